@@ -0,0 +1,237 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// Blpop removes and returns the first element of the first non-empty list
+// among `keys`, blocking until one is available.
+//
+// The `keys` argument is either a single key, or an array of keys. The
+// `timeout` argument is the maximum number of seconds to block for, and
+// can be fractional; a timeout of zero blocks indefinitely.
+//
+// The promise resolves to an object of the form `{ key, value }`, holding
+// the list the element was popped from, or to null if the timeout expired.
+//
+// Blocking commands are sent over a dedicated connection, so that they do
+// not hold the connections used by the other commands, and are canceled
+// when the VU's context is done.
+func (c *Client) Blpop(keys any, timeout float64, params any) *sobek.Promise {
+	return c.blockingPop("blpop", keys, timeout, params)
+}
+
+// Brpop removes and returns the last element of the first non-empty list
+// among `keys`, blocking until one is available.
+//
+// See Blpop for the meaning of its arguments, and of its result.
+func (c *Client) Brpop(keys any, timeout float64, params any) *sobek.Promise {
+	return c.blockingPop("brpop", keys, timeout, params)
+}
+
+// Blmove atomically moves an element from the `whereFrom` side (LEFT or
+// RIGHT) of the list stored at `source`, to the `whereTo` side of the
+// list stored at `destination`, blocking until one is available.
+//
+// The promise resolves to an object of the form `{ key, value }`, where
+// `key` is the source list, or to null if the timeout expired.
+func (c *Client) Blmove(
+	source, destination, whereFrom, whereTo string, timeout float64, params any,
+) *sobek.Promise {
+	return c.blockingMove(source, params, timeout, "blmove", source, destination, whereFrom, whereTo)
+}
+
+// Brpoplpush atomically moves the last element of the list stored at
+// `source` to the head of the list stored at `destination`, blocking
+// until one is available.
+//
+// See Blmove for the meaning of its result.
+func (c *Client) Brpoplpush(source, destination string, timeout float64, params any) *sobek.Promise {
+	return c.blockingMove(source, params, timeout, "brpoplpush", source, destination)
+}
+
+// blockingPop runs the BLPOP or BRPOP command for the provided keys.
+func (c *Client) blockingPop(command string, keys any, timeout float64, params any) *sobek.Promise {
+	keyArgs, err := splitKeys(keys)
+	if err != nil {
+		return c.rejected(fmt.Errorf("invalid %s keys; reason: %w", command, err))
+	}
+
+	args := append([]any{command}, keyArgs...)
+
+	return c.blocking(args, timeout, params, func(value any) any {
+		pair, ok := value.([]any)
+		if !ok || len(pair) != 2 {
+			return value
+		}
+
+		return map[string]any{"key": pair[0], "value": pair[1]}
+	})
+}
+
+// blockingMove runs the BLMOVE or BRPOPLPUSH command with the provided
+// arguments.
+func (c *Client) blockingMove(source string, params any, timeout float64, args ...any) *sobek.Promise {
+	return c.blocking(args, timeout, params, func(value any) any {
+		return map[string]any{"key": source, "value": value}
+	})
+}
+
+// blocking runs the provided blocking command, whose last argument is the
+// timeout, on the dedicated blocking client, and resolves the promise to
+// the result of `convert`, or to null if the timeout expired.
+func (c *Client) blocking(args []any, timeout float64, params any, convert func(any) any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if timeout < 0 {
+		reject(fmt.Errorf("%s timeout must not be negative", args[0]))
+		return promise
+	}
+
+	// The timeout is sent as is, as the go-redis helpers round sub-second
+	// timeouts up to a second.
+	args = append(args, strconv.FormatFloat(timeout, 'f', -1, 64))
+
+	if err := c.connectBlocking(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The dedicated connections do not time out reads, and go-redis
+		// does not interrupt them when the context is done: closing them
+		// unblocks the command instead.
+		stop := context.AfterFunc(ctx, c.blockingConns.interrupt)
+		defer stop()
+
+		value, err := c.blockingClient.Do(ctx, args...).Result()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			reject(fmt.Errorf("blocking command canceled; reason: %w", ctxErr))
+			return
+		}
+
+		if errors.Is(err, redis.Nil) {
+			resolve(nil)
+			return
+		}
+
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(convert(value))
+	}()
+
+	return promise
+}
+
+// rejected returns a promise rejected with the provided error.
+func (c *Client) rejected(err error) *sobek.Promise {
+	promise, _, reject := promises.New(c.vu)
+	reject(err)
+
+	return promise
+}
+
+// connectBlocking establishes the client's connection, and instantiates the
+// dedicated client blocking commands are sent with.
+func (c *Client) connectBlocking() error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	if c.blockingClient != nil {
+		return nil
+	}
+
+	opts := *c.redisOptions
+	opts.ReadTimeout = -1
+	opts.Dialer = c.blockingConns.dialer(c.redisOptions.Dialer)
+
+	c.blockingClient = redis.NewUniversalClient(&opts)
+	c.addHooks(c.blockingClient, c.vu.State())
+
+	return nil
+}
+
+// blockingConns tracks the connections of the blocking client, so that
+// the commands blocked on them can be interrupted.
+type blockingConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// dialer returns a dialer using the provided one, which tracks the
+// connections it establishes until they are closed.
+func (bc *blockingConns) dialer(
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+
+		if bc.conns == nil {
+			bc.conns = make(map[net.Conn]struct{})
+		}
+
+		tc := &trackedConn{Conn: conn, conns: bc}
+		bc.conns[tc] = struct{}{}
+
+		return tc, nil
+	}
+}
+
+// interrupt closes the tracked connections, unblocking the commands
+// waiting on them.
+func (bc *blockingConns) interrupt() {
+	bc.mu.Lock()
+	conns := make([]net.Conn, 0, len(bc.conns))
+	for conn := range bc.conns {
+		conns = append(conns, conn)
+	}
+	bc.mu.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+// trackedConn wraps a net.Conn tracked by blockingConns, and stops
+// tracking it when it is closed.
+type trackedConn struct {
+	net.Conn
+
+	conns *blockingConns
+}
+
+// Close implements the net.Conn interface.
+func (tc *trackedConn) Close() error {
+	tc.conns.mu.Lock()
+	delete(tc.conns.conns, tc)
+	tc.conns.mu.Unlock()
+
+	return tc.Conn.Close()
+}
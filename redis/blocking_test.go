@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientBlockingCommands(t *testing.T) {
+	t.Parallel()
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client of the provided server.
+	runScript := func(t *testing.T, ts testSetup, rs *redistest.StubServer, script string) error {
+		t.Helper()
+
+		return ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				%s
+			`, rs.Addr(), script))
+
+			return err
+		})
+	}
+
+	t.Run("elements are popped and moved", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, ts, rs, `
+			(async () => {
+				// The pop blocks until the element is pushed by another command.
+				const popped = redis.blpop(["empty", "queue"], 1);
+				await redis.rpush("queue", "a", "b", "c", "d");
+				expect(await popped, { key: "queue", value: "a" });
+
+				expect(await redis.brpop("queue", 0.05), { key: "queue", value: "d" });
+				expect(await redis.blmove("queue", "done", "LEFT", "RIGHT", 0.05), { key: "queue", value: "b" });
+				expect(await redis.brpoplpush("queue", "done", 0.05), { key: "queue", value: "c" });
+
+				expect(await redis.lrange("done", 0, -1), ["c", "b"]);
+			})()
+		`)
+		require.NoError(t, err)
+
+		// The blocking commands use their own connection.
+		assert.Equal(t, 2, rs.HandledConnectionsCount())
+	})
+
+	t.Run("timeouts resolve to null", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, ts, rs, `
+			const expectNull = async promise => {
+				const got = await promise;
+				if (got !== null) { throw 'unexpected result: ' + JSON.stringify(got) }
+			};
+
+			(async () => {
+				await expectNull(redis.blpop("queue", 0.05));
+				await expectNull(redis.brpop(["a", "b"], 0.05));
+				await expectNull(redis.blmove("queue", "done", "RIGHT", "LEFT", 0.05));
+				await expectNull(redis.brpoplpush("queue", "done", 0.05));
+			})()
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid arguments are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, ts, rs, `
+			expectError(redis.blpop("queue", -1), "must not be negative")
+				.then(() => expectError(redis.brpop([], 1), "at least one key"))
+				.then(() => expectError(redis.blpop({}, 1), "invalid keys type"))
+				.then(() => expectError(redis.blmove("a", "b", "UP", "LEFT", 1), "syntax error"))
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("blocked commands are canceled with the VU", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		t.Cleanup(cancel)
+		ts.runtime.VU.CtxField = ctx
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		start := time.Now()
+		err := runScript(t, ts, rs, `
+			expectError(redis.blpop("queue", 0), "blocking command canceled")
+		`)
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
@@ -1,10 +1,18 @@
 package redis
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/grafana/sobek"
@@ -13,14 +21,52 @@ import (
 	"go.k6.io/k6/v2/js/modules"
 	"go.k6.io/k6/v2/js/promises"
 	"go.k6.io/k6/v2/lib"
+	"go.k6.io/k6/v2/lib/netext"
+	"go.k6.io/k6/v2/metrics"
 )
 
 // Client represents the Client constructor (i.e. `new redis.Client()`) and
 // returns a new Redis client object.
+//
+// All the command methods accept an optional parameters object as their
+// last argument, of the form `{ tags: {...} }`. The provided tags are added
+// to the metric samples emitted for the command.
+//
+// The methods of commands which have options, such as `getex`, `hgetall` or
+// `geoadd`, take them as an optional options object passed right before the
+// parameters object. To pass parameters without options, null is passed in
+// place of the options: `client.hgetall(key, null, { tags: {...} })`.
 type Client struct {
 	vu           modules.VU
 	redisOptions *redis.UniversalOptions
 	redisClient  redis.UniversalClient
+	metrics      *redisMetrics
+	tags         map[string]string
+	tracing      bool
+	hooks        []jsHook
+
+	// recorder writes the commands processed by the client to the trace
+	// file set by the `record` option, if any.
+	recorder *recorder
+
+	// blockingClient is the client blocking commands are sent with, so
+	// that they do not hold the connections of redisClient. Its
+	// connections are tracked by blockingConns.
+	blockingClient redis.UniversalClient
+	blockingConns  blockingConns
+
+	// hllSets holds the elements added to HyperLogLogs with tracking
+	// enabled, against which their estimated cardinality is verified. It
+	// is shared by all the VUs' clients.
+	hllSets *hllTracker
+
+	// poolStatsEmission tracks the emission of the connection pool
+	// statistics of redisClient.
+	poolStatsEmission poolStatsEmission
+
+	// traffic holds the bytes exchanged through the client's connections
+	// which were not emitted as metric samples yet.
+	traffic clientTraffic
 }
 
 // Set the given key with the given value.
@@ -28,7 +74,7 @@ type Client struct {
 // If the provided value is not a supported type, the promise is rejected with an error.
 //
 // The value for `expiration` is interpreted as seconds.
-func (c *Client) Set(key string, value any, expiration int) *sobek.Promise {
+func (c *Client) Set(key string, value any, expiration int, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -41,8 +87,16 @@ func (c *Client) Set(key string, value any, expiration int) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		result, err := c.redisClient.Set(c.vu.Context(), key, value, time.Duration(expiration)*time.Second).Result()
+		defer done()
+
+		result, err := c.redisClient.Set(ctx, key, value, time.Duration(expiration)*time.Second).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -59,7 +113,7 @@ func (c *Client) Set(key string, value any, expiration int) *sobek.Promise {
 // If the key does not exist, the promise is rejected with an error.
 //
 // If the key does not exist, the promise is rejected with an error.
-func (c *Client) Get(key string) *sobek.Promise {
+func (c *Client) Get(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -67,8 +121,16 @@ func (c *Client) Get(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.Get(c.vu.Context(), key).Result()
+		defer done()
+
+		value, err := c.redisClient.Get(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -83,7 +145,7 @@ func (c *Client) Get(key string) *sobek.Promise {
 // GetSet sets the value of key to value and returns the old value stored
 //
 // If the provided value is not a supported type, the promise is rejected with an error.
-func (c *Client) GetSet(key string, value any) *sobek.Promise {
+func (c *Client) GetSet(key string, value, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -96,8 +158,16 @@ func (c *Client) GetSet(key string, value any) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		oldValue, err := c.redisClient.GetSet(c.vu.Context(), key, value).Result()
+		defer done()
+
+		oldValue, err := c.redisClient.GetSet(ctx, key, value).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -110,7 +180,9 @@ func (c *Client) GetSet(key string, value any) *sobek.Promise {
 }
 
 // Del removes the specified keys. A key is ignored if it does not exist
-func (c *Client) Del(keys ...string) *sobek.Promise {
+func (c *Client) Del(keys ...any) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -118,8 +190,21 @@ func (c *Client) Del(keys ...string) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(0, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.Del(c.vu.Context(), keys...).Result()
+		defer done()
+
+		n, err := c.redisClient.Del(ctx, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -134,7 +219,7 @@ func (c *Client) Del(keys ...string) *sobek.Promise {
 // GetDel gets the value of key and deletes the key.
 //
 // If the key does not exist, the promise is rejected with an error.
-func (c *Client) GetDel(key string) *sobek.Promise {
+func (c *Client) GetDel(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -142,8 +227,16 @@ func (c *Client) GetDel(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.GetDel(c.vu.Context(), key).Result()
+		defer done()
+
+		value, err := c.redisClient.GetDel(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -158,7 +251,9 @@ func (c *Client) GetDel(key string) *sobek.Promise {
 // Exists returns the number of key arguments that exist.
 // Note that if the same existing key is mentioned in the argument
 // multiple times, it will be counted multiple times.
-func (c *Client) Exists(keys ...string) *sobek.Promise {
+func (c *Client) Exists(keys ...any) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -166,8 +261,21 @@ func (c *Client) Exists(keys ...string) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(0, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.Exists(c.vu.Context(), keys...).Result()
+		defer done()
+
+		n, err := c.redisClient.Exists(ctx, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -183,7 +291,7 @@ func (c *Client) Exists(keys ...string) *sobek.Promise {
 // not exist, it is set to zero before performing the operation. An
 // error is returned if the key contains a value of the wrong type, or
 // contains a string that cannot be represented as an integer.
-func (c *Client) Incr(key string) *sobek.Promise {
+func (c *Client) Incr(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -191,8 +299,16 @@ func (c *Client) Incr(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		newValue, err := c.redisClient.Incr(c.vu.Context(), key).Result()
+		defer done()
+
+		newValue, err := c.redisClient.Incr(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -208,7 +324,7 @@ func (c *Client) Incr(key string) *sobek.Promise {
 // not exist, it is set to zero before performing the operation. An
 // error is returned if the key contains a value of the wrong type, or
 // contains a string that cannot be represented as an integer.
-func (c *Client) IncrBy(key string, increment int64) *sobek.Promise {
+func (c *Client) IncrBy(key string, increment int64, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -216,8 +332,16 @@ func (c *Client) IncrBy(key string, increment int64) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		newValue, err := c.redisClient.IncrBy(c.vu.Context(), key, increment).Result()
+		defer done()
+
+		newValue, err := c.redisClient.IncrBy(ctx, key, increment).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -233,7 +357,7 @@ func (c *Client) IncrBy(key string, increment int64) *sobek.Promise {
 // not exist, it is set to zero before performing the operation. An
 // error is returned if the key contains a value of the wrong type, or
 // contains a string that cannot be represented as an integer.
-func (c *Client) Decr(key string) *sobek.Promise {
+func (c *Client) Decr(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -241,8 +365,16 @@ func (c *Client) Decr(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		newValue, err := c.redisClient.Decr(c.vu.Context(), key).Result()
+		defer done()
+
+		newValue, err := c.redisClient.Decr(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -258,7 +390,7 @@ func (c *Client) Decr(key string) *sobek.Promise {
 // not exist, it is set to zero before performing the operation. An
 // error is returned if the key contains a value of the wrong type, or
 // contains a string that cannot be represented as an integer.
-func (c *Client) DecrBy(key string, decrement int64) *sobek.Promise {
+func (c *Client) DecrBy(key string, decrement int64, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -266,8 +398,16 @@ func (c *Client) DecrBy(key string, decrement int64) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		newValue, err := c.redisClient.DecrBy(c.vu.Context(), key, decrement).Result()
+		defer done()
+
+		newValue, err := c.redisClient.DecrBy(ctx, key, decrement).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -282,7 +422,7 @@ func (c *Client) DecrBy(key string, decrement int64) *sobek.Promise {
 // RandomKey returns a random key.
 //
 // If the database is empty, the promise is rejected with an error.
-func (c *Client) RandomKey() *sobek.Promise {
+func (c *Client) RandomKey(params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -290,8 +430,16 @@ func (c *Client) RandomKey() *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		key, err := c.redisClient.RandomKey(c.vu.Context()).Result()
+		defer done()
+
+		key, err := c.redisClient.RandomKey(ctx).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -304,7 +452,9 @@ func (c *Client) RandomKey() *sobek.Promise {
 }
 
 // Mget returns the values associated with the specified keys.
-func (c *Client) Mget(keys ...string) *sobek.Promise {
+func (c *Client) Mget(keys ...any) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -312,8 +462,21 @@ func (c *Client) Mget(keys ...string) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(0, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		values, err := c.redisClient.MGet(c.vu.Context(), keys...).Result()
+		defer done()
+
+		values, err := c.redisClient.MGet(ctx, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -325,11 +488,10 @@ func (c *Client) Mget(keys ...string) *sobek.Promise {
 	return promise
 }
 
-// Expire sets a timeout on key, after which the key will automatically
-// be deleted.
-// Note that calling Expire with a non-positive timeout will result in
-// the key being deleted rather than expired.
-func (c *Client) Expire(key string, seconds int) *sobek.Promise {
+// Append appends `value` at the end of the string stored at `key`, which
+// is created if it does not exist, and returns the length of the resulting
+// string.
+func (c *Client) Append(key, value string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -337,23 +499,30 @@ func (c *Client) Expire(key string, seconds int) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		ok, err := c.redisClient.Expire(c.vu.Context(), key, time.Duration(seconds)*time.Second).Result()
+		defer done()
+
+		n, err := c.redisClient.Append(ctx, key, value).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(ok)
+		resolve(n)
 	}()
 
 	return promise
 }
 
-// Ttl returns the remaining time to live of a key that has a timeout.
-//
-//nolint:revive
-func (c *Client) Ttl(key string) *sobek.Promise {
+// Strlen returns the length of the string stored at `key`, or 0 if it
+// does not exist.
+func (c *Client) Strlen(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -361,21 +530,31 @@ func (c *Client) Ttl(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		duration, err := c.redisClient.TTL(c.vu.Context(), key).Result()
+		defer done()
+
+		n, err := c.redisClient.StrLen(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(duration.Seconds())
+		resolve(n)
 	}()
 
 	return promise
 }
 
-// Persist removes the existing timeout on key.
-func (c *Client) Persist(key string) *sobek.Promise {
+// Getrange returns the substring of the string stored at `key`, between
+// the `start` and `end` offsets, both inclusive. Negative offsets count
+// from the end of the string.
+func (c *Client) Getrange(key string, start, end int64, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -383,133 +562,176 @@ func (c *Client) Persist(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		ok, err := c.redisClient.Persist(c.vu.Context(), key).Result()
+		defer done()
+
+		value, err := c.redisClient.GetRange(ctx, key, start, end).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(ok)
+		resolve(value)
 	}()
 
 	return promise
 }
 
-// Lpush inserts all the specified values at the head of the list stored
-// at `key`. If `key` does not exist, it is created as empty list before
-// performing the push operations. When `key` holds a value that is not
-// a list, and error is returned.
-func (c *Client) Lpush(key string, values ...any) *sobek.Promise {
+// Setrange overwrites the string stored at `key` with `value`, starting
+// at `offset`, padding it with zero bytes if it is shorter than `offset`,
+// and returns the length of the resulting string.
+func (c *Client) Setrange(key string, offset int64, value string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	if offset < 0 {
+		reject(fmt.Errorf("setrange offset must not be negative, got %d", offset))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
-	if err := c.isSupportedType(1, values...); err != nil {
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		listLength, err := c.redisClient.LPush(c.vu.Context(), key, values...).Result()
+		defer done()
+
+		n, err := c.redisClient.SetRange(ctx, key, offset, value).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(listLength)
+		resolve(n)
 	}()
 
 	return promise
 }
 
-// Rpush inserts all the specified values at the tail of the list stored
-// at `key`. If `key` does not exist, it is created as empty list before
-// performing the push operations.
-func (c *Client) Rpush(key string, values ...any) *sobek.Promise {
+// Mset sets the provided keys to their respective values, replacing their
+// existing values, if any.
+//
+// The keys and values are passed either as an object mapping the keys to
+// their values, `mset({ a: 1, b: 2 })`, or as alternating keys and values,
+// `mset("a", 1, "b", 2)`.
+func (c *Client) Mset(args ...any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	values, params, err := c.keyValues("mset", args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
-	if err := c.isSupportedType(1, values...); err != nil {
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		listLength, err := c.redisClient.RPush(c.vu.Context(), key, values...).Result()
+		defer done()
+
+		status, err := c.redisClient.MSet(ctx, values...).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(listLength)
+		resolve(status)
 	}()
 
 	return promise
 }
 
-// Lpop removes and returns the first element of the list stored at `key`.
-//
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Lpop(key string) *sobek.Promise {
-	// TODO: redis supports indicating the amount of values to pop
+// Msetnx is like Mset, but only sets the keys if none of them exist. The
+// promise resolves to whether they were set.
+func (c *Client) Msetnx(args ...any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	values, params, err := c.keyValues("msetnx", args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.LPop(c.vu.Context(), key).Result()
+		defer done()
+
+		set, err := c.redisClient.MSetNX(ctx, values...).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(value)
+		resolve(set)
 	}()
 
 	return promise
 }
 
-// Rpop removes and returns the last element of the list stored at `key`.
-//
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Rpop(key string) *sobek.Promise {
-	// TODO: redis supports indicating the amount of values to pop
-	promise, resolve, reject := promises.New(c.vu)
-
-	if err := c.connect(); err != nil {
-		reject(err)
-		return promise
-	}
+// keyValues returns the keys and values passed to the named Mset or Msetnx
+// method, as a flat list of arguments, along with the parameters object.
+func (c *Client) keyValues(name string, args []any) ([]any, any, error) {
+	if keys, ok := firstArg(args).(map[string]any); ok {
+		if len(args) > 2 {
+			return nil, nil, fmt.Errorf(
+				"%s expects an object of keys and values, and parameters, got %d arguments", name, len(args))
+		}
 
-	go func() {
-		value, err := c.redisClient.RPop(c.vu.Context(), key).Result()
+		values, err := c.fieldValues(0, keys)
 		if err != nil {
-			reject(err)
-			return
+			return nil, nil, err
 		}
 
-		resolve(value)
-	}()
+		return values, firstArg(args[1:]), nil
+	}
 
-	return promise
+	values, params := splitCommandParams(args)
+	if len(values) == 0 || len(values)%2 != 0 {
+		return nil, nil, fmt.Errorf("%s expects keys and values, or an object of keys and values", name)
+	}
+
+	if err := c.isSupportedType(0, values...); err != nil {
+		return nil, nil, err
+	}
+
+	return values, params, nil
 }
 
-// Lrange returns the specified elements of the list stored at `key`. The
-// offsets start and stop are zero-based indexes. These offsets can be
-// negative numbers, where they indicate offsets starting at the end of
-// the list.
-func (c *Client) Lrange(key string, start, stop int64) *sobek.Promise {
+// Setnx sets `key` to `value`, only if it does not exist, and returns
+// whether it was set.
+//
+// If the provided value is not a supported type, the promise is rejected with an error.
+func (c *Client) Setnx(key string, value, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -517,58 +739,130 @@ func (c *Client) Lrange(key string, start, stop int64) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(1, value); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		values, err := c.redisClient.LRange(c.vu.Context(), key, start, stop).Result()
+		defer done()
+
+		set, err := c.redisClient.SetNX(ctx, key, value, 0).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(values)
+		resolve(set)
 	}()
 
 	return promise
 }
 
-// Lindex returns the specified element of the list stored at `key`.
-// The index is zero-based. Negative indices can be used to designate
-// elements starting at the tail of the list.
+// Setex sets `key` to `value`, along with a time to live of `seconds`.
 //
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Lindex(key string, index int64) *sobek.Promise {
+// If the provided value is not a supported type, the promise is rejected with an error.
+func (c *Client) Setex(key string, seconds int64, value, params any) *sobek.Promise {
+	return c.setex("setex", key, seconds, value, params)
+}
+
+// Psetex is like Setex, but the time to live is in milliseconds.
+func (c *Client) Psetex(key string, milliseconds int64, value, params any) *sobek.Promise {
+	return c.setex("psetex", key, milliseconds, value, params)
+}
+
+// setex runs the named SETEX or PSETEX command.
+func (c *Client) setex(command, key string, ttl int64, value, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	if ttl <= 0 {
+		reject(fmt.Errorf("%s time to live must be positive, got %d", command, ttl))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	if err := c.isSupportedType(2, value); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.LIndex(c.vu.Context(), key, index).Result()
+		defer done()
+
+		// go-redis has no PSETEX helper, and its SETEX one truncates the
+		// time to live to seconds.
+		status, err := c.redisClient.Do(ctx, command, key, ttl, value).Text()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(value)
+		resolve(status)
 	}()
 
 	return promise
 }
 
-// Lset sets the list element at `index` to `element`.
+// getexOptions holds the options of the Getex method.
+type getexOptions struct {
+	fieldExpirationOptions
+
+	// Persist removes the expiration time of the key.
+	Persist bool `json:"persist,omitempty"`
+}
+
+// Getex returns the value of `key`, and sets or removes its expiration
+// time, using one of the `ex`, `px`, `exAt`, `pxAt` or `persist` options of
+// the optional `options` object.
 //
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Lset(key string, index int64, element string) *sobek.Promise {
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) Getex(key string, options, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	opts := &getexOptions{}
+	if err := decodeCommandOptions("getex", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	expiration, err := opts.args("PERSIST", opts.Persist)
+	if err != nil {
+		reject(fmt.Errorf("invalid getex options; reason: %w", err))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.LSet(c.vu.Context(), key, index, element).Result()
+		defer done()
+
+		// The go-redis helper only supports relative expiration times.
+		value, err := c.redisClient.Do(ctx, append([]any{"getex", key}, expiration...)...).Text()
 		if err != nil {
 			reject(err)
 			return
@@ -580,13 +874,10 @@ func (c *Client) Lset(key string, index int64, element string) *sobek.Promise {
 	return promise
 }
 
-// Lrem removes the first `count` occurrences of `value` from the list stored
-// at `key`. If `count` is positive, elements are removed from the beginning of the list.
-// If `count` is negative, elements are removed from the end of the list.
-// If `count` is zero, all elements matching `value` are removed.
-//
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Lrem(key string, count int64, value string) *sobek.Promise {
+// Incrbyfloat increments the floating point number stored at `key` by
+// `increment`, and returns its new value. If the key does not exist, it
+// is set to zero before performing the operation.
+func (c *Client) Incrbyfloat(key string, increment float64, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -594,50 +885,67 @@ func (c *Client) Lrem(key string, count int64, value string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.LRem(c.vu.Context(), key, count, value).Result()
+		defer done()
+
+		newValue, err := c.redisClient.IncrByFloat(ctx, key, increment).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(n)
+		resolve(newValue)
 	}()
 
 	return promise
 }
 
-// Llen returns the length of the list stored at `key`. If `key`
-// does not exist, it is interpreted as an empty list and 0 is returned.
-//
-// If the list does not exist, this command rejects the promise with an error.
-func (c *Client) Llen(key string) *sobek.Promise {
+// Setbit sets or clears the bit at `offset` of the string stored at `key`,
+// depending on `value`, which is either 0 or 1, and returns the previous
+// value of the bit. The string is grown as needed.
+func (c *Client) Setbit(key string, offset int64, value int, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	if value != 0 && value != 1 {
+		reject(fmt.Errorf("setbit value must be 0 or 1, got %d", value))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		length, err := c.redisClient.LLen(c.vu.Context(), key).Result()
+		defer done()
+
+		previous, err := c.redisClient.SetBit(ctx, key, offset, value).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(length)
+		resolve(previous)
 	}()
 
 	return promise
 }
 
-// Hset sets the specified field in the hash stored at `key` to `value`.
-// If the `key` does not exist, a new key holding a hash is created.
-// If `field` already exists in the hash, it is overwritten.
-//
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hset(key string, field string, value any) *sobek.Promise {
+// Getbit returns the value of the bit at `offset` of the string stored at
+// `key`, bits beyond the end of the string being 0.
+func (c *Client) Getbit(key string, offset int64, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -645,84 +953,230 @@ func (c *Client) Hset(key string, field string, value any) *sobek.Promise {
 		return promise
 	}
 
-	if err := c.isSupportedType(2, value); err != nil {
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		n, err := c.redisClient.HSet(c.vu.Context(), key, field, value).Result()
+		defer done()
+
+		bit, err := c.redisClient.GetBit(ctx, key, offset).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(n)
+		resolve(bit)
 	}()
 
 	return promise
 }
 
-// Hsetnx sets the specified field in the hash stored at `key` to `value`,
-// only if `field` does not yet exist. If `key` does not exist, a new key
-// holding a hash is created. If `field` already exists, this operation
-// has no effect.
-func (c *Client) Hsetnx(key, field, value string) *sobek.Promise {
+// bitRangeOptions holds the range options of the Bitcount and Bitpos
+// methods.
+type bitRangeOptions struct {
+	// Start and End are the inclusive offsets of the range, negative
+	// offsets counting from the end of the string.
+	Start *int64 `json:"start,omitempty"`
+	End   *int64 `json:"end,omitempty"`
+
+	// Unit is the unit of the offsets, either BYTE, the default, or BIT.
+	Unit string `json:"unit,omitempty"`
+}
+
+// validate checks the range options of the named command, and returns
+// their unit, in upper case. A range needs a start to have an end, and an
+// end to have a unit.
+func (o bitRangeOptions) validate(command string) (string, error) {
+	unit := strings.ToUpper(o.Unit)
+	switch {
+	case unit != "" && unit != redis.BitCountIndexByte && unit != redis.BitCountIndexBit:
+		return "", fmt.Errorf("invalid %s options; reason: unknown unit %q", command, o.Unit)
+	case o.End != nil && o.Start == nil:
+		return "", fmt.Errorf("invalid %s options; reason: end requires start", command)
+	case unit != "" && o.End == nil:
+		return "", fmt.Errorf("invalid %s options; reason: unit requires start and end", command)
+	}
+
+	return unit, nil
+}
+
+// Bitcount returns the number of bits set in the string stored at `key`.
+//
+// The optional `options` object restricts the count to the range between
+// its `start` and `end` offsets, both of which are then required, which
+// are in bytes, or in bits if its `unit` option is BIT.
+func (c *Client) Bitcount(key string, options, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	opts := &bitRangeOptions{}
+	if err := decodeCommandOptions("bitcount", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	unit, err := opts.validate("bitcount")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	var bitCount *redis.BitCount
+	switch {
+	case opts.Start != nil && opts.End != nil:
+		bitCount = &redis.BitCount{Start: *opts.Start, End: *opts.End, Unit: unit}
+	case opts.Start != nil:
+		reject(errors.New("invalid bitcount options; reason: start requires end"))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		ok, err := c.redisClient.HSetNX(c.vu.Context(), key, field, value).Result()
+		defer done()
+
+		n, err := c.redisClient.BitCount(ctx, key, bitCount).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(ok)
+		resolve(n)
 	}()
 
 	return promise
 }
 
-// Hget returns the value associated with `field` in the hash stored at `key`.
+// Bitpos returns the position of the first bit set to `bit`, either 0 or
+// 1, in the string stored at `key`, or -1 if there is none.
 //
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hget(key, field string) *sobek.Promise {
+// The optional `options` object restricts the search to the range starting
+// at its `start` offset, and ending at its `end` offset, if any, which are
+// in bytes, or in bits if its `unit` option is BIT.
+func (c *Client) Bitpos(key string, bit int64, options, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	if bit != 0 && bit != 1 {
+		reject(fmt.Errorf("bitpos bit must be 0 or 1, got %d", bit))
+		return promise
+	}
+
+	opts := &bitRangeOptions{}
+	if err := decodeCommandOptions("bitpos", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	unit, err := opts.validate("bitpos")
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		value, err := c.redisClient.HGet(c.vu.Context(), key, field).Result()
+		defer done()
+
+		var cmd *redis.IntCmd
+		switch {
+		case unit != "":
+			cmd = c.redisClient.BitPosSpan(ctx, key, int8(bit), *opts.Start, *opts.End, strings.ToLower(unit))
+		case opts.End != nil:
+			cmd = c.redisClient.BitPos(ctx, key, bit, *opts.Start, *opts.End)
+		case opts.Start != nil:
+			cmd = c.redisClient.BitPos(ctx, key, bit, *opts.Start)
+		default:
+			cmd = c.redisClient.BitPos(ctx, key, bit)
+		}
+
+		pos, err := cmd.Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(value)
+		resolve(pos)
 	}()
 
 	return promise
 }
 
-// Hdel deletes the specified fields from the hash stored at `key`.
-func (c *Client) Hdel(key string, fields ...string) *sobek.Promise {
+// bitOpMethods holds the go-redis methods running the BITOP command with
+// each of its operations, but NOT, which takes a single key.
+var bitOpMethods = map[string]func(redis.UniversalClient, context.Context, string, ...string) *redis.IntCmd{
+	"AND":   redis.UniversalClient.BitOpAnd,
+	"OR":    redis.UniversalClient.BitOpOr,
+	"XOR":   redis.UniversalClient.BitOpXor,
+	"DIFF":  redis.UniversalClient.BitOpDiff,
+	"DIFF1": redis.UniversalClient.BitOpDiff1,
+	"ANDOR": redis.UniversalClient.BitOpAndOr,
+	"ONE":   redis.UniversalClient.BitOpOne,
+}
+
+// Bitop performs the bitwise `operation` (AND, OR, XOR, NOT, DIFF, DIFF1,
+// ANDOR or ONE) between the strings stored at the provided keys, stores
+// the result in `destination`, and returns its length.
+func (c *Client) Bitop(operation, destination string, keys ...any) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
+	operation = strings.ToUpper(operation)
+	method, ok := bitOpMethods[operation]
+	switch {
+	case operation == "NOT" && len(keys) != 1:
+		reject(fmt.Errorf("bitop NOT expects a single key, got %d", len(keys)))
+		return promise
+	case operation == "NOT":
+		method = func(client redis.UniversalClient, ctx context.Context, destination string, keys ...string) *redis.IntCmd {
+			return client.BitOpNot(ctx, destination, keys[0])
+		}
+	case !ok:
+		reject(fmt.Errorf("unknown bitop operation %q", operation))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	if err := c.isSupportedType(2, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.HDel(c.vu.Context(), key, fields...).Result()
+		defer done()
+
+		n, err := method(c.redisClient, ctx, destination, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -734,34 +1188,153 @@ func (c *Client) Hdel(key string, fields ...string) *sobek.Promise {
 	return promise
 }
 
-// Hgetall returns all fields and values of the hash stored at `key`.
+// bitfieldOperation holds an operation of the Bitfield method.
+type bitfieldOperation struct {
+	// Op is the operation: get, set, incrby or overflow.
+	Op string `json:"op"`
+
+	// Type is the type of the integer the get, set and incrby operations
+	// operate on, such as i8 or u16.
+	Type string `json:"type,omitempty"`
+
+	// Offset is the bit offset of the integer, either a number, or a
+	// string prefixed by # to multiply it by the width of the type.
+	Offset any `json:"offset,omitempty"`
+
+	// Value is the value set by the set operation, and Increment the
+	// increment of the incrby operation.
+	Value     *int64 `json:"value,omitempty"`
+	Increment *int64 `json:"increment,omitempty"`
+
+	// Behavior is the WRAP, SAT or FAIL behavior of the overflow
+	// operation, applying to the following set and incrby operations.
+	Behavior string `json:"behavior,omitempty"`
+}
+
+// args returns the arguments of the BITFIELD command for the operation.
+func (o bitfieldOperation) args() ([]any, error) {
+	op := strings.ToUpper(o.Op)
+	if op == "OVERFLOW" {
+		behavior := strings.ToUpper(o.Behavior)
+		if behavior != "WRAP" && behavior != "SAT" && behavior != "FAIL" {
+			return nil, fmt.Errorf("unknown overflow behavior %q", o.Behavior)
+		}
+
+		return []any{op, behavior}, nil
+	}
+
+	if o.Type == "" {
+		return nil, fmt.Errorf("%s requires a type", o.Op)
+	}
+
+	var offset any
+	switch v := o.Offset.(type) {
+	case float64:
+		if v < 0 || v != math.Trunc(v) {
+			return nil, fmt.Errorf("invalid offset %v; expected a non-negative integer", v)
+		}
+		offset = int64(v)
+	case string:
+		if !strings.HasPrefix(v, "#") {
+			return nil, fmt.Errorf("invalid offset %q; expected a number, or a string prefixed by #", v)
+		}
+		offset = v
+	default:
+		return nil, fmt.Errorf("invalid offset type: %T; expected number or string", o.Offset)
+	}
+
+	switch op {
+	case "GET":
+		return []any{op, o.Type, offset}, nil
+	case "SET":
+		if o.Value == nil {
+			return nil, errors.New("set requires a value")
+		}
+		return []any{op, o.Type, offset, *o.Value}, nil
+	case "INCRBY":
+		if o.Increment == nil {
+			return nil, errors.New("incrby requires an increment")
+		}
+		return []any{op, o.Type, offset, *o.Increment}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", o.Op)
+	}
+}
+
+// Bitfield performs the provided `operations` on the integers of arbitrary
+// width stored in the string at `key`, and returns an array holding the
+// result of each get, set and incrby operation, in order.
 //
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hgetall(key string) *sobek.Promise {
+// Each operation is an object of the form `{ op: "get", type: "u8",
+// offset: 0 }`, `{ op: "set", type: "i16", offset: "#1", value: -3 }`,
+// `{ op: "incrby", type: "u4", offset: 8, increment: 1 }`, or `{ op:
+// "overflow", behavior: "SAT" }`. Set and incrby operations which overflow
+// with the FAIL behavior have a null result.
+func (c *Client) Bitfield(key string, operations, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	ops, ok := operations.([]any)
+	if !ok || len(ops) == 0 {
+		reject(fmt.Errorf("invalid bitfield operations: %v; expected a non-empty array", operations))
+		return promise
+	}
+
+	var args []any
+	for idx, op := range ops {
+		if op == nil {
+			reject(fmt.Errorf("invalid bitfield operation at index %d; reason: expected object", idx))
+			return promise
+		}
+
+		operation := bitfieldOperation{}
+		if err := decodeCommandOptions("bitfield operation", op, &operation); err != nil {
+			reject(fmt.Errorf("invalid bitfield operation at index %d; reason: %w", idx, err))
+			return promise
+		}
+
+		opArgs, err := operation.args()
+		if err != nil {
+			reject(fmt.Errorf("invalid bitfield operation at index %d; reason: %w", idx, err))
+			return promise
+		}
+
+		args = append(args, opArgs...)
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		hashMap, err := c.redisClient.HGetAll(c.vu.Context(), key).Result()
+		defer done()
+
+		// The go-redis BitField helper fails altogether when one of the
+		// operations overflows with the FAIL behavior, rather than
+		// replying with null for it.
+		results, err := c.redisClient.Do(ctx, append([]any{"bitfield", key}, args...)...).Slice()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(hashMap)
+		resolve(results)
 	}()
 
 	return promise
 }
 
-// Hkeys returns all fields of the hash stored at `key`.
-//
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hkeys(key string) *sobek.Promise {
+// Expire sets a timeout on key, after which the key will automatically
+// be deleted.
+// Note that calling Expire with a non-positive timeout will result in
+// the key being deleted rather than expired.
+func (c *Client) Expire(key string, seconds int, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -769,96 +1342,2554 @@ func (c *Client) Hkeys(key string) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		keys, err := c.redisClient.HKeys(c.vu.Context(), key).Result()
+		defer done()
+
+		ok, err := c.redisClient.Expire(ctx, key, time.Duration(seconds)*time.Second).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(keys)
+		resolve(ok)
 	}()
 
 	return promise
 }
 
-// Hvals returns all values of the hash stored at `key`.
+// Ttl returns the remaining time to live of a key that has a timeout.
 //
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hvals(key string) *sobek.Promise {
+//nolint:revive
+func (c *Client) Ttl(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		duration, err := c.redisClient.TTL(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(duration.Seconds())
+	}()
+
+	return promise
+}
+
+// Persist removes the existing timeout on key.
+func (c *Client) Persist(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ok, err := c.redisClient.Persist(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(ok)
+	}()
+
+	return promise
+}
+
+// Pexpire is like Expire, but the timeout is in milliseconds.
+func (c *Client) Pexpire(key string, milliseconds int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ok, err := c.redisClient.PExpire(ctx, key, time.Duration(milliseconds)*time.Millisecond).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(ok)
+	}()
+
+	return promise
+}
+
+// Pttl returns the remaining time to live of `key`, in milliseconds, -1
+// if it has no timeout, or -2 if it does not exist.
+func (c *Client) Pttl(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ttl, err := c.redisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(durationIn(ttl, time.Millisecond))
+	}()
+
+	return promise
+}
+
+// Expireat sets the Unix time, in seconds, at which `key` expires. A time
+// in the past deletes the key.
+func (c *Client) Expireat(key string, timestamp int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ok, err := c.redisClient.ExpireAt(ctx, key, time.Unix(timestamp, 0)).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(ok)
+	}()
+
+	return promise
+}
+
+// Expiretime returns the Unix time, in seconds, at which `key` expires,
+// -1 if it has no timeout, or -2 if it does not exist.
+func (c *Client) Expiretime(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
-	if err := c.connect(); err != nil {
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		timestamp, err := c.redisClient.ExpireTime(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(durationIn(timestamp, time.Second))
+	}()
+
+	return promise
+}
+
+// durationIn returns the provided duration, as replied by go-redis for the
+// commands returning a time to live or a timestamp, in the provided unit.
+// The -1 and -2 special values are returned as such.
+func durationIn(d, unit time.Duration) int64 {
+	if d < 0 {
+		return int64(d)
+	}
+
+	return int64(d / unit)
+}
+
+// Type returns the type of the value stored at `key`: string, list, set,
+// zset, hash or stream, or none if it does not exist.
+func (c *Client) Type(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		typ, err := c.redisClient.Type(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(typ)
+	}()
+
+	return promise
+}
+
+// Rename renames `key` to `newKey`, replacing it if it exists.
+//
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) Rename(key, newKey string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		status, err := c.redisClient.Rename(ctx, key, newKey).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(status)
+	}()
+
+	return promise
+}
+
+// Renamenx renames `key` to `newKey`, only if it does not exist, and
+// returns whether it was renamed.
+//
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) Renamenx(key, newKey string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		renamed, err := c.redisClient.RenameNX(ctx, key, newKey).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(renamed)
+	}()
+
+	return promise
+}
+
+// copyOptions holds the options of the Copy method.
+type copyOptions struct {
+	// DB is the database to copy the value to, instead of the current one.
+	DB *int64 `json:"db,omitempty"`
+
+	// Replace replaces the destination key if it exists.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// Copy copies the value stored at `source` to `destination`, and returns
+// whether it was copied, which it is not if the destination key exists.
+//
+// The optional `options` object accepts a `db` option, to copy the value
+// to another database, and a `replace` option, to replace the destination
+// key if it exists.
+func (c *Client) Copy(source, destination string, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &copyOptions{}
+	if err := decodeCommandOptions("copy", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if opts.DB != nil && *opts.DB < 0 {
+		reject(fmt.Errorf("invalid copy options; reason: db must not be negative, got %d", *opts.DB))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The go-redis helper always passes a database, which cluster
+		// nodes refuse.
+		args := []any{"copy", source, destination}
+		if opts.DB != nil {
+			args = append(args, "DB", *opts.DB)
+		}
+		if opts.Replace {
+			args = append(args, "REPLACE")
+		}
+
+		n, err := c.redisClient.Do(ctx, args...).Int()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n == 1)
+	}()
+
+	return promise
+}
+
+// Unlink removes the specified keys, like Del, but reclaims their memory
+// asynchronously, and returns the number of keys that were removed.
+func (c *Client) Unlink(keys ...any) *sobek.Promise {
+	return c.keysCommand(redis.UniversalClient.Unlink, keys)
+}
+
+// Touch updates the last access time of the specified keys, and returns
+// the number of keys that exist.
+func (c *Client) Touch(keys ...any) *sobek.Promise {
+	return c.keysCommand(redis.UniversalClient.Touch, keys)
+}
+
+// keysCommand runs the UNLINK or TOUCH command, using the provided go-redis
+// method.
+func (c *Client) keysCommand(
+	method func(redis.UniversalClient, context.Context, ...string) *redis.IntCmd, keys []any,
+) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(0, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := method(c.redisClient, ctx, toStrings(keys)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// ObjectEncoding returns the internal encoding of the value stored at
+// `key`, such as listpack or embstr.
+//
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) ObjectEncoding(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		encoding, err := c.redisClient.ObjectEncoding(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(encoding)
+	}()
+
+	return promise
+}
+
+// ObjectFreq returns the logarithmic access frequency counter of `key`,
+// which is only tracked when the server uses an LFU maxmemory policy.
+//
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) ObjectFreq(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		freq, err := c.redisClient.ObjectFreq(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(freq)
+	}()
+
+	return promise
+}
+
+// ObjectIdletime returns the number of seconds since `key` was last
+// accessed.
+//
+// If the key does not exist, the promise is rejected with an error.
+func (c *Client) ObjectIdletime(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		idle, err := c.redisClient.ObjectIdleTime(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(durationIn(idle, time.Second))
+	}()
+
+	return promise
+}
+
+// Dump returns the value stored at `key`, serialized in a Redis-specific
+// format, as an ArrayBuffer which can be passed to Restore, or null if the
+// key does not exist.
+func (c *Client) Dump(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	// An ArrayBuffer can only be instantiated from the event loop.
+	enqueue := c.vu.RegisterCallback()
+
+	go func() {
+		defer done()
+
+		serialized, err := c.redisClient.Dump(ctx, key).Result()
+
+		enqueue(func() error {
+			switch {
+			case errors.Is(err, redis.Nil):
+				resolve(nil)
+			case err != nil:
+				reject(err)
+			default:
+				resolve(c.vu.Runtime().NewArrayBuffer([]byte(serialized)))
+			}
+
+			return nil
+		})
+	}()
+
+	return promise
+}
+
+// restoreOptions holds the options of the Restore method.
+type restoreOptions struct {
+	// Replace replaces the key if it exists.
+	Replace bool `json:"replace,omitempty"`
+
+	// AbsTTL interprets the time to live as the Unix time, in milliseconds,
+	// at which the key expires.
+	AbsTTL bool `json:"absTtl,omitempty"`
+
+	// IdleTime and Freq set the idle time, in seconds, and the access
+	// frequency counter of the key, as reported by the OBJECT command.
+	IdleTime *int64 `json:"idleTime,omitempty"`
+	Freq     *int64 `json:"freq,omitempty"`
+}
+
+// Restore creates `key` holding the provided `serialized` value, as
+// returned by Dump, either as an ArrayBuffer or as a string, along with a
+// time to live of `ttl` milliseconds, 0 meaning no expiration.
+//
+// The optional `options` object accepts the `replace`, `absTtl`,
+// `idleTime` and `freq` options.
+//
+// If the key already exists, and the `replace` option is not set, the
+// promise is rejected with an error.
+func (c *Client) Restore(key string, ttl int64, serialized, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	var value string
+	switch serialized := serialized.(type) {
+	case sobek.ArrayBuffer:
+		value = string(serialized.Bytes())
+	case string:
+		value = serialized
+	default:
+		reject(fmt.Errorf("invalid restore value type: %T; expected ArrayBuffer or string", serialized))
+		return promise
+	}
+
+	opts := &restoreOptions{}
+	if err := decodeCommandOptions("restore", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if ttl < 0 {
+		reject(fmt.Errorf("restore time to live must not be negative, got %d", ttl))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The go-redis helpers do not support the ABSTTL, IDLETIME and FREQ
+		// options.
+		args := []any{"restore", key, ttl, value}
+		if opts.Replace {
+			args = append(args, "REPLACE")
+		}
+		if opts.AbsTTL {
+			args = append(args, "ABSTTL")
+		}
+		if opts.IdleTime != nil {
+			args = append(args, "IDLETIME", *opts.IdleTime)
+		}
+		if opts.Freq != nil {
+			args = append(args, "FREQ", *opts.Freq)
+		}
+
+		status, err := c.redisClient.Do(ctx, args...).Text()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(status)
+	}()
+
+	return promise
+}
+
+// Lpush inserts all the specified values at the head of the list stored
+// at `key`. If `key` does not exist, it is created as empty list before
+// performing the push operations. When `key` holds a value that is not
+// a list, and error is returned.
+func (c *Client) Lpush(key string, values ...any) *sobek.Promise {
+	values, params := splitCommandParams(values)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, values...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		listLength, err := c.redisClient.LPush(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(listLength)
+	}()
+
+	return promise
+}
+
+// Rpush inserts all the specified values at the tail of the list stored
+// at `key`. If `key` does not exist, it is created as empty list before
+// performing the push operations.
+func (c *Client) Rpush(key string, values ...any) *sobek.Promise {
+	values, params := splitCommandParams(values)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, values...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		listLength, err := c.redisClient.RPush(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(listLength)
+	}()
+
+	return promise
+}
+
+// Lpop removes and returns the first element of the list stored at `key`.
+//
+// An optional `count` can be passed before the parameters object, in which
+// case up to `count` elements are removed, and returned as an array.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Lpop(key string, args ...any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	count, withCount, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if count < 0 {
+		reject(fmt.Errorf("lpop count must not be negative, got %d", count))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		var value any
+		if withCount {
+			value, err = c.redisClient.LPopCount(ctx, key, int(count)).Result()
+		} else {
+			value, err = c.redisClient.LPop(ctx, key).Result()
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Rpop removes and returns the last element of the list stored at `key`.
+//
+// An optional `count` can be passed before the parameters object, in which
+// case up to `count` elements are removed, and returned as an array.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Rpop(key string, args ...any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	count, withCount, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if count < 0 {
+		reject(fmt.Errorf("rpop count must not be negative, got %d", count))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		var value any
+		if withCount {
+			value, err = c.redisClient.RPopCount(ctx, key, int(count)).Result()
+		} else {
+			value, err = c.redisClient.RPop(ctx, key).Result()
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Lrange returns the specified elements of the list stored at `key`. The
+// offsets start and stop are zero-based indexes. These offsets can be
+// negative numbers, where they indicate offsets starting at the end of
+// the list.
+func (c *Client) Lrange(key string, start, stop int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		values, err := c.redisClient.LRange(ctx, key, start, stop).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(values)
+	}()
+
+	return promise
+}
+
+// Lindex returns the specified element of the list stored at `key`.
+// The index is zero-based. Negative indices can be used to designate
+// elements starting at the tail of the list.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Lindex(key string, index int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.LIndex(ctx, key, index).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Lset sets the list element at `index` to `element`.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Lset(key string, index int64, element string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.LSet(ctx, key, index, element).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Lrem removes the first `count` occurrences of `value` from the list stored
+// at `key`. If `count` is positive, elements are removed from the beginning of the list.
+// If `count` is negative, elements are removed from the end of the list.
+// If `count` is zero, all elements matching `value` are removed.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Lrem(key string, count int64, value string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.LRem(ctx, key, count, value).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// Llen returns the length of the list stored at `key`. If `key`
+// does not exist, it is interpreted as an empty list and 0 is returned.
+//
+// If the list does not exist, this command rejects the promise with an error.
+func (c *Client) Llen(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		length, err := c.redisClient.LLen(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(length)
+	}()
+
+	return promise
+}
+
+// Lpushx inserts the specified values at the head of the list stored at
+// `key`, only if `key` already exists and holds a list.
+func (c *Client) Lpushx(key string, values ...any) *sobek.Promise {
+	values, params := splitCommandParams(values)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, values...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		listLength, err := c.redisClient.LPushX(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(listLength)
+	}()
+
+	return promise
+}
+
+// Rpushx inserts the specified values at the tail of the list stored at
+// `key`, only if `key` already exists and holds a list.
+func (c *Client) Rpushx(key string, values ...any) *sobek.Promise {
+	values, params := splitCommandParams(values)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, values...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		listLength, err := c.redisClient.RPushX(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(listLength)
+	}()
+
+	return promise
+}
+
+// Linsert inserts `element` in the list stored at `key`, either before
+// or after the first occurrence of `pivot`, as indicated by `position`
+// (BEFORE or AFTER).
+//
+// The promise resolves to the length of the list after the insertion, to
+// -1 if `pivot` was not found, or to 0 if the list does not exist.
+func (c *Client) Linsert(key, position string, pivot, element, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(2, pivot, element); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		listLength, err := c.redisClient.LInsert(ctx, key, position, pivot, element).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(listLength)
+	}()
+
+	return promise
+}
+
+// Ltrim trims the list stored at `key`, so that it only contains the
+// elements between the `start` and `stop` zero-based indexes. As with
+// Lrange, these can be negative, indicating offsets from the end of the
+// list.
+func (c *Client) Ltrim(key string, start, stop int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.LTrim(ctx, key, start, stop).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// lposOptions holds the options of the Lpos method.
+type lposOptions struct {
+	// Rank is the rank of the first match to return, negative ranks
+	// looking for matches from the tail of the list.
+	Rank int64 `json:"rank,omitempty"`
+
+	// Count is the number of matches to return, zero meaning all of them.
+	Count *int64 `json:"count,omitempty"`
+
+	// MaxLen is the maximum number of elements to compare, zero meaning
+	// all of them.
+	MaxLen int64 `json:"maxLen,omitempty"`
+}
+
+// Lpos returns the index of `element` in the list stored at `key`.
+//
+// The optional `options` object accepts a `rank`, a `count` and a `maxLen`
+// option, as described by the LPOS command. When `count` is set, the
+// promise resolves to an array of the matching indexes; otherwise, it
+// resolves to the index of the match, or to null if there is none.
+func (c *Client) Lpos(key string, element, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &lposOptions{}
+	if err := decodeCommandOptions("lpos", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if opts.Count != nil && *opts.Count < 0 {
+		reject(fmt.Errorf("invalid lpos options; reason: count must not be negative, got %d", *opts.Count))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, element); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		args := redis.LPosArgs{Rank: opts.Rank, MaxLen: opts.MaxLen}
+		value := fmt.Sprint(element)
+
+		if opts.Count != nil {
+			indexes, err := c.redisClient.LPosCount(ctx, key, value, *opts.Count, args).Result()
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			resolve(indexes)
+			return
+		}
+
+		index, err := c.redisClient.LPos(ctx, key, value, args).Result()
+		if errors.Is(err, redis.Nil) {
+			resolve(nil)
+			return
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(index)
+	}()
+
+	return promise
+}
+
+// Lmove atomically removes an element from the `whereFrom` side (LEFT or
+// RIGHT) of the list stored at `source`, pushes it to the `whereTo` side
+// of the list stored at `destination`, and returns it.
+//
+// If the source list does not exist, this command rejects the promise with
+// an error.
+func (c *Client) Lmove(source, destination, whereFrom, whereTo string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.LMove(ctx, source, destination, whereFrom, whereTo).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Lmpop removes up to `count` elements from the `direction` side (LEFT
+// or RIGHT) of the first non-empty list among `keys`, which is either a
+// single key, or an array of keys. The optional `count` defaults to 1.
+//
+// The promise resolves to an object of the form `{ key, values }`, or to
+// null if all the lists are empty.
+func (c *Client) Lmpop(keys any, direction string, args ...any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	count, withCount, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if !withCount {
+		count = 1
+	}
+
+	if count <= 0 {
+		reject(fmt.Errorf("lmpop count must be positive, got %d", count))
+		return promise
+	}
+
+	keyArgs, err := splitKeys(keys)
+	if err != nil {
+		reject(fmt.Errorf("invalid lmpop keys; reason: %w", err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		key, values, err := c.redisClient.LMPop(ctx, direction, count, toStrings(keyArgs)...).Result()
+		if errors.Is(err, redis.Nil) {
+			resolve(nil)
+			return
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(map[string]any{"key": key, "values": values})
+	}()
+
+	return promise
+}
+
+// Hset sets the specified field in the hash stored at `key` to `value`.
+// If the `key` does not exist, a new key holding a hash is created.
+// If `field` already exists in the hash, it is overwritten.
+//
+// Multiple fields can be set at once by passing an object mapping them to
+// their values, instead of a field and a value: `hset(key, { a: 1, b: 2 })`.
+// The promise resolves to the number of fields that were added.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hset(key string, args ...any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	var (
+		values []any
+		params any
+	)
+	if fields, ok := firstArg(args).(map[string]any); ok {
+		if len(args) > 2 {
+			reject(fmt.Errorf("hset expects an object of fields and values, and parameters, got %d arguments", len(args)+1))
+			return promise
+		}
+
+		fieldValues, err := c.fieldValues(1, fields)
+		if err != nil {
+			reject(err)
+			return promise
+		}
+
+		values, params = fieldValues, firstArg(args[1:])
+	} else {
+		values, params = splitCommandParams(args)
+		if len(values) != 2 {
+			reject(errors.New("hset expects a field and a value, or an object of fields and values"))
+			return promise
+		}
+
+		if err := c.isSupportedType(1, values...); err != nil {
+			reject(err)
+			return promise
+		}
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.HSet(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// Hsetnx sets the specified field in the hash stored at `key` to `value`,
+// only if `field` does not yet exist. If `key` does not exist, a new key
+// holding a hash is created. If `field` already exists, this operation
+// has no effect.
+func (c *Client) Hsetnx(key, field, value string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ok, err := c.redisClient.HSetNX(ctx, key, field, value).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(ok)
+	}()
+
+	return promise
+}
+
+// Hget returns the value associated with `field` in the hash stored at `key`.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hget(key, field string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.HGet(ctx, key, field).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Hdel deletes the specified fields from the hash stored at `key`.
+func (c *Client) Hdel(key string, fields ...any) *sobek.Promise {
+	fields, params := splitCommandParams(fields)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, fields...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.HDel(ctx, key, toStrings(fields)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// hgetallOptions holds the options of the Hgetall method.
+type hgetallOptions struct {
+	// AsMap resolves the fields and values to a JS Map rather than to an
+	// object.
+	AsMap bool `json:"asMap,omitempty"`
+}
+
+// Hgetall returns all fields and values of the hash stored at `key`.
+//
+// The optional `options` object accepts an `asMap` option which, when true,
+// resolves the promise to a JS Map, ordered by field, rather than to an
+// object.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hgetall(key string, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &hgetallOptions{}
+	if err := decodeCommandOptions("hgetall", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	// A JS Map can only be instantiated from the event loop.
+	var enqueue func(func() error)
+	if opts.AsMap {
+		enqueue = c.vu.RegisterCallback()
+	}
+
+	go func() {
+		defer done()
+
+		hashMap, err := c.redisClient.HGetAll(ctx, key).Result()
+		if !opts.AsMap {
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			resolve(hashMap)
+			return
+		}
+
+		enqueue(func() error {
+			if err != nil {
+				reject(err)
+				return nil
+			}
+
+			jsMap, err := newJSMap(c.vu.Runtime(), hashMap)
+			if err != nil {
+				reject(err)
+				return nil
+			}
+
+			resolve(jsMap)
+			return nil
+		})
+	}()
+
+	return promise
+}
+
+// newJSMap returns a JS Map holding the provided fields and values,
+// ordered by field. It must be called from the event loop.
+func newJSMap(rt *sobek.Runtime, fields map[string]string) (*sobek.Object, error) {
+	jsMap, err := rt.New(rt.Get("Map"))
+	if err != nil {
+		return nil, err
+	}
+
+	set, ok := sobek.AssertFunction(jsMap.Get("set"))
+	if !ok {
+		return nil, errors.New("unable to instantiate a JS Map")
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if _, err := set(jsMap, rt.ToValue(key), rt.ToValue(fields[key])); err != nil {
+			return nil, err
+		}
+	}
+
+	return jsMap, nil
+}
+
+// Hkeys returns all fields of the hash stored at `key`.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hkeys(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		keys, err := c.redisClient.HKeys(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(keys)
+	}()
+
+	return promise
+}
+
+// Hvals returns all values of the hash stored at `key`.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hvals(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		values, err := c.redisClient.HVals(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(values)
+	}()
+
+	return promise
+}
+
+// Hlen returns the number of fields in the hash stored at `key`.
+//
+// If the hash does not exist, this command rejects the promise with an error.
+func (c *Client) Hlen(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.HLen(ctx, key).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// Hincrby increments the integer value of `field` in the hash stored at `key`
+// by `increment`. If `key` does not exist, a new key holding a hash is created.
+// If `field` does not exist the value is set to 0 before the operation is
+// set to 0 before the operation is performed.
+func (c *Client) Hincrby(key, field string, increment int64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		newValue, err := c.redisClient.HIncrBy(ctx, key, field, increment).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(newValue)
+	}()
+
+	return promise
+}
+
+// Hmget returns the values associated with the specified fields in the
+// hash stored at `key`, null standing for the fields which do not exist.
+func (c *Client) Hmget(key string, fields ...any) *sobek.Promise {
+	fields, params := splitCommandParams(fields)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, fields...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		values, err := c.redisClient.HMGet(ctx, key, toStrings(fields)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(values)
+	}()
+
+	return promise
+}
+
+// Hexists returns whether `field` exists in the hash stored at `key`.
+func (c *Client) Hexists(key, field string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		exists, err := c.redisClient.HExists(ctx, key, field).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(exists)
+	}()
+
+	return promise
+}
+
+// Hincrbyfloat increments the floating point number stored at `field` in
+// the hash stored at `key` by `increment`, and returns the resulting value.
+// If `field` does not exist, it is set to 0 before performing the operation.
+func (c *Client) Hincrbyfloat(key, field string, increment float64, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		value, err := c.redisClient.HIncrByFloat(ctx, key, field, increment).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(value)
+	}()
+
+	return promise
+}
+
+// Hstrlen returns the length of the value associated with `field` in the
+// hash stored at `key`, or 0 if it does not exist.
+func (c *Client) Hstrlen(key, field string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		length, err := c.redisClient.HStrLen(ctx, key, field).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(length)
+	}()
+
+	return promise
+}
+
+// hrandfieldOptions holds the options of the Hrandfield method.
+type hrandfieldOptions struct {
+	// Count is the number of fields to return. A negative count allows
+	// the same field to be returned multiple times.
+	Count *int64 `json:"count,omitempty"`
+
+	// WithValues returns the fields along with their values.
+	WithValues bool `json:"withValues,omitempty"`
+}
+
+// Hrandfield returns a random field of the hash stored at `key`, or null
+// if it does not exist.
+//
+// The optional `options` object accepts a `count` and a `withValues`
+// option. When `count` is set, the promise resolves to an array of fields
+// or, when `withValues` is set too, to an array of `{ field, value }`
+// objects.
+func (c *Client) Hrandfield(key string, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &hrandfieldOptions{}
+	if err := decodeCommandOptions("hrandfield", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if opts.WithValues && opts.Count == nil {
+		reject(errors.New("invalid hrandfield options; reason: withValues requires a count"))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		switch {
+		case opts.WithValues:
+			pairs, err := c.redisClient.HRandFieldWithValues(ctx, key, int(*opts.Count)).Result()
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			fields := make([]any, 0, len(pairs))
+			for _, pair := range pairs {
+				fields = append(fields, map[string]any{"field": pair.Key, "value": pair.Value})
+			}
+			resolve(fields)
+		case opts.Count != nil:
+			fields, err := c.redisClient.HRandField(ctx, key, int(*opts.Count)).Result()
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			resolve(fields)
+		default:
+			// The go-redis helper always passes a count, which changes the
+			// type of the reply.
+			field, err := c.redisClient.Do(ctx, "hrandfield", key).Text()
+			if errors.Is(err, redis.Nil) {
+				resolve(nil)
+				return
+			}
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			resolve(field)
+		}
+	}()
+
+	return promise
+}
+
+// hexpireOptions holds the options of the Hexpire and Hpexpire methods.
+type hexpireOptions struct {
+	// Condition is the NX, XX, GT or LT condition the current expiration
+	// time of the fields must meet for it to be set.
+	Condition string `json:"condition,omitempty"`
+}
+
+// Hexpire sets the time to live, in seconds, of the provided `fields` of
+// the hash stored at `key`, which is either a single field, or an array of
+// fields.
+//
+// The optional `options` object accepts a `condition` option (NX, XX, GT or
+// LT). The promise resolves to an array holding, for each field, -2 if it
+// does not exist, 0 if the condition was not met, 1 if its expiration time
+// was set, or 2 if it was deleted.
+func (c *Client) Hexpire(key string, seconds int64, fields, options, params any) *sobek.Promise {
+	return c.hexpire("hexpire", key, time.Duration(seconds)*time.Second, fields, options, params,
+		redis.UniversalClient.HExpireWithArgs)
+}
+
+// Hpexpire is like Hexpire, but the time to live is in milliseconds.
+func (c *Client) Hpexpire(key string, milliseconds int64, fields, options, params any) *sobek.Promise {
+	return c.hexpire("hpexpire", key, time.Duration(milliseconds)*time.Millisecond, fields, options, params,
+		redis.UniversalClient.HPExpireWithArgs)
+}
+
+// hexpireMethod is the type of the go-redis HExpireWithArgs and
+// HPExpireWithArgs methods.
+type hexpireMethod func(
+	redis.UniversalClient, context.Context, string, time.Duration, redis.HExpireArgs, ...string,
+) *redis.IntSliceCmd
+
+// hexpire runs the named HEXPIRE or HPEXPIRE command, using the provided
+// go-redis method.
+func (c *Client) hexpire(
+	command, key string, expiration time.Duration, fields, options, params any,
+	method hexpireMethod,
+) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &hexpireOptions{}
+	if err := decodeCommandOptions(command, options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	var args redis.HExpireArgs
+	switch strings.ToUpper(opts.Condition) {
+	case "":
+	case "NX":
+		args.NX = true
+	case "XX":
+		args.XX = true
+	case "GT":
+		args.GT = true
+	case "LT":
+		args.LT = true
+	default:
+		reject(fmt.Errorf("invalid %s options; reason: unknown condition %q", command, opts.Condition))
+		return promise
+	}
+
+	fieldArgs, err := splitFields(fields)
+	if err != nil {
+		reject(fmt.Errorf("invalid %s fields; reason: %w", command, err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		results, err := method(c.redisClient, ctx, key, expiration, args, toStrings(fieldArgs)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// Httl returns the remaining time to live, in seconds, of the provided
+// `fields` of the hash stored at `key`, which is either a single field, or
+// an array of fields.
+//
+// The promise resolves to an array holding, for each field, its time to
+// live, -2 if it does not exist, or -1 if it has no expiration time.
+func (c *Client) Httl(key string, fields, params any) *sobek.Promise {
+	return c.hashFieldsCommand("httl", key, fields, params, redis.UniversalClient.HTTL)
+}
+
+// Hpersist removes the expiration time of the provided `fields` of the hash
+// stored at `key`, which is either a single field, or an array of fields.
+//
+// The promise resolves to an array holding, for each field, 1 if its
+// expiration time was removed, -2 if it does not exist, or -1 if it has no
+// expiration time.
+func (c *Client) Hpersist(key string, fields, params any) *sobek.Promise {
+	return c.hashFieldsCommand("hpersist", key, fields, params, redis.UniversalClient.HPersist)
+}
+
+// hashFieldsCommand runs the named hash fields command, using the provided
+// go-redis method.
+func (c *Client) hashFieldsCommand(
+	command, key string, fields, params any,
+	method func(redis.UniversalClient, context.Context, string, ...string) *redis.IntSliceCmd,
+) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	fieldArgs, err := splitFields(fields)
+	if err != nil {
+		reject(fmt.Errorf("invalid %s fields; reason: %w", command, err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		results, err := method(c.redisClient, ctx, key, toStrings(fieldArgs)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// fieldExpirationOptions holds the expiration options shared by the
+// Hgetex, Hsetex and Getex methods, at most one of which can be set.
+type fieldExpirationOptions struct {
+	// Ex and Px set the time to live of the fields, in seconds and in
+	// milliseconds respectively.
+	Ex int64 `json:"ex,omitempty"`
+	Px int64 `json:"px,omitempty"`
+
+	// ExAt and PxAt set the Unix time at which the fields expire, in
+	// seconds and in milliseconds respectively.
+	ExAt int64 `json:"exAt,omitempty"`
+	PxAt int64 `json:"pxAt,omitempty"`
+}
+
+// args returns the arguments of the expiration option which is set, if
+// any, along with the provided flag, if set.
+func (o fieldExpirationOptions) args(flag string, flagSet bool) ([]any, error) {
+	var args []any
+	for _, option := range []struct {
+		name  string
+		value int64
+	}{{"EX", o.Ex}, {"PX", o.Px}, {"EXAT", o.ExAt}, {"PXAT", o.PxAt}} {
+		if option.value < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", strings.ToLower(option.name), option.value)
+		}
+		if option.value > 0 {
+			args = append(args, option.name, option.value)
+		}
+	}
+
+	if flagSet {
+		args = append(args, flag)
+	}
+
+	if len(args) > 2 || (flagSet && len(args) > 1) {
+		return nil, errors.New("at most one expiration option can be set")
+	}
+
+	return args, nil
+}
+
+// hgetexOptions holds the options of the Hgetex method.
+type hgetexOptions struct {
+	fieldExpirationOptions
+
+	// Persist removes the expiration time of the fields.
+	Persist bool `json:"persist,omitempty"`
+}
+
+// Hgetex returns the values of the provided `fields` of the hash stored at
+// `key`, which is either a single field, or an array of fields, null
+// standing for the fields which do not exist.
+//
+// The optional `options` object sets or removes the expiration time of the
+// fields, using one of its `ex`, `px`, `exAt`, `pxAt` or `persist` options.
+func (c *Client) Hgetex(key string, fields, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &hgetexOptions{}
+	if err := decodeCommandOptions("hgetex", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	expiration, err := opts.args("PERSIST", opts.Persist)
+	if err != nil {
+		reject(fmt.Errorf("invalid hgetex options; reason: %w", err))
+		return promise
+	}
+
+	fieldArgs, err := splitFields(fields)
+	if err != nil {
+		reject(fmt.Errorf("invalid hgetex fields; reason: %w", err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The go-redis helper replies with empty strings, rather than null,
+		// for the fields which do not exist.
+		args := append([]any{"hgetex", key}, expiration...)
+		args = append(args, "FIELDS", len(fieldArgs))
+		args = append(args, fieldArgs...)
+
+		values, err := c.redisClient.Do(ctx, args...).Slice()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(values)
+	}()
+
+	return promise
+}
+
+// hsetexOptions holds the options of the Hsetex method.
+type hsetexOptions struct {
+	fieldExpirationOptions
+
+	// KeepTTL retains the expiration time of the fields.
+	KeepTTL bool `json:"keepTtl,omitempty"`
+
+	// Condition is either FNX, to only set the fields if none of them
+	// exist, or FXX, to only set them if they all exist.
+	Condition string `json:"condition,omitempty"`
+}
+
+// Hsetex sets the fields of the hash stored at `key` to the values of the
+// provided object, along with their expiration time.
+//
+// The optional `options` object accepts one of the `ex`, `px`, `exAt`,
+// `pxAt` or `keepTtl` expiration options, and a `condition` option (FNX or
+// FXX). The promise resolves to whether the fields were set.
+func (c *Client) Hsetex(key string, fields, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &hsetexOptions{}
+	if err := decodeCommandOptions("hsetex", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	expiration, err := opts.args("KEEPTTL", opts.KeepTTL)
+	if err != nil {
+		reject(fmt.Errorf("invalid hsetex options; reason: %w", err))
+		return promise
+	}
+
+	condition := strings.ToUpper(opts.Condition)
+	if condition != "" && condition != "FNX" && condition != "FXX" {
+		reject(fmt.Errorf("invalid hsetex options; reason: unknown condition %q", opts.Condition))
+		return promise
+	}
+
+	obj, ok := fields.(map[string]any)
+	if !ok {
+		reject(fmt.Errorf("invalid hsetex fields type: %T; expected object", fields))
+		return promise
+	}
+
+	values, err := c.fieldValues(1, obj)
+	if err != nil {
+		reject(fmt.Errorf("invalid hsetex fields; reason: %w", err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		args := []any{"hsetex", key}
+		if condition != "" {
+			args = append(args, condition)
+		}
+		args = append(args, expiration...)
+		args = append(args, "FIELDS", len(obj))
+		args = append(args, values...)
+
+		n, err := c.redisClient.Do(ctx, args...).Int()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n == 1)
+	}()
+
+	return promise
+}
+
+// Sadd adds the specified members to the set stored at key.
+// Specified members that are already a member of this set are ignored.
+// If key does not exist, a new set is created before adding the specified members.
+func (c *Client) Sadd(key string, members ...any) *sobek.Promise {
+	members, params := splitCommandParams(members)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, members...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.SAdd(ctx, key, members...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// Srem removes the specified members from the set stored at key.
+// Specified members that are not a member of this set are ignored.
+// If key does not exist, it is treated as an empty set and this command returns 0.
+func (c *Client) Srem(key string, members ...any) *sobek.Promise {
+	members, params := splitCommandParams(members)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, members...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.SRem(ctx, key, members...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// Sismember returns if member is a member of the set stored at key.
+func (c *Client) Sismember(key string, member, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, member); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		ok, err := c.redisClient.SIsMember(ctx, key, member).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(ok)
+	}()
+
+	return promise
+}
+
+// Smembers returns all members of the set stored at key.
+func (c *Client) Smembers(key string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		values, err := c.redisClient.HVals(c.vu.Context(), key).Result()
+		defer done()
+
+		members, err := c.redisClient.SMembers(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(values)
+		resolve(members)
 	}()
 
 	return promise
 }
 
-// Hlen returns the number of fields in the hash stored at `key`.
+// Srandmember returns a random element from the set value stored at key.
 //
-// If the hash does not exist, this command rejects the promise with an error.
-func (c *Client) Hlen(key string) *sobek.Promise {
+// An optional `count` can be passed before the parameters object, in which
+// case up to `count` distinct elements are returned, as an array. A negative
+// count allows the same element to be returned multiple times.
+//
+// If the set does not exist, the promise is rejected with an error, unless
+// a count is passed, in which case it resolves to an empty array.
+func (c *Client) Srandmember(key string, args ...any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	count, withCount, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.HLen(c.vu.Context(), key).Result()
+		defer done()
+
+		var value any
+		if withCount {
+			value, err = c.redisClient.SRandMemberN(ctx, key, count).Result()
+		} else {
+			value, err = c.redisClient.SRandMember(ctx, key).Result()
+		}
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(n)
+		resolve(value)
 	}()
 
 	return promise
 }
 
-// Hincrby increments the integer value of `field` in the hash stored at `key`
-// by `increment`. If `key` does not exist, a new key holding a hash is created.
-// If `field` does not exist the value is set to 0 before the operation is
-// set to 0 before the operation is performed.
-func (c *Client) Hincrby(key, field string, increment int64) *sobek.Promise {
+// Spop removes and returns a random element from the set value stored at key.
+//
+// An optional `count` can be passed before the parameters object, in which
+// case up to `count` elements are removed, and returned as an array.
+//
+// If the set does not exist, the promise is rejected with an error, unless
+// a count is passed, in which case it resolves to an empty array.
+func (c *Client) Spop(key string, args ...any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	count, withCount, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if count < 0 {
+		reject(fmt.Errorf("spop count must not be negative, got %d", count))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		newValue, err := c.redisClient.HIncrBy(c.vu.Context(), key, field, increment).Result()
+		defer done()
+
+		var value any
+		if withCount {
+			value, err = c.redisClient.SPopN(ctx, key, count).Result()
+		} else {
+			value, err = c.redisClient.SPop(ctx, key).Result()
+		}
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(newValue)
+		resolve(value)
 	}()
 
 	return promise
 }
 
-// Sadd adds the specified members to the set stored at key.
-// Specified members that are already a member of this set are ignored.
-// If key does not exist, a new set is created before adding the specified members.
-func (c *Client) Sadd(key string, members ...any) *sobek.Promise {
+// Scard returns the number of members of the set stored at `key`, or 0
+// if it does not exist.
+func (c *Client) Scard(key string, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -866,13 +3897,16 @@ func (c *Client) Sadd(key string, members ...any) *sobek.Promise {
 		return promise
 	}
 
-	if err := c.isSupportedType(1, members...); err != nil {
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		n, err := c.redisClient.SAdd(c.vu.Context(), key, members...).Result()
+		defer done()
+
+		n, err := c.redisClient.SCard(ctx, key).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -884,10 +3918,11 @@ func (c *Client) Sadd(key string, members ...any) *sobek.Promise {
 	return promise
 }
 
-// Srem removes the specified members from the set stored at key.
-// Specified members that are not a member of this set are ignored.
-// If key does not exist, it is treated as an empty set and this command returns 0.
-func (c *Client) Srem(key string, members ...any) *sobek.Promise {
+// Smismember returns, for each of the provided members, whether it is a
+// member of the set stored at `key`, as an array of booleans.
+func (c *Client) Smismember(key string, members ...any) *sobek.Promise {
+	members, params := splitCommandParams(members)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -900,21 +3935,31 @@ func (c *Client) Srem(key string, members ...any) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		n, err := c.redisClient.SRem(c.vu.Context(), key, members...).Result()
+		defer done()
+
+		memberships, err := c.redisClient.SMIsMember(ctx, key, members...).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(n)
+		resolve(memberships)
 	}()
 
 	return promise
 }
 
-// Sismember returns if member is a member of the set stored at key.
-func (c *Client) Sismember(key string, member any) *sobek.Promise {
+// Smove moves `member` from the set stored at `source` to the set stored
+// at `destination`, and returns whether it was moved, which it is not if
+// it is not a member of the source set.
+func (c *Client) Smove(source, destination string, member, params any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -922,26 +3967,57 @@ func (c *Client) Sismember(key string, member any) *sobek.Promise {
 		return promise
 	}
 
-	if err := c.isSupportedType(1, member); err != nil {
+	if err := c.isSupportedType(2, member); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
 		reject(err)
 		return promise
 	}
 
 	go func() {
-		ok, err := c.redisClient.SIsMember(c.vu.Context(), key, member).Result()
+		defer done()
+
+		moved, err := c.redisClient.SMove(ctx, source, destination, member).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(ok)
+		resolve(moved)
 	}()
 
 	return promise
 }
 
-// Smembers returns all members of the set stored at key.
-func (c *Client) Smembers(key string) *sobek.Promise {
+// Sinter returns the members of the intersection of the sets stored at the
+// provided keys, keys which do not exist being considered empty sets.
+func (c *Client) Sinter(keys ...any) *sobek.Promise {
+	return c.setOperation(redis.UniversalClient.SInter, keys)
+}
+
+// Sunion returns the members of the union of the sets stored at the
+// provided keys.
+func (c *Client) Sunion(keys ...any) *sobek.Promise {
+	return c.setOperation(redis.UniversalClient.SUnion, keys)
+}
+
+// Sdiff returns the members of the set stored at the first of the provided
+// keys, which are not members of the sets stored at the other ones.
+func (c *Client) Sdiff(keys ...any) *sobek.Promise {
+	return c.setOperation(redis.UniversalClient.SDiff, keys)
+}
+
+// setOperation runs the SINTER, SUNION or SDIFF command, using the provided
+// go-redis method.
+func (c *Client) setOperation(
+	method func(redis.UniversalClient, context.Context, ...string) *redis.StringSliceCmd, keys []any,
+) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -949,8 +4025,21 @@ func (c *Client) Smembers(key string) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(0, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		members, err := c.redisClient.SMembers(c.vu.Context(), key).Result()
+		defer done()
+
+		members, err := method(c.redisClient, ctx, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -962,10 +4051,32 @@ func (c *Client) Smembers(key string) *sobek.Promise {
 	return promise
 }
 
-// Srandmember returns a random element from the set value stored at key.
-//
-// If the set does not exist, the promise is rejected with an error.
-func (c *Client) Srandmember(key string) *sobek.Promise {
+// Sinterstore stores the intersection of the sets stored at the provided
+// keys in `destination`, replacing it if it exists, and returns the number
+// of members of the resulting set.
+func (c *Client) Sinterstore(destination string, keys ...any) *sobek.Promise {
+	return c.setOperationStore(redis.UniversalClient.SInterStore, destination, keys)
+}
+
+// Sunionstore is like Sinterstore, for the union of the sets.
+func (c *Client) Sunionstore(destination string, keys ...any) *sobek.Promise {
+	return c.setOperationStore(redis.UniversalClient.SUnionStore, destination, keys)
+}
+
+// Sdiffstore is like Sinterstore, for the difference of the sets.
+func (c *Client) Sdiffstore(destination string, keys ...any) *sobek.Promise {
+	return c.setOperationStore(redis.UniversalClient.SDiffStore, destination, keys)
+}
+
+// setOperationStore runs the SINTERSTORE, SUNIONSTORE or SDIFFSTORE
+// command, using the provided go-redis method.
+func (c *Client) setOperationStore(
+	method func(redis.UniversalClient, context.Context, string, ...string) *redis.IntCmd,
+	destination string,
+	keys []any,
+) *sobek.Promise {
+	keys, params := splitCommandParams(keys)
+
 	promise, resolve, reject := promises.New(c.vu)
 
 	if err := c.connect(); err != nil {
@@ -973,38 +4084,78 @@ func (c *Client) Srandmember(key string) *sobek.Promise {
 		return promise
 	}
 
+	if err := c.isSupportedType(1, keys...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		element, err := c.redisClient.SRandMember(c.vu.Context(), key).Result()
+		defer done()
+
+		n, err := method(c.redisClient, ctx, destination, toStrings(keys)...).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(element)
+		resolve(n)
 	}()
 
 	return promise
 }
 
-// Spop removes and returns a random element from the set value stored at key.
+// Sintercard returns the number of members of the intersection of the sets
+// stored at `keys`, which is either a single key, or an array of keys.
 //
-// If the set does not exist, the promise is rejected with an error.
-func (c *Client) Spop(key string) *sobek.Promise {
+// An optional `limit` can be passed before the parameters object, in which
+// case the count stops at `limit` members. A limit of 0 means no limit.
+func (c *Client) Sintercard(keys any, args ...any) *sobek.Promise {
 	promise, resolve, reject := promises.New(c.vu)
 
+	limit, _, params, err := splitCount(args)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if limit < 0 {
+		reject(fmt.Errorf("sintercard limit must not be negative, got %d", limit))
+		return promise
+	}
+
+	keyArgs, err := splitKeys(keys)
+	if err != nil {
+		reject(fmt.Errorf("invalid sintercard keys; reason: %w", err))
+		return promise
+	}
+
 	if err := c.connect(); err != nil {
 		reject(err)
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		element, err := c.redisClient.SPop(c.vu.Context(), key).Result()
+		defer done()
+
+		n, err := c.redisClient.SInterCard(ctx, limit, toStrings(keyArgs)...).Result()
 		if err != nil {
 			reject(err)
 			return
 		}
 
-		resolve(element)
+		resolve(n)
 	}()
 
 	return promise
@@ -1012,6 +4163,8 @@ func (c *Client) Spop(key string) *sobek.Promise {
 
 // SendCommand sends a command to the redis server.
 func (c *Client) SendCommand(command string, args ...any) *sobek.Promise {
+	args, params := splitCommandParams(args)
+
 	doArgs := make([]any, 0, 1+len(args))
 	doArgs = append(doArgs, command)
 	doArgs = append(doArgs, args...)
@@ -1028,8 +4181,16 @@ func (c *Client) SendCommand(command string, args ...any) *sobek.Promise {
 		return promise
 	}
 
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
 	go func() {
-		cmd, err := c.redisClient.Do(c.vu.Context(), doArgs...).Result()
+		defer done()
+
+		cmd, err := c.redisClient.Do(ctx, doArgs...).Result()
 		if err != nil {
 			reject(err)
 			return
@@ -1087,18 +4248,34 @@ func (c *Client) connect() error {
 		// See Pull Request's #17 [discussion] for more details.
 		//
 		// [discussion]: https://github.com/grafana/xk6-redis/pull/17#discussion_r1369707388
-		c.redisOptions.Dialer = c.upgradeDialerToTLS(vuState.Dialer, tlsCfg)
+		c.redisOptions.Dialer = c.upgradeDialerToTLS(c.countingDialer(vuState.Dialer), tlsCfg)
 	} else {
-		c.redisOptions.Dialer = vuState.Dialer.DialContext
+		c.redisOptions.Dialer = c.countingDialer(vuState.Dialer)
 	}
 
 	// Replace the internal redis client instance with a new
 	// one using our custom options.
 	c.redisClient = redis.NewUniversalClient(c.redisOptions)
+	c.addHooks(c.redisClient, vuState)
 
 	return nil
 }
 
+// addHooks adds the hooks of the Client to the provided redis client.
+func (c *Client) addHooks(client redis.UniversalClient, vuState *lib.State) {
+	// Hooks are called in the order they are added. The JS hooks are
+	// added first, so that the time spent in their callbacks is not
+	// accounted for in the commands' metrics and spans.
+	client.AddHook(&jsHooksHook{client: c})
+	if c.recorder != nil {
+		client.AddHook(&recordingHook{client: c, recorder: c.recorder})
+	}
+	client.AddHook(&metricsHook{client: c})
+	if c.tracing && vuState.TracerProvider != nil {
+		client.AddHook(newTracingHook(vuState.TracerProvider, c.redisOptions.Addrs))
+	}
+}
+
 // IsConnected returns true if the client is connected to redis.
 func (c *Client) IsConnected() bool {
 	return c.redisClient != nil
@@ -1132,9 +4309,357 @@ func (c *Client) isSupportedType(offset int, args ...any) error {
 	return nil
 }
 
+// commandParams holds the optional parameters which can be passed as the
+// last argument of any command method.
+type commandParams struct {
+	// Tags are added to the metric samples emitted for the command.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// commandParamsKey is the context key holding the *commandParams of the
+// command the context relates to.
+type commandParamsKey struct{}
+
+// commandContext returns the context to execute a command with, carrying
+// the command's parameters, if any.
+//
+// The `params` argument is expected to be either nil, or the map
+// representation of the parameters object as exported from sobek.Runtime.
+//
+// If hooks were registered using `client.addHook`, the returned context
+// also carries the event loop callbacks needed to call them. Because these
+// need to be reserved from the event loop's thread, commandContext must be
+// called synchronously by the command methods, and the returned `done`
+// function must be called once the command has been processed.
+func (c *Client) commandContext(params any) (ctx context.Context, done func(), err error) {
+	ctx = c.vu.Context()
+
+	if params != nil {
+		obj, ok := params.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid command parameters type: %T; expected object", params)
+		}
+
+		jsonStr, err := json.Marshal(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to serialize command parameters to JSON %w", err)
+		}
+
+		// As for the Client's options, unknown parameters produce an error.
+		decoder := json.NewDecoder(bytes.NewReader(jsonStr))
+		decoder.DisallowUnknownFields()
+
+		cp := &commandParams{}
+		if err := decoder.Decode(cp); err != nil {
+			return nil, nil, fmt.Errorf("invalid command parameters; reason: %w", err)
+		}
+
+		ctx = context.WithValue(ctx, commandParamsKey{}, cp)
+	}
+
+	if c.recorder != nil {
+		ctx = context.WithValue(ctx, recordCallKey{}, &atomic.Bool{})
+	}
+
+	if len(c.hooks) == 0 {
+		return ctx, func() {}, nil
+	}
+
+	call := c.newHookCall()
+
+	return context.WithValue(ctx, hookCallKey{}, call), call.release, nil
+}
+
+// splitCommandParams separates the optional parameters object from the
+// variadic arguments of a command method, if it was passed as their last
+// element.
+func splitCommandParams(args []any) ([]any, any) {
+	if len(args) == 0 {
+		return args, nil
+	}
+
+	if params, ok := args[len(args)-1].(map[string]any); ok {
+		return args[:len(args)-1], params
+	}
+
+	return args, nil
+}
+
+// decodeCommandOptions decodes the options object of the named command
+// into `dst`, from its map representation as exported from sobek.Runtime.
+// A nil `options` leaves `dst` untouched.
+func decodeCommandOptions(name string, options, dst any) error {
+	if options == nil {
+		return nil
+	}
+
+	obj, ok := options.(map[string]any)
+	if !ok {
+		return fmt.Errorf("invalid %s options type: %T; expected object", name, options)
+	}
+
+	jsonStr, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("unable to serialize %s options to JSON %w", name, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonStr))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("invalid %s options; reason: %w", name, err)
+	}
+
+	return nil
+}
+
+// splitCount separates the optional count argument of a command method,
+// from its optional parameters object, both of which can be passed as its
+// last arguments.
+func splitCount(args []any) (count int64, withCount bool, params any, err error) {
+	args, params = splitCommandParams(args)
+
+	switch len(args) {
+	case 0:
+		return 0, false, params, nil
+	case 1:
+	default:
+		return 0, false, nil, fmt.Errorf("expected at most a count and parameters, got %d arguments", len(args)+1)
+	}
+
+	switch n := args[0].(type) {
+	case int64:
+		return n, true, params, nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), true, params, nil
+		}
+	}
+
+	return 0, false, nil, fmt.Errorf("invalid count: %v; expected an integer", args[0])
+}
+
+// firstArg returns the first of the provided arguments, or nil if there
+// is none.
+func firstArg(args []any) any {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return args[0]
+}
+
+// fieldValues returns the fields and values of the provided object as a
+// flat list of arguments, ordered by field. As for isSupportedType, the
+// values must be of a supported type, and errors indicate their position
+// with the provided offset.
+func (c *Client) fieldValues(offset int, fields map[string]any) ([]any, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("expected at least one field")
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	values := make([]any, 0, 2*len(fields))
+	for _, name := range names {
+		if err := c.isSupportedType(offset, fields[name]); err != nil {
+			return nil, fmt.Errorf("invalid value of field %q: %w", name, err)
+		}
+
+		values = append(values, name, fields[name])
+	}
+
+	return values, nil
+}
+
+// splitKeys returns the keys of a command method accepting either a single
+// key, or an array of keys.
+func splitKeys(keys any) ([]any, error) {
+	return splitStrings("key", keys)
+}
+
+// splitFields returns the fields of a hash command method accepting either
+// a single field, or an array of fields.
+func splitFields(fields any) ([]any, error) {
+	return splitStrings("field", fields)
+}
+
+// splitStrings returns the values of the named kind of a command method
+// argument, which is either a single string, or an array of strings.
+func splitStrings(kind string, values any) ([]any, error) {
+	switch values := values.(type) {
+	case string:
+		return []any{values}, nil
+	case []any:
+		if len(values) == 0 {
+			return nil, fmt.Errorf("expected at least one %s", kind)
+		}
+
+		for idx, value := range values {
+			if _, ok := value.(string); !ok {
+				return nil, fmt.Errorf("invalid %s type at index %d: %T; expected string", kind, idx, value)
+			}
+		}
+
+		return values, nil
+	default:
+		return nil, fmt.Errorf("invalid %ss type: %T; expected string or array", kind, values)
+	}
+}
+
+// toStrings converts arguments of a supported type (see isSupportedType)
+// to their string representation.
+func toStrings(args []any) []string {
+	strs := make([]string, 0, len(args))
+	for _, arg := range args {
+		strs = append(strs, fmt.Sprint(arg))
+	}
+
+	return strs
+}
+
 // DialContextFunc is a function that can be used to dial a connection to a redis server.
 type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
 
+// DialContext implements the lib.DialContexter interface.
+func (f DialContextFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// countingDialer returns a DialContextFunc that uses the provided dialer to
+// establish a connection, and wraps it in a countingConn, so that the traffic
+// it carries is accounted for in k6's data_sent and data_received metrics.
+//
+// The k6 [netext.Dialer] already counts the bytes going through the
+// connections it establishes, and reports them untagged at the end of each
+// iteration. To avoid counting the redis traffic twice, we unwrap the
+// connection it returns, and count the bytes ourselves instead.
+func (c *Client) countingDialer(dialer lib.DialContexter) DialContextFunc {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if nc, ok := conn.(*netext.Conn); ok {
+			conn = nc.Conn
+		}
+
+		return &countingConn{Conn: conn, traffic: c.traffic.counters(addr)}, nil
+	}
+}
+
+// countingConn wraps a net.Conn, and accumulates the bytes written to and
+// read from it in the traffic counters of the redis server's address.
+//
+// The accumulated bytes are emitted as data_sent and data_received metric
+// samples by the client's metrics hook, once per command, rather than on
+// each read and write.
+type countingConn struct {
+	net.Conn
+
+	traffic *addrTraffic
+}
+
+// Read implements the net.Conn interface.
+func (cc *countingConn) Read(b []byte) (int, error) {
+	n, err := cc.Conn.Read(b)
+	cc.traffic.received.Add(int64(n))
+
+	return n, err
+}
+
+// Write implements the net.Conn interface.
+func (cc *countingConn) Write(b []byte) (int, error) {
+	n, err := cc.Conn.Write(b)
+	cc.traffic.sent.Add(int64(n))
+
+	return n, err
+}
+
+// addrTraffic holds the bytes sent to and received from a redis server
+// which were not emitted as metric samples yet.
+type addrTraffic struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// clientTraffic holds the traffic counters of the redis servers a Client
+// is connected to, indexed by address.
+type clientTraffic struct {
+	mu    sync.Mutex
+	addrs map[string]*addrTraffic
+}
+
+// counters returns the traffic counters of the provided address.
+func (t *clientTraffic) counters(addr string) *addrTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.addrs == nil {
+		t.addrs = make(map[string]*addrTraffic)
+	}
+
+	counters, ok := t.addrs[addr]
+	if !ok {
+		counters = &addrTraffic{}
+		t.addrs[addr] = counters
+	}
+
+	return counters
+}
+
+// emitTraffic pushes the bytes accumulated by the client's connections
+// since the previous call as data_sent and data_received metric samples,
+// tagged with the address of the redis server they were exchanged with.
+func (c *Client) emitTraffic() {
+	state := c.vu.State()
+	if state == nil {
+		return
+	}
+
+	ctx := c.vu.Context()
+
+	c.traffic.mu.Lock()
+	defer c.traffic.mu.Unlock()
+
+	now := time.Now()
+	for addr, counters := range c.traffic.addrs {
+		sent, received := counters.sent.Swap(0), counters.received.Swap(0)
+		if sent == 0 && received == 0 {
+			continue
+		}
+
+		ctm := c.tagsAndMeta(ctx)
+		ctm.SetTag(addrTagName, addr)
+
+		sample := func(metric *metrics.Metric, value int64) metrics.Sample {
+			return metrics.Sample{
+				TimeSeries: metrics.TimeSeries{
+					Metric: metric,
+					Tags:   ctm.Tags,
+				},
+				Time:     now,
+				Metadata: ctm.Metadata,
+				Value:    float64(value),
+			}
+		}
+
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.ConnectedSamples{
+			Samples: []metrics.Sample{
+				sample(state.BuiltinMetrics.DataSent, sent),
+				sample(state.BuiltinMetrics.DataReceived, received),
+			},
+			Tags: ctm.Tags,
+			Time: now,
+		})
+	}
+}
+
 // upgradeDialerToTLS returns a DialContextFunc that uses the provided dialer to
 // establish a connection, and then upgrades it to TLS using the provided config.
 //
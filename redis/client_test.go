@@ -2,13 +2,16 @@ package redis
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-redis/redis/redistest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/v2/js/modulestest"
@@ -107,6 +110,28 @@ func TestClientConstructor(t *testing.T) {
 				sentinelPassword: 'sentinelpass',
 			}`,
 		},
+		{
+			name: "ok/object/tags",
+			arg: `{
+				socket: {
+					host: 'localhost',
+					port: 6379,
+				},
+				tags: {
+					service: 'cache',
+				},
+			}`,
+		},
+		{
+			name: "ok/object/tracing",
+			arg: `{
+				socket: {
+					host: 'localhost',
+					port: 6379,
+				},
+				tracing: true,
+			}`,
+		},
 		{
 			name:   "err/empty",
 			arg:    "",
@@ -135,6 +160,19 @@ func TestClientConstructor(t *testing.T) {
 			}`,
 			expErr: "invalid options; reason: empty socket options",
 		},
+		{
+			name: "err/object/tags_wrong_type",
+			arg: `{
+				socket: {
+					host: 'localhost',
+					port: 6379,
+				},
+				tags: {
+					service: 42,
+				},
+			}`,
+			expErr: `invalid options; reason: json: cannot unmarshal number into Go struct field clientOptions.tags.service of type string`,
+		},
 		{
 			name: "err/object/cluster_wrong_type",
 			arg: `{
@@ -214,8 +252,8 @@ func TestClientSet(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("SET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("SET", func(c *redistest.Connection, args []string) {
 		if len(args) <= 2 && len(args) > 4 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'GET' command"))
 			return
@@ -266,8 +304,8 @@ func TestClientGet(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("GET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("GET", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'GET' command"))
 			return
@@ -310,8 +348,8 @@ func TestClientGetSet(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("GETSET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("GETSET", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'GETSET' command"))
 			return
@@ -356,8 +394,8 @@ func TestClientDel(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("DEL", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("DEL", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'DEL' command"))
 			return
@@ -389,8 +427,8 @@ func TestClientGetDel(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("GETDEL", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("GETDEL", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'GETDEL' command"))
 			return
@@ -433,8 +471,8 @@ func TestClientExists(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("EXISTS", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("EXISTS", func(c *redistest.Connection, args []string) {
 		if len(args) == 0 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'EXISTS' command"))
 			return
@@ -466,8 +504,8 @@ func TestClientIncr(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("INCR", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("INCR", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'INCR' command"))
 			return
@@ -509,8 +547,8 @@ func TestClientIncrBy(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("INCRBY", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("INCRBY", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'INCRBY' command"))
 			return
@@ -558,8 +596,8 @@ func TestClientDecr(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("DECR", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("DECR", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'DECR' command"))
 			return
@@ -601,8 +639,8 @@ func TestClientDecrBy(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("DECRBY", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("DECRBY", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'DECRBY' command"))
 			return
@@ -650,9 +688,9 @@ func TestClientRandomKey(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	calledN := 0
-	rs.RegisterCommandHandler("RANDOMKEY", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("RANDOMKEY", func(c *redistest.Connection, args []string) {
 		if len(args) != 0 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'RANDOMKEY' command"))
 			return
@@ -697,8 +735,8 @@ func TestClientMget(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("MGET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("MGET", func(c *redistest.Connection, args []string) {
 		if len(args) < 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'MGET' command"))
 			return
@@ -736,8 +774,8 @@ func TestClientExpire(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("EXPIRE", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("EXPIRE", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'EXPIRE' command"))
 			return
@@ -777,8 +815,8 @@ func TestClientTTL(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("TTL", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("TTL", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'EXPIRE' command"))
 			return
@@ -818,8 +856,8 @@ func TestClientPersist(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("PERSIST", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("PERSIST", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'PERSIST' command"))
 			return
@@ -855,12 +893,180 @@ func TestClientPersist(t *testing.T) {
 	}, rs.GotCommands())
 }
 
+func TestClientStringAndKeyCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	ks := redistest.NewKeyspace()
+	rs.UseKeyspace(ks)
+
+	now := time.Now().Truncate(time.Second)
+	ks.Now = func() time.Time { return now }
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+			const now = %d;
+
+
+			(async () => {
+				expect(await redis.append("greeting", "Hello"), 5);
+				expect(await redis.append("greeting", " World", { tags: { kind: "append" } }), 11);
+				expect(await redis.strlen("greeting"), 11);
+				expect(await redis.getrange("greeting", -5, -1), "World");
+				expect(await redis.setrange("greeting", 6, "Redis"), 11);
+				expect(await redis.get("greeting"), "Hello Redis");
+
+				expect(await redis.mset({ a: 1, b: "two" }), "OK");
+				expect(await redis.mset("c", 3, "d", true, { tags: { kind: "pairs" } }), "OK");
+				expect(await redis.mget("a", "b", "c"), ["1", "two", "3"]);
+				expect(await redis.msetnx({ a: 1, e: 5 }), false);
+				expect(await redis.msetnx("e", 5, "f", 6), true);
+				expect(await redis.setnx("a", "other"), false);
+				expect(await redis.setnx("g", 7), true);
+				expect(await redis.incrbyfloat("a", 0.5), 1.5);
+
+				expect(await redis.setex("session", 60, "abc"), "OK");
+				expect(await redis.ttl("session"), 60);
+				expect(await redis.psetex("session", 1500, "def"), "OK");
+				expect(await redis.pttl("session"), 1500);
+				expect(await redis.getex("session", { persist: true }), "def");
+				expect(await redis.pttl("session"), -1);
+				expect(await redis.getex("session", { exAt: now + 30 }), "def");
+				expect(await redis.expiretime("session"), now + 30);
+				expect(await redis.pexpire("session", 2000), true);
+				expect(await redis.pttl("session"), 2000);
+				expect(await redis.expireat("session", now + 90), true);
+				expect(await redis.ttl("session"), 90);
+				expect(await redis.expiretime("missing"), -2);
+				expect(await redis.pttl("missing"), -2);
+
+				expect(await redis.type("session"), "string");
+				expect(await redis.type("missing"), "none");
+				expect(await redis.rename("session", "renamed"), "OK");
+				expect(await redis.renamenx("renamed", "a"), false);
+				expect(await redis.copy("renamed", "copied"), true);
+				expect(await redis.copy("a", "copied"), false);
+				expect(await redis.copy("a", "copied", { replace: true, db: 0 }), true);
+				expect(await redis.get("copied"), "1.5");
+
+				expect(await redis.touch("a", "b", "missing"), 2);
+				expect(await redis.objectIdletime("a"), 0);
+				expect(await redis.objectEncoding("c"), "int");
+
+				const dump = await redis.dump("greeting");
+				if (!(dump instanceof ArrayBuffer)) { throw 'expected an ArrayBuffer' }
+				expect(await redis.dump("missing"), null);
+				expect(await redis.restore("restored", 0, dump), "OK");
+				expect(await redis.get("restored"), "Hello Redis");
+				expect(await redis.restore("restored", 5000, dump, { replace: true, idleTime: 10 }), "OK");
+				expect(await redis.objectIdletime("restored"), 10);
+				expect(await redis.pttl("restored"), 5000);
+
+				expect(await redis.unlink("a", "b", "missing"), 2);
+
+				await expectError(redis.mset("a"), "expects keys and values");
+				await expectError(redis.mset({ a: {} }), "unsupported type");
+				await expectError(redis.setrange("greeting", -1, "x"), "must not be negative");
+				await expectError(redis.setex("session", 0, "abc"), "must be positive");
+				await expectError(redis.getex("greeting", { ex: 1, persist: true }), "at most one expiration option");
+				await expectError(redis.getex("missing"), "redis: nil");
+				await expectError(redis.rename("missing", "other"), "no such key");
+				await expectError(redis.copy("a", "b", { db: -1 }), "must not be negative");
+				await expectError(redis.objectFreq("c"), "LFU maxmemory policy");
+				await expectError(redis.restore("restored", 0, dump), "BUSYKEY");
+				await expectError(redis.restore("restored", 0, 42), "expected ArrayBuffer or string");
+				await expectError(redis.unlink({}, null), "unsupported type");
+			})()
+		`, rs.Addr(), now.Unix()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"MSET", "a", "1", "b", "two"})
+	assert.Contains(t, rs.GotCommands(), []string{"GETEX", "session", "PERSIST"})
+	assert.Contains(t, rs.GotCommands(), []string{"COPY", "renamed", "copied"})
+}
+
+func TestClientBitmapCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			(async () => {
+				for (const user of [1, 3, 9]) {
+					expect(await redis.setbit("active", user, 1), 0);
+				}
+				expect(await redis.setbit("active", 3, 0, { tags: { kind: "clear" } }), 1);
+				expect(await redis.getbit("active", 1), 1);
+				expect(await redis.getbit("active", 100), 0);
+
+				expect(await redis.bitcount("active"), 2);
+				expect(await redis.bitcount("active", { start: 0, end: 0 }), 1);
+				expect(await redis.bitcount("active", { start: 2, end: 9, unit: "bit" }), 1);
+				expect(await redis.bitpos("active", 1), 1);
+				expect(await redis.bitpos("active", 1, { start: 1 }), 9);
+				expect(await redis.bitpos("active", 1, { start: 2, end: 8, unit: "BIT" }), -1);
+				expect(await redis.bitpos("active", 0, { start: 0, end: 0 }), 0);
+
+				await redis.setbit("other", 2, 1);
+				expect(await redis.bitop("or", "union", "active", "other"), 2);
+				expect(await redis.bitcount("union"), 3);
+				expect(await redis.bitop("AND", "both", "active", "other"), 2);
+				expect(await redis.bitcount("both"), 0);
+				expect(await redis.bitop("not", "inverted", "other"), 1);
+				expect(await redis.bitcount("inverted"), 7);
+
+				expect(await redis.bitfield("counters", [
+					{ op: "set", type: "u8", offset: "#1", value: 200 },
+					{ op: "get", type: "i8", offset: 8 },
+					{ op: "overflow", behavior: "fail" },
+					{ op: "incrby", type: "u8", offset: 8, increment: 100 },
+					{ op: "overflow", behavior: "SAT" },
+					{ op: "incrby", type: "u8", offset: 8, increment: 100 },
+				]), [0, -56, null, 255]);
+
+				await expectError(redis.setbit("active", 1, 2), "must be 0 or 1");
+				await expectError(redis.bitcount("active", { start: 0 }), "start requires end");
+				await expectError(redis.bitcount("active", { start: 0, end: 1, unit: "word" }), "unknown unit");
+				await expectError(redis.bitpos("active", 1, { end: 1 }), "end requires start");
+				await expectError(redis.bitpos("active", 1, { start: 0, unit: "bit" }), "unit requires start and end");
+				await expectError(redis.bitop("nand", "dest", "active"), "unknown bitop operation");
+				await expectError(redis.bitop("not", "dest", "active", "other"), "expects a single key");
+				await expectError(redis.bitfield("counters", []), "expected a non-empty array");
+				await expectError(redis.bitfield("counters", [{ op: "get", type: "u8", offset: -1 }]), "index 0");
+				await expectError(redis.bitfield("counters", [{ op: "set", type: "u8", offset: 0 }]), "requires a value");
+				await expectError(redis.bitfield("counters", [{ op: "overflow", behavior: "clamp" }]), "unknown overflow");
+				await expectError(redis.bitfield("counters", [{ op: "get", type: "u64", offset: 0 }]), "Invalid bitfield type");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"BITCOUNT", "active", "2", "9", "BIT"})
+	assert.Contains(t, rs.GotCommands(), []string{
+		"BITFIELD", "counters", "SET", "u8", "#1", "200", "GET", "i8", "8", "OVERFLOW", "FAIL",
+		"INCRBY", "u8", "8", "100", "OVERFLOW", "SAT", "INCRBY", "u8", "8", "100",
+	})
+}
+
 func TestClientLPush(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("LPUSH", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("LPUSH", func(c *redistest.Connection, args []string) {
 		if len(args) < 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LPUSH' command"))
 			return
@@ -903,8 +1109,8 @@ func TestClientRPush(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("RPUSH", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("RPUSH", func(c *redistest.Connection, args []string) {
 		if len(args) < 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'RPUSH' command"))
 			return
@@ -947,9 +1153,9 @@ func TestClientLPop(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	listState := []string{"first", "second"}
-	rs.RegisterCommandHandler("LPOP", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("LPOP", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LPOP' command"))
 			return
@@ -998,9 +1204,9 @@ func TestClientRPop(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	listState := []string{"first", "second"}
-	rs.RegisterCommandHandler("RPOP", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("RPOP", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'RPOP' command"))
 			return
@@ -1049,9 +1255,9 @@ func TestClientLRange(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	listState := []string{"first", "second", "third"}
-	rs.RegisterCommandHandler("LRANGE", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("LRANGE", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LRANGE' command"))
 			return
@@ -1113,9 +1319,9 @@ func TestClientLIndex(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	listState := []string{"first", "second", "third"}
-	rs.RegisterCommandHandler("LINDEX", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("LINDEX", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LINDEX' command"))
 			return
@@ -1177,9 +1383,9 @@ func TestClientClientLSet(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	listState := []string{"first"}
-	rs.RegisterCommandHandler("LSET", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("LSET", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LSET' command"))
 			return
@@ -1231,8 +1437,8 @@ func TestClientLrem(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("LREM", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("LREM", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LREM' command"))
 			return
@@ -1278,8 +1484,8 @@ func TestClientLlen(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("LLEN", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("LLEN", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LREM' command"))
 			return
@@ -1320,12 +1526,67 @@ func TestClientLlen(t *testing.T) {
 	}, rs.GotCommands())
 }
 
+func TestClientListCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			(async () => {
+				expect(await redis.lpushx("log", "a"), 0);
+				await redis.rpush("log", "a", "b", "a", "c");
+				expect(await redis.rpushx("log", "d", "e"), 6);
+				expect(await redis.lpushx("log", "z"), 7);
+
+				expect(await redis.linsert("log", "BEFORE", "b", "B"), 8);
+				expect(await redis.linsert("log", "AFTER", "missing", "x"), -1);
+				expect(await redis.lrange("log", 0, -1), ["z", "a", "B", "b", "a", "c", "d", "e"]);
+
+				expect(await redis.lpos("log", "a"), 1);
+				expect(await redis.lpos("log", "a", { rank: -1 }), 4);
+				expect(await redis.lpos("log", "a", { count: 0 }), [1, 4]);
+				expect(await redis.lpos("log", "missing"), null);
+
+				// Capped logs keep their most recent entries.
+				expect(await redis.ltrim("log", -5, -1), "OK");
+				expect(await redis.lrange("log", 0, -1), ["b", "a", "c", "d", "e"]);
+
+				expect(await redis.lpop("log", 2), ["b", "a"]);
+				expect(await redis.rpop("log", 1, { tags: { queue: "log" } }), ["e"]);
+				expect(await redis.lpop("log"), "c");
+
+				expect(await redis.lmove("log", "done", "LEFT", "RIGHT"), "d");
+				expect(await redis.lmpop(["log", "done"], "LEFT"), { key: "done", values: ["d"] });
+				expect(await redis.lmpop("done", "RIGHT", 2), null);
+
+				await expectError(redis.lpop("log", -1), "must not be negative");
+				await expectError(redis.lpop("log", 1.5), "expected an integer");
+				await expectError(redis.lpos("log", "a", { limit: 1 }), "unknown field");
+				await expectError(redis.lmpop([], "LEFT"), "expected at least one key");
+				await expectError(redis.lmpop("log", "LEFT", 0), "must be positive");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"LPOS", "log", "a", "rank", "-1"})
+	assert.Contains(t, rs.GotCommands(), []string{"LMPOP", "2", "log", "done", "left", "count", "1"})
+}
+
 func TestClientHSet(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HSET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HSET", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'LREM' command"))
 			return
@@ -1371,8 +1632,8 @@ func TestClientHsetnx(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HSETNX", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HSETNX", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HSETNX' command"))
 			return
@@ -1422,8 +1683,8 @@ func TestClientHget(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HGET", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HGET", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HGET' command"))
 			return
@@ -1466,8 +1727,8 @@ func TestClientHdel(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HDEL", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HDEL", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HDEL' command"))
 			return
@@ -1510,8 +1771,8 @@ func TestClientHgetall(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HGETALL", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HGETALL", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HGETALL' command"))
 			return
@@ -1554,8 +1815,8 @@ func TestClientHkeys(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HKEYS", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HKEYS", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HKEYS' command"))
 			return
@@ -1598,8 +1859,8 @@ func TestClientHvals(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HVALS", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HVALS", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HVALS' command"))
 			return
@@ -1642,8 +1903,8 @@ func TestClientHlen(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("HLEN", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("HLEN", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HLEN' command"))
 			return
@@ -1686,9 +1947,9 @@ func TestClientHincrby(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	fooHValue := 1
-	rs.RegisterCommandHandler("HINCRBY", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("HINCRBY", func(c *redistest.Connection, args []string) {
 		if len(args) != 3 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'HINCRBY' command"))
 			return
@@ -1735,13 +1996,82 @@ func TestClientHincrby(t *testing.T) {
 	}, rs.GotCommands())
 }
 
+func TestClientHashCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			(async () => {
+				expect(await redis.hset("user", { name: "ada", visits: 1, ratio: 0.5 }), 3);
+				expect(await redis.hset("user", "visits", 2, { tags: { kind: "single" } }), 0);
+				expect(await redis.hmget("user", "name", "missing", "visits"), ["ada", null, "2"]);
+				expect(await redis.hexists("user", "name"), true);
+				expect(await redis.hexists("user", "missing"), false);
+				expect(await redis.hincrbyfloat("user", "ratio", 0.25), 0.75);
+				expect(await redis.hstrlen("user", "name"), 3);
+
+				const fields = ["name", "ratio", "visits"];
+				if (!fields.includes(await redis.hrandfield("user"))) { throw 'unexpected random field' }
+				expect((await redis.hrandfield("user", { count: 5 })).sort(), fields);
+				const [pair] = await redis.hrandfield("user", { count: 1, withValues: true });
+				expect(pair.value, await redis.hget("user", pair.field));
+				expect(await redis.hrandfield("missing"), null);
+
+				const map = await redis.hgetall("user", { asMap: true }, { tags: { kind: "map" } });
+				if (!(map instanceof Map)) { throw 'expected a Map' }
+				expect([...map.entries()], [["name", "ada"], ["ratio", "0.75"], ["visits", "2"]]);
+				expect((await redis.hgetall("user")).name, "ada");
+				expect((await redis.hgetall("user", null, { tags: { kind: "object" } })).name, "ada");
+
+				expect(await redis.hexpire("user", 60, ["name", "missing"]), [1, -2]);
+				expect(await redis.hexpire("user", 120, "name", { condition: "nx" }), [0]);
+				expect(await redis.hpexpire("user", 90000, "visits"), [1]);
+				expect(await redis.httl("user", ["name", "visits", "ratio"]), [60, 90, -1]);
+				expect(await redis.hpersist("user", "name"), [1]);
+
+				expect(await redis.hgetex("user", ["visits", "missing"], { persist: true }), ["2", null]);
+				expect(await redis.httl("user", "visits"), [-1]);
+				expect(await redis.hgetex("user", "name", { ex: 30 }), ["ada"]);
+				expect(await redis.httl("user", "name"), [30]);
+
+				expect(await redis.hsetex("session", { token: "abc" }, { px: 5000, condition: "FNX" }), true);
+				expect(await redis.hsetex("session", { token: "def" }, { condition: "FNX" }), false);
+				expect(await redis.hsetex("session", { token: "ghi" }, { keepTtl: true }), true);
+				expect(await redis.httl("session", "token"), [5]);
+
+				await expectError(redis.hset("user", "name"), "expects a field and a value");
+				await expectError(redis.hset("user", { name: {} }), "unsupported type");
+				await expectError(redis.hgetall("user", { asMap: "yes" }), "invalid hgetall options");
+				await expectError(redis.hrandfield("user", { withValues: true }), "requires a count");
+				await expectError(redis.hexpire("user", 1, [], null), "expected at least one field");
+				await expectError(redis.hexpire("user", 1, "name", { condition: "ALWAYS" }), "unknown condition");
+				await expectError(redis.hgetex("user", "name", { ex: 1, persist: true }), "at most one expiration option");
+				await expectError(redis.hsetex("user", "name", null), "expected object");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"HSET", "user", "name", "ada", "ratio", "0.5", "visits", "1"})
+	assert.Contains(t, rs.GotCommands(), []string{"HSETEX", "session", "FNX", "PX", "5000", "FIELDS", "1", "token", "abc"})
+}
+
 func TestClientSadd(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	barWasSet := false
-	rs.RegisterCommandHandler("SADD", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("SADD", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SADD' command"))
 			return
@@ -1790,9 +2120,9 @@ func TestClientSrem(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	fooWasRemoved := false
-	rs.RegisterCommandHandler("SREM", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("SREM", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SREM' command"))
 			return
@@ -1844,8 +2174,8 @@ func TestClientSismember(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("SISMEMBER", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("SISMEMBER", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SISMEMBER' command"))
 			return
@@ -1893,8 +2223,8 @@ func TestClientSmembers(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("SMEMBERS", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("SMEMBERS", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SMEMBERS' command"))
 			return
@@ -1934,8 +2264,8 @@ func TestClientSrandmember(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("SRANDMEMBER", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("SRANDMEMBER", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SRANDMEMBER' command"))
 			return
@@ -1978,8 +2308,8 @@ func TestClientSpop(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
-	rs.RegisterCommandHandler("SPOP", func(c *Connection, args []string) {
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("SPOP", func(c *redistest.Connection, args []string) {
 		if len(args) != 1 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SPOP' command"))
 			return
@@ -2018,13 +2348,72 @@ func TestClientSpop(t *testing.T) {
 	}, rs.GotCommands())
 }
 
+func TestClientSetCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			(async () => {
+				expect(await redis.sadd("a", "1", "2", "3"), 3);
+				expect(await redis.sadd("b", "2", "3", "4"), 3);
+				expect(await redis.scard("a"), 3);
+				expect(await redis.scard("missing"), 0);
+				expect(await redis.smismember("a", "1", "4", { tags: { kind: "multi" } }), [true, false]);
+
+				expect((await redis.sinter("a", "b")).sort(), ["2", "3"]);
+				expect((await redis.sunion("a", "b")).sort(), ["1", "2", "3", "4"]);
+				expect(await redis.sdiff("a", "b", { tags: { kind: "diff" } }), ["1"]);
+				expect(await redis.sinter("a", "missing"), []);
+				expect(await redis.sintercard(["a", "b"]), 2);
+				expect(await redis.sintercard(["a", "b"], 1), 1);
+				expect(await redis.sintercard("a"), 3);
+
+				expect(await redis.sunionstore("union", "a", "b"), 4);
+				expect(await redis.sinterstore("inter", "a", "b"), 2);
+				expect(await redis.sdiffstore("diff", "b", "a"), 1);
+				expect(await redis.smembers("diff"), ["4"]);
+
+				expect(await redis.smove("a", "c", "1"), true);
+				expect(await redis.smove("a", "c", "1"), false);
+				expect(await redis.sismember("c", "1"), true);
+
+				expect((await redis.srandmember("union", 10)).sort(), ["1", "2", "3", "4"]);
+				expect((await redis.srandmember("union", -6)).length, 6);
+				expect(await redis.srandmember("missing", 2), []);
+				expect((await redis.spop("union", 3)).length, 3);
+				expect(await redis.scard("union"), 1);
+				expect(await redis.spop("missing", 1), []);
+
+				await expectError(redis.spop("union", -1), "must not be negative");
+				await expectError(redis.srandmember("union", 1.5), "expected an integer");
+				await expectError(redis.sintercard([], 1), "expected at least one key");
+				await expectError(redis.sintercard(["a"], -1), "must not be negative");
+				await expectError(redis.smismember("a", {}, null), "unsupported type");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"SINTERCARD", "2", "a", "b", "limit", "1"})
+	assert.Contains(t, rs.GotCommands(), []string{"SPOP", "union", "3"})
+}
+
 func TestClientSendCommand(t *testing.T) {
 	t.Parallel()
 
 	ts := newTestSetup(t)
-	rs := RunT(t)
+	rs := redistest.RunT(t)
 	fooWasSet := false
-	rs.RegisterCommandHandler("SADD", func(c *Connection, args []string) {
+	rs.RegisterCommandHandler("SADD", func(c *redistest.Connection, args []string) {
 		if len(args) != 2 {
 			c.WriteError(errors.New("ERR unexpected number of arguments for 'SADD' command"))
 			return
@@ -2062,6 +2451,480 @@ func TestClientSendCommand(t *testing.T) {
 	}, rs.GotCommands())
 }
 
+func TestClientAgainstKeyspace(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+			redis.set("counter", 10, 0)
+				.then(() => redis.incrBy("counter", 5))
+				.then(res => { if (res !== 15) { throw 'unexpected value for incrBy result: ' + res } })
+				.then(() => redis.rpush("queue", "a", "b", "c"))
+				.then(() => redis.lpop("queue"))
+				.then(res => { if (res !== "a") { throw 'unexpected value for lpop result: ' + res } })
+				.then(() => redis.hset("user", "name", "alice"))
+				.then(() => redis.hgetall("user"))
+				.then(res => { if (res.name !== "alice") { throw 'unexpected value for hgetall result: ' + JSON.stringify(res) } })
+				.then(() => redis.del("counter", "queue", "user"))
+				.then(res => { if (res !== 3) { throw 'unexpected value for del result: ' + res } })
+				.then(() => redis.get("counter"))
+				.then(
+					res => { throw 'expected get to fail on a deleted key' },
+					err => { if (!String(err).includes('redis: nil')) { throw 'unexpected error: ' + err } }
+				)
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+}
+
+func TestClientRetriesAndTimeouts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retriable errors are retried up to maxRetries", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+			c.WriteBulkString("bar")
+		})
+		rs.InjectFault(redistest.Fault{Command: "GET", Error: redistest.ErrLoading, Times: 2})
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({
+					socket: { host: '%s', port: %d },
+					maxRetries: 2,
+					minRetryBackoff: 1000000,
+					maxRetryBackoff: 1000000,
+				});
+
+				redis.get("foo")
+					.then(res => { if (res !== "bar") { throw 'unexpected value for get result: ' + res } })
+			`, rs.Addr().IP.String(), rs.Addr().Port))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Equal(t, 2, rs.InjectedFaultsCount())
+	})
+
+	t.Run("errors are returned once retries are exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.InjectFault(redistest.Fault{Command: "GET", Error: redistest.ErrLoading})
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({
+					socket: { host: '%s', port: %d },
+					maxRetries: 1,
+					minRetryBackoff: 1000000,
+					maxRetryBackoff: 1000000,
+				});
+
+				redis.get("foo")
+					.then(
+						res => { throw 'expected get to fail' },
+						err => { if (!String(err).includes('LOADING')) { throw 'unexpected error: ' + err } }
+					)
+			`, rs.Addr().IP.String(), rs.Addr().Port))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Equal(t, 2, rs.InjectedFaultsCount())
+	})
+
+	t.Run("slow replies time out", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+			c.WriteBulkString("bar")
+		})
+		rs.InjectFault(redistest.Fault{Command: "GET", Latency: 500 * time.Millisecond})
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({
+					socket: { host: '%s', port: %d, readTimeout: 100 },
+					maxRetries: -1,
+				});
+
+				redis.get("foo")
+					.then(
+						res => { throw 'expected get to time out' },
+						err => { if (!String(err).includes('i/o timeout')) { throw 'unexpected error: ' + err } }
+					)
+			`, rs.Addr().IP.String(), rs.Addr().Port))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+	})
+}
+
+func TestClientCluster(t *testing.T) {
+	t.Parallel()
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client of the cluster, configured with the provided cluster options,
+	// and with all the nodes of the cluster as seed nodes.
+	runScript := func(t *testing.T, sc *redistest.StubCluster, clusterOptions, script string) {
+		t.Helper()
+
+		ts := newTestSetup(t)
+
+		// Scripts may issue many commands, whose metrics samples would
+		// otherwise end up filling the samples channel.
+		go func() {
+			for {
+				select {
+				case <-ts.samples:
+				case <-t.Context().Done():
+					return
+				}
+			}
+		}()
+
+		var urls []string
+		for _, node := range sc.Nodes() {
+			urls = append(urls, "redis://"+node.Addr().String())
+		}
+		nodes, err := json.Marshal(urls)
+		require.NoError(t, err)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({ cluster: { nodes: %s, %s } });
+				%s
+			`, nodes, clusterOptions, script))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+	}
+
+	// countCommands returns the number of commands of the provided
+	// name received by the node.
+	countCommands := func(node *redistest.StubServer, command string) int {
+		n := 0
+		for _, cmd := range node.GotCommands() {
+			if cmd[0] == command {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("commands are routed to the master serving their key", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 2, 0)
+		sc.UseKeyspace()
+
+		runScript(t, sc, "", `
+			redis.set("foo", "bar", 0)
+				.then(() => redis.get("foo"))
+				.then(res => { if (res !== "bar") { throw 'unexpected value for get result: ' + res } })
+		`)
+
+		owner := sc.SlotOwner(redistest.KeySlot("foo"))
+		assert.Contains(t, sc.Masters[owner].GotCommands(), []string{"GET", "foo"})
+		assert.Zero(t, countCommands(sc.Masters[1-owner], "GET"))
+	})
+
+	t.Run("maxRedirects", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 2, 0)
+		slot := redistest.KeySlot("foo")
+		for i, master := range sc.Masters {
+			master.InjectFault(redistest.Fault{
+				Command: "GET",
+				Error:   fmt.Errorf("MOVED %d %s", slot, sc.Masters[1-i].Addr()),
+			})
+		}
+
+		runScript(t, sc, "maxRedirects: 1", `
+			redis.get("foo")
+				.then(
+					res => { throw 'expected get to fail' },
+					err => { if (!String(err).includes('MOVED')) { throw 'unexpected error: ' + err } }
+				)
+		`)
+
+		assert.Equal(t, 2, sc.Masters[0].InjectedFaultsCount()+sc.Masters[1].InjectedFaultsCount())
+	})
+
+	t.Run("readOnly", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 2, 1)
+		sc.UseKeyspace()
+
+		runScript(t, sc, "readOnly: true", `
+			redis.set("foo", "bar", 0)
+				.then(() => redis.get("foo"))
+				.then(res => { if (res !== "bar") { throw 'unexpected value for get result: ' + res } })
+		`)
+
+		owner := sc.SlotOwner(redistest.KeySlot("foo"))
+		assert.Equal(t, 1, countCommands(sc.Masters[owner], "SET"))
+		assert.Zero(t, countCommands(sc.Masters[owner], "GET"))
+		assert.Equal(t, 1, countCommands(sc.Replicas[owner][0], "GET"))
+	})
+
+	t.Run("readOnly function calls", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 2, 1)
+		for _, command := range []string{"FCALL", "FCALL_RO"} {
+			sc.RegisterCommandHandler(command, func(c *redistest.Connection, args []string) {
+				c.WriteBulkString(args[2])
+			})
+		}
+
+		runScript(t, sc, "readOnly: true", `
+			redis.fcall("myfunc", ["foo"])
+				.then(() => redis.fcallRo("myfunc", ["foo"]))
+				.then(res => { if (res !== "foo") { throw 'unexpected value for fcallRo result: ' + res } })
+		`)
+
+		owner := sc.SlotOwner(redistest.KeySlot("foo"))
+		assert.Equal(t, 1, countCommands(sc.Masters[owner], "FCALL"))
+		assert.Zero(t, countCommands(sc.Masters[owner], "FCALL_RO"))
+		assert.Equal(t, 1, countCommands(sc.Replicas[owner][0], "FCALL_RO"))
+	})
+
+	t.Run("routeByLatency", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 1, 1)
+		sc.UseKeyspace()
+		sc.Masters[0].InjectFault(redistest.Fault{Command: "PING", Latency: 50 * time.Millisecond})
+		sc.RegisterCommandHandler("EXISTS", func(c *redistest.Connection, _ []string) {
+			time.Sleep(10 * time.Millisecond)
+			c.WriteInteger(0)
+		})
+
+		// Latencies are measured in the background, once the cluster's
+		// topology is known, so we keep on reading for a while.
+		runScript(t, sc, "routeByLatency: true", `
+			(async () => {
+				const start = Date.now();
+				while (Date.now() - start < 1000) {
+					await redis.exists("foo");
+				}
+			})()
+		`)
+
+		assert.Positive(t, countCommands(sc.Replicas[0][0], "EXISTS"))
+	})
+
+	t.Run("routeRandomly", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 1, 1)
+		sc.UseKeyspace()
+
+		runScript(t, sc, "routeRandomly: true", `
+			(async () => {
+				for (let i = 0; i < 50; i++) {
+					await redis.exists("foo");
+				}
+			})()
+		`)
+
+		assert.Positive(t, countCommands(sc.Masters[0], "EXISTS"))
+		assert.Positive(t, countCommands(sc.Replicas[0][0], "EXISTS"))
+	})
+}
+
+func TestClientSentinel(t *testing.T) {
+	t.Parallel()
+
+	// newServer returns a server replying to GET commands with its name.
+	newServer := func(t *testing.T, name string) *redistest.StubServer {
+		t.Helper()
+
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+			c.WriteBulkString(name)
+		})
+
+		return rs
+	}
+
+	master, replica := newServer(t, "master"), newServer(t, "replica")
+	ss := redistest.RunSentinelT(t, 1, "mymaster", master, replica)
+	ts := newTestSetup(t)
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client({
+				socket: { host: '%[1]s', port: %[2]d },
+				masterName: 'mymaster',
+			});
+			const sentinel = new Client('redis://%[1]s:%[2]d');
+
+			(async () => {
+				let res = await redis.get("foo");
+				if (res !== "master") { throw 'unexpected value for get result: ' + res }
+
+				await sentinel.sendCommand("SENTINEL", "FAILOVER", "mymaster");
+
+				const deadline = Date.now() + 1000;
+				while (res !== "replica") {
+					if (Date.now() > deadline) { throw 'the client did not follow the failover' }
+					res = await redis.get("foo");
+				}
+			})()
+		`, ss.Sentinels[0].Addr().IP, ss.Sentinels[0].Addr().Port))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Same(t, replica, ss.Master())
+	assert.Contains(t, ss.Sentinels[0].GotCommands(), []string{"SENTINEL", "get-master-addr-by-name", "mymaster"})
+}
+
+func TestClientAuth(t *testing.T) {
+	t.Parallel()
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client configured with the provided options, and returns its error.
+	runScript := func(t *testing.T, options, script string) error {
+		t.Helper()
+
+		ts := newTestSetup(t)
+
+		return ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client(%s);
+				%s
+			`, options, script))
+
+			return err
+		})
+	}
+
+	newServer := func(t *testing.T) *redistest.StubServer {
+		t.Helper()
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+		rs.AddUser(redistest.User{Name: "alice", Password: "secret"})
+		rs.AddUser(redistest.User{Name: "bob", Password: "secret", Commands: []string{"get"}})
+
+		return rs
+	}
+
+	t.Run("password", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+		rs.RequirePassword("secret")
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, fmt.Sprintf("'redis://:secret@%s'", rs.Addr()), `
+			redis.set("foo", "bar", 0)
+		`)
+		require.NoError(t, err)
+		assert.Contains(t, rs.GotCommands(), []string{"AUTH", "secret"})
+	})
+
+	t.Run("username and password", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t)
+
+		err := runScript(t, fmt.Sprintf(`{
+			socket: { host: '%s', port: %d },
+			username: 'alice',
+			password: 'secret',
+		}`, rs.Addr().IP, rs.Addr().Port), `
+			redis.set("foo", "bar", 0)
+		`)
+		require.NoError(t, err)
+		assert.Contains(t, rs.GotCommands(), []string{"AUTH", "alice", "secret"})
+		assert.Contains(t, rs.GotCommands(), []string{"SET", "foo", "bar"})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t)
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			redis.get("foo")
+				.then(
+					res => { throw 'unexpected get result: ' + res },
+					err => { if (!String(err).includes("NOAUTH")) { throw 'unexpected error: ' + err } },
+				)
+		`)
+		require.NoError(t, err)
+
+		err = runScript(t, fmt.Sprintf("'redis://alice:wrong@%s'", rs.Addr()), `
+			redis.get("foo")
+				.then(
+					res => { throw 'unexpected get result: ' + res },
+					err => { if (!String(err).includes("WRONGPASS")) { throw 'unexpected error: ' + err } },
+				)
+		`)
+		require.NoError(t, err)
+
+		err = runScript(t, fmt.Sprintf("'redis://bob:secret@%s'", rs.Addr()), `
+			redis.set("foo", "bar", 0)
+				.then(
+					res => { throw 'unexpected set result: ' + res },
+					err => { if (!String(err).includes("NOPERM")) { throw 'unexpected error: ' + err } },
+				)
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("sentinel credentials", func(t *testing.T) {
+		t.Parallel()
+
+		master := newServer(t)
+		ss := redistest.RunSentinelT(t, 1, "mymaster", master)
+		ss.Sentinels[0].AddUser(redistest.User{Name: "watcher", Password: "sentinelpass"})
+
+		err := runScript(t, fmt.Sprintf(`{
+			socket: { host: '%s', port: %d },
+			masterName: 'mymaster',
+			username: 'alice',
+			password: 'secret',
+			sentinelUsername: 'watcher',
+			sentinelPassword: 'sentinelpass',
+		}`, ss.Sentinels[0].Addr().IP, ss.Sentinels[0].Addr().Port), `
+			redis.set("foo", "bar", 0)
+		`)
+		require.NoError(t, err)
+		assert.Contains(t, ss.Sentinels[0].GotCommands(), []string{"AUTH", "watcher", "sentinelpass"})
+		assert.Contains(t, master.GotCommands(), []string{"AUTH", "alice", "secret"})
+	})
+}
+
 func TestClientCommandsInInitContext(t *testing.T) {
 	t.Parallel()
 
@@ -2559,8 +3422,22 @@ type testSetup struct {
 // and event loop, ready to execute scripts as if being executed in the
 // main context of k6.
 func newTestSetup(t testing.TB) testSetup {
-	ts := newInitContextTestSetup(t)
+	return newTestSetupWithModule(t, new(RootModule))
+}
 
+// newTestSetupWithModule is like newTestSetup, but the module instance of
+// the VU is created by the provided root module, which several test setups
+// can share, as the VUs of a test do.
+func newTestSetupWithModule(t testing.TB, rm *RootModule) testSetup {
+	ts := newInitContextTestSetupWithModule(t, rm)
+	ts.moveToVUContext()
+
+	return ts
+}
+
+// moveToVUContext moves the test setup's runtime from the init context to
+// the VU context, as k6 does once the init context was evaluated.
+func (ts *testSetup) moveToVUContext() {
 	state := &lib.State{
 		Dialer: netext.NewDialer(
 			net.Dialer{},
@@ -2582,20 +3459,49 @@ func newTestSetup(t testing.TB) testSetup {
 	ts.runtime.MoveToVUContext(state)
 
 	ts.state = state
-	return ts
 }
 
+// testScriptHelpers defines the assertion functions available to the test
+// scripts:
+//   - expect throws unless the provided value equals the wanted one, objects
+//     being compared regardless of the order of their keys;
+//   - expectError returns a promise rejecting unless the provided promise
+//     rejects with an error containing the expected message.
+const testScriptHelpers = `
+	globalThis.canonical = value => JSON.stringify(value, (_, v) =>
+		v && typeof v === "object" && !Array.isArray(v)
+			? Object.fromEntries(Object.keys(v).sort().map(k => [k, v[k]]))
+			: v);
+	globalThis.expect = (got, want) => {
+		if (canonical(got) !== canonical(want)) {
+			throw 'expected ' + canonical(want) + ', got ' + canonical(got)
+		}
+	};
+	globalThis.expectError = (promise, expected) => promise.then(
+		res => { throw 'unexpected result: ' + canonical(res) },
+		err => { if (!String(err).includes(expected)) { throw 'unexpected error: ' + err } },
+	);
+`
+
 // newInitContextTestSetup initializes a new test setup.
 // It prepares a test setup with a mocked redis server and a goja runtime,
 // and event loop, ready to execute scripts as if being executed in the
 // main context of k6.
 func newInitContextTestSetup(t testing.TB) testSetup {
+	return newInitContextTestSetupWithModule(t, new(RootModule))
+}
+
+// newInitContextTestSetupWithModule is like newInitContextTestSetup, but
+// the module instance is created by the provided root module.
+func newInitContextTestSetupWithModule(t testing.TB, rm *RootModule) testSetup {
 	runtime := modulestest.NewRuntime(t)
 	samples := make(chan metrics.SampleContainer, 1000)
 
 	rt := runtime.VU.RuntimeField
-	m := new(RootModule).NewModuleInstance(runtime.VU)
+	m := rm.NewModuleInstance(runtime.VU)
 	require.NoError(t, rt.Set("Client", m.Exports().Named["Client"]))
+	_, err := rt.RunString(testScriptHelpers)
+	require.NoError(t, err)
 
 	return testSetup{
 		runtime: runtime,
@@ -2609,7 +3515,7 @@ func TestClientTLS(t *testing.T) {
 
 	ts := newTestSetup(t)
 	ts.state.TLSConfig.InsecureSkipVerify = true
-	rs := RunTSecure(t, nil)
+	rs := redistest.RunTSecure(t, nil)
 
 	err := ts.rt.Set("caCert", string(rs.TLSCertificate()))
 	require.NoError(t, err)
@@ -2643,12 +3549,12 @@ func TestClientTLS(t *testing.T) {
 func TestClientTLSAuth(t *testing.T) {
 	t.Parallel()
 
-	clientCert, clientPKey, err := generateTLSCert()
+	clientCert, clientPKey, err := redistest.GenerateTLSCert()
 	require.NoError(t, err)
 
 	ts := newTestSetup(t)
 	ts.state.TLSConfig.InsecureSkipVerify = true
-	rs := RunTSecure(t, clientCert)
+	rs := redistest.RunTSecure(t, clientCert)
 
 	err = ts.rt.Set("caCert", string(rs.TLSCertificate()))
 	require.NoError(t, err)
@@ -2688,11 +3594,11 @@ func TestClientTLSAuth(t *testing.T) {
 func TestClientTLSRespectsNetworkOPtions(t *testing.T) {
 	t.Parallel()
 
-	clientCert, clientPKey, err := generateTLSCert()
+	clientCert, clientPKey, err := redistest.GenerateTLSCert()
 	require.NoError(t, err)
 
 	ts := newTestSetup(t)
-	rs := RunTSecure(t, clientCert)
+	rs := redistest.RunTSecure(t, clientCert)
 
 	err = ts.rt.Set("caCert", string(rs.TLSCertificate()))
 	require.NoError(t, err)
@@ -0,0 +1,249 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// functionLoadOptions holds the options of the FunctionLoad method.
+type functionLoadOptions struct {
+	// Replace replaces the library if it already exists.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// FunctionLoad loads the library of Redis functions whose source `code`
+// is provided, and returns its name.
+//
+// The optional `options` object accepts a `replace` option, to replace the
+// library if it already exists.
+func (c *Client) FunctionLoad(code string, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &functionLoadOptions{}
+	if err := decodeCommandOptions("functionLoad", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		load := c.redisClient.FunctionLoad
+		if opts.Replace {
+			load = c.redisClient.FunctionLoadReplace
+		}
+
+		name, err := load(ctx, code).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(name)
+	}()
+
+	return promise
+}
+
+// functionListOptions holds the options of the FunctionList method.
+type functionListOptions struct {
+	// LibraryName is a glob-style pattern the names of the listed
+	// libraries must match.
+	LibraryName string `json:"libraryName,omitempty"`
+
+	// WithCode adds the source code of the libraries to the results.
+	WithCode bool `json:"withCode,omitempty"`
+}
+
+// FunctionList returns the libraries of Redis functions which are loaded,
+// as objects of the form `{ name, engine, functions, code }`, each of
+// their functions being an object of the form `{ name, description, flags
+// }`.
+//
+// The optional `options` object accepts a `libraryName` option, a pattern
+// to filter the libraries by name, and a `withCode` option, without which
+// the results do not hold the source code of the libraries.
+func (c *Client) FunctionList(options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &functionListOptions{}
+	if err := decodeCommandOptions("functionList", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		libraries, err := c.redisClient.FunctionList(ctx, redis.FunctionListQuery{
+			LibraryNamePattern: opts.LibraryName,
+			WithCode:           opts.WithCode,
+		}).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		results := make([]any, 0, len(libraries))
+		for _, library := range libraries {
+			functions := make([]any, 0, len(library.Functions))
+			for _, function := range library.Functions {
+				flags := function.Flags
+				if flags == nil {
+					flags = []string{}
+				}
+
+				functions = append(functions, map[string]any{
+					"name":        function.Name,
+					"description": function.Description,
+					"flags":       flags,
+				})
+			}
+
+			result := map[string]any{"name": library.Name, "engine": library.Engine, "functions": functions}
+			if opts.WithCode {
+				result["code"] = library.Code
+			}
+
+			results = append(results, result)
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// FunctionDelete deletes the library of Redis functions with the provided
+// `name`.
+func (c *Client) FunctionDelete(name string, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		status, err := c.redisClient.FunctionDelete(ctx, name).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(status)
+	}()
+
+	return promise
+}
+
+// Fcall calls the Redis function with the provided `name`, passing it the
+// provided `keys` and `args`, both of which are optional arrays, and
+// returns its result, null if the function returned nil.
+func (c *Client) Fcall(name string, keys, args, params any) *sobek.Promise {
+	return c.fcall("fcall", name, keys, args, params, redis.UniversalClient.FCall)
+}
+
+// FcallRo is like Fcall, but calls a read-only function, which is flagged
+// as `no-writes`. Such calls are served by replicas when the client is
+// connected to a cluster with the `readOnly` option.
+func (c *Client) FcallRo(name string, keys, args, params any) *sobek.Promise {
+	return c.fcall("fcallRo", name, keys, args, params, redis.UniversalClient.FCallRO)
+}
+
+// fcallMethod is the type of the go-redis FCall and FCallRO methods.
+type fcallMethod func(redis.UniversalClient, context.Context, string, []string, ...any) *redis.Cmd
+
+// fcall calls a Redis function using the provided go-redis method, on
+// behalf of the named method.
+func (c *Client) fcall(method, name string, keys, args, params any, call fcallMethod) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	// Unlike the keys of other methods, those of functions can be empty.
+	var keyArgs []any
+	if arr, ok := keys.([]any); keys != nil && (!ok || len(arr) > 0) {
+		var err error
+		if keyArgs, err = splitKeys(keys); err != nil {
+			reject(fmt.Errorf("invalid %s keys; reason: %w", method, err))
+			return promise
+		}
+	}
+
+	var argArgs []any
+	if args != nil {
+		var ok bool
+		if argArgs, ok = args.([]any); !ok {
+			reject(fmt.Errorf("invalid %s args type: %T; expected array", method, args))
+			return promise
+		}
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(0, argArgs...); err != nil {
+		reject(fmt.Errorf("invalid %s args; reason: %w", method, err))
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		result, err := call(c.redisClient, ctx, name, toStrings(keyArgs), argArgs...).Result()
+		if errors.Is(err, redis.Nil) {
+			resolve(nil)
+			return
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientFunctions(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	// The stub server does not run Lua: loaded libraries are only named
+	// after their shebang, and the "echo" function of any of them replies
+	// with its keys and arguments.
+	var (
+		mu        sync.Mutex
+		libraries = map[string]string{}
+	)
+	rs.RegisterCommandHandler("FUNCTION", func(c *redistest.Connection, args []string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch strings.ToUpper(args[0]) {
+		case "LOAD":
+			code := args[len(args)-1]
+			name := strings.TrimPrefix(strings.SplitN(code, "\n", 2)[0], "#!lua name=")
+			if _, exists := libraries[name]; exists && !strings.EqualFold(args[1], "REPLACE") {
+				c.WriteError(fmt.Errorf("ERR Library '%s' already exists", name))
+				return
+			}
+			libraries[name] = code
+			c.WriteBulkString(name)
+		case "LIST":
+			var reply []any
+			for name, code := range libraries {
+				library := map[string]any{
+					"library_name": name,
+					"engine":       "LUA",
+					"functions": []any{map[string]any{
+						"name": "echo", "description": nil, "flags": []any{"no-writes"},
+					}},
+				}
+				if strings.EqualFold(args[len(args)-1], "WITHCODE") {
+					library["library_code"] = code
+				}
+				reply = append(reply, library)
+			}
+			c.WriteValue(reply)
+		case "DELETE":
+			if _, exists := libraries[args[1]]; !exists {
+				c.WriteError(errors.New("ERR Library not found"))
+				return
+			}
+			delete(libraries, args[1])
+			c.WriteOK()
+		}
+	})
+
+	echo := func(c *redistest.Connection, args []string) {
+		switch args[0] {
+		case "echo":
+			numKeys, _ := strconv.Atoi(args[1])
+			c.WriteValue([]any{args[2 : 2+numKeys], args[2+numKeys:]})
+		case "nothing":
+			c.WriteNull()
+		default:
+			c.WriteError(errors.New("ERR Function not found"))
+		}
+	}
+	rs.RegisterCommandHandler("FCALL", echo)
+	rs.RegisterCommandHandler("FCALL_RO", echo)
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			const code = "#!lua name=mylib\nredis.register_function('echo', function(keys, args) return {keys, args} end)";
+
+			(async () => {
+				expect(await redis.functionLoad(code), "mylib");
+				await expectError(redis.functionLoad(code), "already exists");
+				expect(await redis.functionLoad(code, { replace: true }, { tags: { kind: "load" } }), "mylib");
+
+				const [library] = await redis.functionList();
+				expect([library.name, library.engine, library.code], ["mylib", "LUA", undefined]);
+				expect(library.functions.map((f) => [f.name, f.description, f.flags]), [["echo", "", ["no-writes"]]]);
+				expect((await redis.functionList({ libraryName: "my*", withCode: true }))[0].code, code);
+
+				expect(await redis.fcall("echo", ["a", "b"], ["c", 1]), [["a", "b"], ["c", "1"]]);
+				expect(await redis.fcall("echo", "a"), [["a"], []]);
+				expect(await redis.fcallRo("echo", [], [true]), [[], ["1"]]);
+				expect(await redis.fcallRo("nothing"), null);
+				await expectError(redis.fcall("missing"), "Function not found");
+
+				expect(await redis.functionDelete("mylib"), "OK");
+				expect(await redis.functionList(), []);
+				await expectError(redis.functionDelete("mylib"), "Library not found");
+
+				await expectError(redis.functionLoad(code, { replace: "yes" }), "invalid functionLoad options");
+				await expectError(redis.functionList({ pattern: "my*" }), "invalid functionList options");
+				await expectError(redis.fcall("echo", [1]), "invalid fcall keys");
+				await expectError(redis.fcallRo("echo", [], "a"), "invalid fcallRo args type");
+				await expectError(redis.fcall("echo", [], [{}]), "unsupported type");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"FUNCTION", "load", "replace", "#!lua name=mylib\n" +
+		"redis.register_function('echo', function(keys, args) return {keys, args} end)"})
+	assert.Contains(t, rs.GotCommands(), []string{"FUNCTION", "list", "libraryname", "my*", "withcode"})
+	assert.Contains(t, rs.GotCommands(), []string{"FCALL", "echo", "2", "a", "b", "c", "1"})
+	assert.Contains(t, rs.GotCommands(), []string{"FCALL_RO", "echo", "0", "1"})
+}
@@ -0,0 +1,567 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// geoLocation is a location added to a geospatial index by Geoadd.
+type geoLocation struct {
+	Longitude *float64 `json:"longitude"`
+	Latitude  *float64 `json:"latitude"`
+	Member    string   `json:"member"`
+}
+
+// geoaddOptions holds the options of the Geoadd method.
+type geoaddOptions struct {
+	// Condition is either NX, to only add new members, or XX, to only
+	// update the location of existing members.
+	Condition string `json:"condition,omitempty"`
+
+	// Ch makes Geoadd return the number of members added or updated,
+	// rather than the number of members added.
+	Ch bool `json:"ch,omitempty"`
+}
+
+// Geoadd adds the provided `locations` to the geospatial index stored at
+// `key`, which is created if it does not exist, and returns the number of
+// members added.
+//
+// The locations are either a single object, or an array of objects, of the
+// form `{ longitude: 13.361389, latitude: 38.115556, member: "Palermo" }`.
+// The optional `options` object accepts a `condition` option (NX or XX),
+// and a `ch` option, to count the members whose location was updated too.
+func (c *Client) Geoadd(key string, locations, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &geoaddOptions{}
+	if err := decodeCommandOptions("geoadd", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	condition := strings.ToUpper(opts.Condition)
+	if condition != "" && condition != "NX" && condition != "XX" {
+		reject(fmt.Errorf("invalid geoadd options; reason: unknown condition %q", opts.Condition))
+		return promise
+	}
+
+	values, err := geoLocations(locations)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The go-redis GeoAdd helper supports neither the NX and XX
+		// conditions, nor the CH option.
+		args := []any{"geoadd", key}
+		if condition != "" {
+			args = append(args, condition)
+		}
+		if opts.Ch {
+			args = append(args, "CH")
+		}
+		args = append(args, values...)
+
+		n, err := c.redisClient.Do(ctx, args...).Int64()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
+
+// geoLocations returns the locations passed to Geoadd, either a single
+// object or an array of objects, as a flat list of longitude, latitude and
+// member arguments.
+func geoLocations(locations any) ([]any, error) {
+	var objs []any
+	switch locations := locations.(type) {
+	case map[string]any:
+		objs = []any{locations}
+	case []any:
+		if len(locations) == 0 {
+			return nil, errors.New("invalid geoadd locations; reason: expected at least one location")
+		}
+		objs = locations
+	default:
+		return nil, fmt.Errorf("invalid geoadd locations type: %T; expected object or array", locations)
+	}
+
+	values := make([]any, 0, 3*len(objs))
+	for idx, obj := range objs {
+		if obj == nil {
+			return nil, fmt.Errorf("invalid geoadd location at index %d; reason: expected object", idx)
+		}
+
+		location := geoLocation{}
+		if err := decodeCommandOptions("geoadd location", obj, &location); err != nil {
+			return nil, fmt.Errorf("invalid geoadd location at index %d; reason: %w", idx, err)
+		}
+
+		if location.Longitude == nil || location.Latitude == nil {
+			return nil, fmt.Errorf(
+				"invalid geoadd location at index %d; reason: longitude and latitude are required", idx)
+		}
+
+		values = append(values, *location.Longitude, *location.Latitude, location.Member)
+	}
+
+	return values, nil
+}
+
+// Geopos returns the positions of the provided members of the geospatial
+// index stored at `key`, as `{ longitude, latitude }` objects, or null for
+// the members which do not exist.
+func (c *Client) Geopos(key string, members ...any) *sobek.Promise {
+	members, params := splitCommandParams(members)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if len(members) == 0 {
+		reject(errors.New("geopos requires at least one member"))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, members...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		positions, err := c.redisClient.GeoPos(ctx, key, toStrings(members)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		results := make([]any, 0, len(positions))
+		for _, pos := range positions {
+			if pos == nil {
+				results = append(results, nil)
+				continue
+			}
+
+			results = append(results, map[string]any{"longitude": pos.Longitude, "latitude": pos.Latitude})
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// geoUnitOptions holds the unit option of the Geodist method.
+type geoUnitOptions struct {
+	// Unit is the unit of the distance, either M, the default, KM, FT or
+	// MI.
+	Unit string `json:"unit,omitempty"`
+}
+
+// geoUnit validates a distance unit, and returns it in lower case. It
+// defaults to meters, as it does in Redis.
+func geoUnit(unit string) (string, error) {
+	switch lower := strings.ToLower(unit); lower {
+	case "":
+		return "m", nil
+	case "m", "km", "ft", "mi":
+		return lower, nil
+	default:
+		return "", fmt.Errorf("unknown unit %q", unit)
+	}
+}
+
+// Geodist returns the distance between the provided members of the
+// geospatial index stored at `key`, or null if one of them does not exist.
+//
+// The optional `options` object accepts a `unit` option (M, the default,
+// KM, FT or MI).
+func (c *Client) Geodist(key, member1, member2 string, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &geoUnitOptions{}
+	if err := decodeCommandOptions("geodist", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	unit, err := geoUnit(opts.Unit)
+	if err != nil {
+		reject(fmt.Errorf("invalid geodist options; reason: %w", err))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		distance, err := c.redisClient.GeoDist(ctx, key, member1, member2, unit).Result()
+		if errors.Is(err, redis.Nil) {
+			resolve(nil)
+			return
+		}
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(distance)
+	}()
+
+	return promise
+}
+
+// Geohash returns the geohash strings of the positions of the provided
+// members of the geospatial index stored at `key`, or null for the members
+// which do not exist.
+func (c *Client) Geohash(key string, members ...any) *sobek.Promise {
+	members, params := splitCommandParams(members)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if len(members) == 0 {
+		reject(errors.New("geohash requires at least one member"))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, members...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		hashes, err := c.redisClient.GeoHash(ctx, key, toStrings(members)...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		// A geohash is never empty, go-redis replies with an empty string
+		// for the members which do not exist.
+		results := make([]any, 0, len(hashes))
+		for _, hash := range hashes {
+			if hash == "" {
+				results = append(results, nil)
+				continue
+			}
+
+			results = append(results, hash)
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// geoSearchOptions holds the query of the Geosearch and Geosearchstore
+// methods.
+type geoSearchOptions struct {
+	// Member is the member of the index to search from, as with the
+	// FROMMEMBER option. Longitude and Latitude are the position to search
+	// from otherwise, as with the FROMLONLAT option.
+	Member    *string  `json:"member,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+
+	// Radius is the radius of the circular area to search, as with the
+	// BYRADIUS option. Width and Height are the dimensions of the
+	// rectangular area to search otherwise, as with the BYBOX option.
+	Radius *float64 `json:"radius,omitempty"`
+	Width  *float64 `json:"width,omitempty"`
+	Height *float64 `json:"height,omitempty"`
+
+	// Unit is the unit of the area's dimensions and of the distances,
+	// either M, the default, KM, FT or MI.
+	Unit string `json:"unit,omitempty"`
+
+	// Sort is the order of the results by distance, either ASC or DESC.
+	Sort string `json:"sort,omitempty"`
+
+	// Count limits the number of results. With Any, the search stops as
+	// soon as enough results are found, which are then not the closest.
+	Count int64 `json:"count,omitempty"`
+	Any   bool  `json:"any,omitempty"`
+
+	// WithDist and WithCoord add the distance and the position of the
+	// members to the results of Geosearch.
+	WithDist  bool `json:"withDist,omitempty"`
+	WithCoord bool `json:"withCoord,omitempty"`
+}
+
+// geoSearchQuery validates the query of the named command, and returns it
+// as a go-redis query.
+//
+//nolint:cyclop
+func geoSearchQuery(command string, query any) (*redis.GeoSearchLocationQuery, error) {
+	if query == nil {
+		return nil, fmt.Errorf("%s requires a query object", command)
+	}
+
+	opts := &geoSearchOptions{}
+	if err := decodeCommandOptions(command, query, opts); err != nil {
+		return nil, err
+	}
+
+	unit, unitErr := geoUnit(opts.Unit)
+	sort := strings.ToUpper(opts.Sort)
+
+	fromLonLat := opts.Longitude != nil || opts.Latitude != nil
+	byBox := opts.Width != nil || opts.Height != nil
+
+	var reason string
+	switch {
+	case unitErr != nil:
+		reason = unitErr.Error()
+	case (opts.Member != nil) == fromLonLat:
+		reason = "expected either a member, or a longitude and a latitude"
+	case fromLonLat && (opts.Longitude == nil || opts.Latitude == nil):
+		reason = "longitude and latitude must be provided together"
+	case (opts.Radius != nil) == byBox:
+		reason = "expected either a radius, or a width and a height"
+	case byBox && (opts.Width == nil || opts.Height == nil):
+		reason = "width and height must be provided together"
+	case opts.Radius != nil && *opts.Radius <= 0, byBox && (*opts.Width <= 0 || *opts.Height <= 0):
+		reason = "the dimensions of the searched area must be positive"
+	case sort != "" && sort != "ASC" && sort != "DESC":
+		reason = fmt.Sprintf("unknown sort order %q", opts.Sort)
+	case opts.Count < 0:
+		reason = fmt.Sprintf("count must not be negative, got %d", opts.Count)
+	case opts.Any && opts.Count == 0:
+		reason = "any requires count"
+	}
+
+	if reason != "" {
+		return nil, fmt.Errorf("invalid %s query; reason: %s", command, reason)
+	}
+
+	q := &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Sort:     sort,
+			Count:    int(opts.Count),
+			CountAny: opts.Any,
+		},
+		WithDist:  opts.WithDist,
+		WithCoord: opts.WithCoord,
+	}
+
+	if opts.Member != nil {
+		q.Member = *opts.Member
+	} else {
+		q.Longitude, q.Latitude = *opts.Longitude, *opts.Latitude
+	}
+
+	if opts.Radius != nil {
+		q.Radius, q.RadiusUnit = *opts.Radius, unit
+	} else {
+		q.BoxWidth, q.BoxHeight, q.BoxUnit = *opts.Width, *opts.Height, unit
+	}
+
+	return q, nil
+}
+
+// Geosearch returns the members of the geospatial index stored at `key`
+// within the area described by the provided `query` object, as objects of
+// the form `{ member, distance, longitude, latitude }`.
+//
+// The query holds either the `member` to search from, or its `longitude`
+// and `latitude`, and either the `radius` of the area, or its `width` and
+// `height`, all in the optional `unit` (M, the default, KM, FT or MI). It
+// optionally holds the `sort` order (ASC or DESC), a `count` limit, along
+// with `any`, and the `withDist` and `withCoord` options, without which
+// the results do not hold the distance and position of the members.
+func (c *Client) Geosearch(key string, query, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	q, err := geoSearchQuery("geosearch", query)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		// The go-redis GeoSearchLocation helper expects each result to be
+		// an array, which it is not without the WITHDIST and WITHCOORD
+		// options.
+		if !q.WithDist && !q.WithCoord {
+			members, err := c.redisClient.GeoSearch(ctx, key, &q.GeoSearchQuery).Result()
+			if err != nil {
+				reject(err)
+				return
+			}
+
+			results := make([]any, 0, len(members))
+			for _, member := range members {
+				results = append(results, map[string]any{"member": member})
+			}
+
+			resolve(results)
+			return
+		}
+
+		// The go-redis GeoSearchLocation helper appends the query to the
+		// command's arguments twice, which NewGeoSearchLocationCmd already
+		// does on its own.
+		cmd := redis.NewGeoSearchLocationCmd(ctx, q, "geosearch", key)
+		_ = c.redisClient.Process(ctx, cmd)
+
+		locations, err := cmd.Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		results := make([]any, 0, len(locations))
+		for _, location := range locations {
+			result := map[string]any{"member": location.Name}
+			if q.WithDist {
+				result["distance"] = location.Dist
+			}
+			if q.WithCoord {
+				result["longitude"] = location.Longitude
+				result["latitude"] = location.Latitude
+			}
+
+			results = append(results, result)
+		}
+
+		resolve(results)
+	}()
+
+	return promise
+}
+
+// geosearchstoreOptions holds the options of the Geosearchstore method.
+type geosearchstoreOptions struct {
+	// StoreDist stores the distances of the members as their scores,
+	// rather than their positions.
+	StoreDist bool `json:"storeDist,omitempty"`
+}
+
+// Geosearchstore is like Geosearch, but stores the members found in the
+// geospatial index stored at `source` in a new one, stored at
+// `destination`, and returns their number.
+//
+// The `query` object does not accept the `withDist` and `withCoord`
+// options. The optional `options` object accepts a `storeDist` option, to
+// store the distances of the members, in a sorted set, instead.
+func (c *Client) Geosearchstore(destination, source string, query, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	q, err := geoSearchQuery("geosearchstore", query)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if q.WithDist || q.WithCoord {
+		reject(errors.New("invalid geosearchstore query; reason: withDist and withCoord are not supported"))
+		return promise
+	}
+
+	opts := &geosearchstoreOptions{}
+	if err := decodeCommandOptions("geosearchstore", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		n, err := c.redisClient.GeoSearchStore(ctx, source, destination, &redis.GeoSearchStoreQuery{
+			GeoSearchQuery: q.GeoSearchQuery,
+			StoreDist:      opts.StoreDist,
+		}).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(n)
+	}()
+
+	return promise
+}
@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGeoCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+			const round = (n) => Math.round(n * 1e4) / 1e4;
+
+			(async () => {
+				expect(await redis.geoadd("Sicily", [
+					{ longitude: 13.361389, latitude: 38.115556, member: "Palermo" },
+					{ longitude: 15.087269, latitude: 37.502669, member: "Catania" },
+				]), 2);
+				expect(await redis.geoadd("Sicily", { longitude: 15.09, latitude: 37.5, member: "Catania" },
+					{ condition: "NX" }), 0);
+				expect(await redis.geoadd("Sicily", { longitude: 15.09, latitude: 37.5, member: "Catania" },
+					{ condition: "xx", ch: true }, { tags: { kind: "update" } }), 1);
+				expect(await redis.geoadd("Sicily", { longitude: 15.087269, latitude: 37.502669, member: "Catania" }), 0);
+
+				const positions = await redis.geopos("Sicily", "Palermo", "Atlantis");
+				expect([round(positions[0].longitude), round(positions[0].latitude), positions[1]],
+					[13.3614, 38.1156, null]);
+
+				expect(await redis.geodist("Sicily", "Palermo", "Catania"), 166274.1516);
+				expect(await redis.geodist("Sicily", "Palermo", "Catania", { unit: "KM" }), 166.2742);
+				expect(await redis.geodist("Sicily", "Palermo", "Atlantis"), null);
+
+				expect(await redis.geohash("Sicily", "Palermo", "Atlantis"), ["sqc8b49rny0", null]);
+
+				expect(await redis.geosearch("Sicily", { longitude: 15, latitude: 37, radius: 200, unit: "km" }),
+					[{ member: "Palermo" }, { member: "Catania" }]);
+				const nearest = await redis.geosearch("Sicily",
+					{ longitude: 15, latitude: 37, radius: 200, unit: "km", sort: "ASC", withDist: true });
+				expect(nearest.map((r) => [r.member, r.distance]), [["Catania", 56.4413], ["Palermo", 190.4424]]);
+
+				const found = await redis.geosearch("Sicily",
+					{ member: "Palermo", width: 400, height: 400, unit: "km", count: 1, withCoord: true });
+				expect([found[0].member, round(found[0].longitude), round(found[0].latitude)],
+					["Palermo", 13.3614, 38.1156]);
+
+				expect(await redis.geosearchstore("near", "Sicily",
+					{ member: "Catania", radius: 100, unit: "km" }, { storeDist: true }), 1);
+				expect(await redis.sendCommand("ZSCORE", "near", "Catania"), "0");
+
+				await expectError(redis.geoadd("Sicily", [{ longitude: 1, member: "a" }]),
+					"longitude and latitude are required");
+				await expectError(redis.geoadd("Sicily", []), "expected at least one location");
+				await expectError(redis.geoadd("Sicily", { longitude: 1, latitude: 1, member: "a" },
+					{ condition: "GT" }), "unknown condition");
+				await expectError(redis.geoadd("Sicily", { longitude: 1, latitude: 90, member: "a" }),
+					"invalid longitude,latitude pair");
+				await expectError(redis.geodist("Sicily", "Palermo", "Catania", { unit: "yd" }), "unknown unit");
+				await expectError(redis.geopos("Sicily"), "requires at least one member");
+				await expectError(redis.geosearch("Sicily", { radius: 1 }),
+					"expected either a member, or a longitude and a latitude");
+				await expectError(redis.geosearch("Sicily", { member: "Palermo", longitude: 1, latitude: 1, radius: 1 }),
+					"expected either a member, or a longitude and a latitude");
+				await expectError(redis.geosearch("Sicily", { member: "Palermo", width: 1 }),
+					"width and height must be provided together");
+				await expectError(redis.geosearch("Sicily", { member: "Palermo", radius: 1, any: true }),
+					"any requires count");
+				await expectError(redis.geosearch("Sicily", { member: "Palermo", radius: 1, sort: "up" }),
+					"unknown sort order");
+				await expectError(redis.geosearchstore("near", "Sicily", { member: "Palermo", radius: 1, withDist: true }),
+					"not supported");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"GEOADD", "Sicily", "XX", "CH", "15.09", "37.5", "Catania"})
+	assert.Contains(t, rs.GotCommands(), []string{"GEODIST", "Sicily", "Palermo", "Catania", "m"})
+	assert.Contains(t, rs.GotCommands(), []string{
+		"GEOSEARCH", "Sicily", "frommember", "Palermo", "bybox", "400", "400", "km", "count", "1", "withcoord",
+	})
+	assert.Contains(t, rs.GotCommands(), []string{
+		"GEOSEARCHSTORE", "near", "Sicily", "frommember", "Catania", "byradius", "100", "km", "storedist",
+	})
+}
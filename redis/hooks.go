@@ -0,0 +1,251 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/common"
+)
+
+// jsHook holds the JS callbacks of a hook registered using `client.addHook`.
+type jsHook struct {
+	before sobek.Callable
+	after  sobek.Callable
+}
+
+// AddHook registers a hook intercepting the commands processed by the client.
+//
+// The hook is an object with optional `before` and `after` methods:
+//   - `before(cmd, args)` is called before the command is sent to the server.
+//   - `after(cmd, args, result, err, durationMs)` is called once the command's
+//     reply was received, or once it failed.
+//
+// Both are called on the VU's event loop, and the command only proceeds once
+// they have returned. If `before` throws, the command is not sent, and its
+// promise is rejected with the thrown error. If `after` throws, the command's
+// promise is rejected with the thrown error.
+//
+// Hooks only apply to the commands issued after they were registered.
+func (c *Client) AddHook(hook sobek.Value) {
+	rt := c.vu.Runtime()
+
+	if common.IsNullish(hook) {
+		common.Throw(rt, errors.New("addHook expects a hook object argument"))
+	}
+
+	obj := hook.ToObject(rt)
+	h := jsHook{
+		before: hookMethod(rt, obj, "before"),
+		after:  hookMethod(rt, obj, "after"),
+	}
+
+	if h.before == nil && h.after == nil {
+		common.Throw(rt, errors.New("hook must define at least one of the before and after methods"))
+	}
+
+	c.hooks = append(c.hooks, h)
+}
+
+// hookMethod returns the hook object's method with the provided name, or nil
+// if it is not defined.
+func hookMethod(rt *sobek.Runtime, hook *sobek.Object, name string) sobek.Callable {
+	value := hook.Get(name)
+	if common.IsNullish(value) {
+		return nil
+	}
+
+	method, ok := sobek.AssertFunction(value)
+	if !ok {
+		common.Throw(rt, fmt.Errorf("hook's %s property must be a function", name))
+	}
+
+	return method
+}
+
+// hookCallKey is the context key holding the *hookCall of the command the
+// context relates to.
+type hookCallKey struct{}
+
+// hookCall holds what is needed to call the JS hooks of a single command
+// method call.
+//
+// Callbacks to the event loop can only be reserved from its thread, while
+// redis.Hook functions are called from the goroutine processing a command.
+// A hookCall thus holds event loop callbacks reserved beforehand, when the
+// command method was called, for each phase of the hooks.
+type hookCall struct {
+	hooks []jsHook
+
+	enqueueBefore func(func() error)
+	enqueueAfter  func(func() error)
+	beforeOnce    sync.Once
+	afterOnce     sync.Once
+
+	// claimed is set by the first redis.Hook call the hookCall is used in.
+	// Commands issued by go-redis while processing the command method's one,
+	// such as those initializing a new connection, are thus ignored.
+	claimed atomic.Bool
+}
+
+// newHookCall returns a hookCall for the currently registered hooks.
+//
+// It must be called from the event loop's thread.
+func (c *Client) newHookCall() *hookCall {
+	return &hookCall{
+		hooks:         append([]jsHook(nil), c.hooks...),
+		enqueueBefore: c.vu.RegisterCallback(),
+		enqueueAfter:  c.vu.RegisterCallback(),
+	}
+}
+
+// release enqueues no-op callbacks for the phases which did not run, so that
+// the event loop does not wait for them.
+func (hc *hookCall) release() {
+	noop := func() error { return nil }
+	hc.beforeOnce.Do(func() { hc.enqueueBefore(noop) })
+	hc.afterOnce.Do(func() { hc.enqueueAfter(noop) })
+}
+
+// runBefore calls the hooks' `before` methods on the event loop, and waits
+// for them to return.
+func (hc *hookCall) runBefore(ctx context.Context, rt *sobek.Runtime, name string, args []any) error {
+	var err error
+	hc.beforeOnce.Do(func() {
+		err = runOnEventLoop(ctx, hc.enqueueBefore, func() error {
+			for _, h := range hc.hooks {
+				if h.before == nil {
+					continue
+				}
+
+				if _, err := h.before(sobek.Undefined(), rt.ToValue(name), rt.ToValue(args)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	return err
+}
+
+// runAfter calls the hooks' `after` methods on the event loop, and waits
+// for them to return.
+func (hc *hookCall) runAfter(
+	ctx context.Context, rt *sobek.Runtime, cmd redis.Cmder, cmdErr error, duration time.Duration,
+) error {
+	var err error
+	hc.afterOnce.Do(func() {
+		err = runOnEventLoop(ctx, hc.enqueueAfter, func() error {
+			errValue := sobek.Null()
+			if cmdErr != nil {
+				errValue = rt.ToValue(cmdErr.Error())
+			}
+
+			for _, h := range hc.hooks {
+				if h.after == nil {
+					continue
+				}
+
+				_, err := h.after(
+					sobek.Undefined(),
+					rt.ToValue(cmd.Name()),
+					rt.ToValue(cmd.Args()[1:]),
+					rt.ToValue(cmdResult(cmd)),
+					errValue,
+					rt.ToValue(float64(duration)/float64(time.Millisecond)),
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	return err
+}
+
+// runOnEventLoop enqueues `fn` using the provided event loop callback, and
+// waits for it to have run, or for the context to be done.
+func runOnEventLoop(ctx context.Context, enqueue func(func() error), fn func() error) error {
+	errCh := make(chan error, 1)
+	enqueue(func() error {
+		errCh <- fn()
+
+		// Errors are returned to the command, rather than
+		// interrupting the iteration.
+		return nil
+	})
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cmdResult returns the value of the reply to the provided command, as
+// returned by its `Val` method, or nil if it has none.
+func cmdResult(cmd redis.Cmder) any {
+	val := reflect.ValueOf(cmd).MethodByName("Val")
+	if !val.IsValid() || val.Type().NumIn() != 0 || val.Type().NumOut() != 1 {
+		return nil
+	}
+
+	return val.Call(nil)[0].Interface()
+}
+
+// jsHooksHook is a redis.Hook calling the JS hooks registered using
+// `client.addHook`.
+type jsHooksHook struct {
+	client *Client
+}
+
+var _ redis.Hook = &jsHooksHook{}
+
+// DialHook implements the redis.Hook interface.
+func (h *jsHooksHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements the redis.Hook interface, and calls the JS hooks
+// around the processing of the command.
+func (h *jsHooksHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		call, ok := ctx.Value(hookCallKey{}).(*hookCall)
+		if !ok || !call.claimed.CompareAndSwap(false, true) {
+			return next(ctx, cmd)
+		}
+
+		rt := h.client.vu.Runtime()
+
+		if err := call.runBefore(ctx, rt, cmd.Name(), cmd.Args()[1:]); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		duration := time.Since(start)
+
+		if hookErr := call.runAfter(ctx, rt, cmd, err, duration); hookErr != nil {
+			return hookErr
+		}
+
+		return err
+	}
+}
+
+// ProcessPipelineHook implements the redis.Hook interface.
+func (h *jsHooksHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAddHook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hooks are called around commands", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+			c.WriteBulkString("bar")
+		})
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+				const calls = [];
+
+				redis.addHook({
+					before: (cmd, args) => { calls.push('before ' + cmd + ' ' + args.join(',')) },
+					after: (cmd, args, result, err, durationMs) => {
+						if (typeof durationMs !== 'number' || durationMs < 0) {
+							throw 'unexpected duration: ' + durationMs
+						}
+						calls.push('after ' + cmd + ' ' + args.join(',') + ' ' + result + ' ' + err)
+					},
+				});
+				redis.addHook({
+					after: (cmd) => { calls.push('second after ' + cmd) },
+				});
+
+				redis.get("foo")
+					.then(res => { calls.push('resolved ' + res) })
+					.then(() => redis.incr("foo"))
+					.catch(err => { calls.push('rejected') })
+					.then(() => {
+						const expected = [
+							'before get foo',
+							'after get foo bar null',
+							'second after get',
+							'resolved bar',
+							'before incr foo',
+							'after incr foo 0 unknown command',
+							'second after incr',
+							'rejected',
+						];
+						if (JSON.stringify(calls) !== JSON.stringify(expected)) {
+							throw 'unexpected hook calls: ' + JSON.stringify(calls)
+						}
+					})
+			`, rs.Addr()))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Equal(t, [][]string{
+			{"HELLO", "2"},
+			{"GET", "foo"},
+			{"INCR", "foo"},
+		}, rs.GotCommands())
+	})
+
+	t.Run("throwing in before aborts the command", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				redis.addHook({
+					before: (cmd, args) => { if (args[0] === 'forbidden') { throw new Error('simulated fault') } },
+				});
+
+				redis.sendCommand("PING", "allowed")
+					.then(() => redis.sendCommand("PING", "forbidden"))
+					.then(
+						res => { throw 'expected the command to be aborted' },
+						err => { if (!String(err).includes('simulated fault')) { throw 'unexpected error: ' + err } }
+					)
+			`, rs.Addr()))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Equal(t, [][]string{
+			{"HELLO", "2"},
+			{"PING", "allowed"},
+		}, rs.GotCommands())
+	})
+
+	t.Run("throwing in after rejects the command", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				redis.addHook({
+					after: (cmd, args, result) => { if (result !== 'expected') { throw new Error('unexpected reply') } },
+				});
+
+				redis.sendCommand("PING", "expected")
+					.then(() => redis.sendCommand("PING", "other"))
+					.then(
+						res => { throw 'expected the command to be rejected' },
+						err => { if (!String(err).includes('unexpected reply')) { throw 'unexpected error: ' + err } }
+					)
+			`, rs.Addr()))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Equal(t, 2, rs.HandledCommandsCount())
+	})
+
+	t.Run("invalid hooks are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			name, hook, expErr string
+		}{
+			{
+				name:   "missing hook",
+				hook:   "",
+				expErr: "addHook expects a hook object argument",
+			},
+			{
+				name:   "no methods",
+				hook:   "{}",
+				expErr: "hook must define at least one of the before and after methods",
+			},
+			{
+				name:   "non-function method",
+				hook:   "{ before: 42 }",
+				expErr: "hook's before property must be a function",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				ts := newTestSetup(t)
+
+				_, err := ts.rt.RunString(fmt.Sprintf(`
+					const redis = new Client('redis://localhost:6379');
+					redis.addHook(%s);
+				`, tc.hook))
+
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expErr)
+			})
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package redis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// defaultHLLTolerance is the relative error tolerated by default when
+// verifying the cardinality estimated by a HyperLogLog: three times its
+// standard error of 0.81%.
+const defaultHLLTolerance = 3 * 0.0081
+
+// Pfadd adds the provided elements to the HyperLogLog stored at `key`,
+// which is created if it does not exist, and returns whether its estimated
+// cardinality changed.
+//
+// The `elements` argument is either a single element, or an array of
+// elements. The optional `options` object accepts a `track` option which,
+// when true, also adds the elements to a set tracked locally, shared by the
+// clients of all VUs, against which Pfcount can verify the estimated
+// cardinality. As it holds every element, tracking is meant for correctness
+// checks rather than for large cardinalities.
+//
+// The tracked sets are specific to the server(s) and database the client is
+// connected to, but are not cleared when the HyperLogLogs are deleted or
+// expire. The `reset` option clears the set tracked for the key before the
+// elements are added, for instance when the key is first written to by an
+// iteration.
+func (c *Client) Pfadd(key string, elements, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	opts := &pfaddOptions{}
+	if err := decodeCommandOptions("pfadd", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	values, ok := elements.([]any)
+	if !ok {
+		values = []any{elements}
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, values...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		changed, err := c.redisClient.PFAdd(ctx, key, values...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		if opts.Reset {
+			c.hllSets.reset(c.hllKeys(key)[0])
+		}
+		if opts.Track {
+			c.hllSets.add(c.hllKeys(key)[0], toStrings(values))
+		}
+
+		resolve(changed == 1)
+	}()
+
+	return promise
+}
+
+// Pfcount returns the cardinality estimated by the HyperLogLog stored at
+// the provided keys, or by their union if several keys are provided.
+//
+// The `keys` argument is either a single key, or an array of keys. The
+// optional `options` object accepts a `verify` option which, when true,
+// rejects the promise with an error if the estimate deviates from the
+// cardinality of the union of the sets tracked by Pfadd for the keys by
+// more than the relative `tolerance` option, which defaults to 2.43%, three
+// times the standard error of Redis' HyperLogLogs.
+func (c *Client) Pfcount(keys, options, params any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	keyArgs, err := splitKeys(keys)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts := &pfcountOptions{}
+	if err := decodeCommandOptions("pfcount", options, opts); err != nil {
+		reject(err)
+		return promise
+	}
+
+	tolerance := defaultHLLTolerance
+	if opts.Tolerance != nil {
+		tolerance = *opts.Tolerance
+	}
+	if tolerance < 0 {
+		reject(fmt.Errorf("invalid pfcount options; reason: tolerance must not be negative, got %v", tolerance))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		keyStrs := toStrings(keyArgs)
+
+		estimate, err := c.redisClient.PFCount(ctx, keyStrs...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		if opts.Verify {
+			if err := verifyCardinality(estimate, c.hllSets.count(c.hllKeys(keyStrs...)), tolerance); err != nil {
+				reject(err)
+				return
+			}
+		}
+
+		resolve(estimate)
+	}()
+
+	return promise
+}
+
+// verifyCardinality returns an error if the estimated cardinality deviates
+// from the exact one by more than the relative tolerance.
+func verifyCardinality(estimate, exact int64, tolerance float64) error {
+	deviation := math.Abs(float64(estimate - exact))
+	if exact > 0 {
+		deviation /= float64(exact)
+	}
+
+	if deviation > tolerance {
+		return fmt.Errorf(
+			"pfcount estimated a cardinality of %d, deviating from the tracked cardinality of %d "+
+				"by more than the tolerance of %.2f%%", estimate, exact, tolerance*100)
+	}
+
+	return nil
+}
+
+// Pfmerge merges the HyperLogLogs stored at the provided source keys into
+// the one stored at `destination`, which is created if it does not exist.
+//
+// The sets tracked by Pfadd for the source keys are merged likewise.
+func (c *Client) Pfmerge(destination string, sources ...any) *sobek.Promise {
+	sources, params := splitCommandParams(sources)
+
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.isSupportedType(1, sources...); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	go func() {
+		defer done()
+
+		sourceStrs := toStrings(sources)
+
+		status, err := c.redisClient.PFMerge(ctx, destination, sourceStrs...).Result()
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		c.hllSets.merge(c.hllKeys(destination)[0], c.hllKeys(sourceStrs...))
+
+		resolve(status)
+	}()
+
+	return promise
+}
+
+// pfaddOptions holds the options of the Pfadd method.
+type pfaddOptions struct {
+	// Track adds the elements to the set tracked for the key.
+	Track bool `json:"track,omitempty"`
+
+	// Reset clears the set tracked for the key beforehand.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// pfcountOptions holds the options of the Pfcount method.
+type pfcountOptions struct {
+	// Verify verifies the estimated cardinality against the tracked one.
+	Verify bool `json:"verify,omitempty"`
+
+	// Tolerance is the relative deviation tolerated by the verification.
+	Tolerance *float64 `json:"tolerance,omitempty"`
+}
+
+// hllKey identifies a HyperLogLog, by the server(s) and the database it
+// is stored on, and by its key.
+type hllKey struct {
+	server string
+	key    string
+}
+
+// hllKeys returns the hllKeys of the HyperLogLogs stored at the provided
+// keys, on the server(s) and in the database the client is connected to.
+func (c *Client) hllKeys(keys ...string) []hllKey {
+	server := fmt.Sprintf("%s/%s/%d", c.redisOptions.MasterName, strings.Join(c.redisOptions.Addrs, ","), c.redisOptions.DB)
+
+	hllKeys := make([]hllKey, 0, len(keys))
+	for _, key := range keys {
+		hllKeys = append(hllKeys, hllKey{server: server, key: key})
+	}
+
+	return hllKeys
+}
+
+// hllTracker holds the sets of elements added to HyperLogLogs by Pfadd,
+// when tracked, indexed by hllKey.
+//
+// The sets are not updated when the HyperLogLogs are deleted, expire, or
+// are otherwise modified without Pfadd or Pfmerge. Such sets are reset by
+// Pfadd's `reset` option.
+type hllTracker struct {
+	mu   sync.Mutex
+	sets map[hllKey]map[string]struct{}
+}
+
+// add adds the provided elements to the set tracked for the key.
+func (t *hllTracker) add(key hllKey, elements []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sets == nil {
+		t.sets = make(map[hllKey]map[string]struct{})
+	}
+
+	set, ok := t.sets[key]
+	if !ok {
+		set = make(map[string]struct{}, len(elements))
+		t.sets[key] = set
+	}
+
+	for _, element := range elements {
+		set[element] = struct{}{}
+	}
+}
+
+// reset stops tracking the set of the key.
+func (t *hllTracker) reset(key hllKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.sets, key)
+}
+
+// count returns the cardinality of the union of the sets tracked for the
+// provided keys.
+func (t *hllTracker) count(keys []hllKey) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(keys) == 1 {
+		return int64(len(t.sets[keys[0]]))
+	}
+
+	union := make(map[string]struct{})
+	for _, key := range keys {
+		for element := range t.sets[key] {
+			union[element] = struct{}{}
+		}
+	}
+
+	return int64(len(union))
+}
+
+// merge adds the elements of the sets tracked for the source keys to the
+// set tracked for the destination key.
+func (t *hllTracker) merge(destination hllKey, sources []hllKey) {
+	t.mu.Lock()
+	var elements []string
+	for _, source := range sources {
+		for element := range t.sets[source] {
+			elements = append(elements, element)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(elements) > 0 {
+		t.add(destination, elements)
+	}
+}
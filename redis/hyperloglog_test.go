@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientHyperLogLogCommands(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	// The emulated HyperLogLogs count exactly, so that an estimate off by
+	// 2% is simulated for the "skewed" key.
+	rs.RegisterCommandHandler("PFCOUNT", func(c *redistest.Connection, args []string) {
+		if args[0] == "skewed" {
+			c.WriteInteger(102)
+			return
+		}
+
+		c.WriteError(errors.New("ERR unexpected key"))
+	})
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+
+			(async () => {
+				const visitors = [];
+				for (let i = 0; i < 100; i++) {
+					visitors.push("visitor-" + i);
+				}
+
+				expect(await redis.pfadd("skewed", visitors, { track: true }, { tags: { kind: "tracked" } }), true);
+				expect(await redis.pfadd("skewed", "visitor-1", { track: true }), false);
+				expect(await redis.pfadd("untracked", [1, 2, 3]), true);
+
+				expect(await redis.pfcount("skewed"), 102);
+				expect(await redis.pfcount("skewed", { verify: true }), 102);
+				expect(await redis.pfcount("skewed", { verify: true, tolerance: 0.02 }), 102);
+				await expectError(redis.pfcount("skewed", { verify: true, tolerance: 0.01 }),
+					"deviating from the tracked cardinality of 100 by more than the tolerance of 1.00%%");
+
+				expect(await redis.pfmerge("merged", "skewed", "untracked"), "OK");
+
+				await expectError(redis.pfadd("skewed", "a", { track: "yes" }), "invalid pfadd options");
+				await expectError(redis.pfcount("skewed", { track: true }), "invalid pfcount options");
+				await expectError(redis.pfcount("skewed", null, { verify: true }), "invalid command parameters");
+				await expectError(redis.pfcount("skewed", { tolerance: -1 }), "must not be negative");
+				await expectError(redis.pfadd("skewed", [{}]), "unsupported type");
+				await expectError(redis.pfcount([]), "expected at least one key");
+			})()
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Contains(t, rs.GotCommands(), []string{"PFMERGE", "merged", "skewed", "untracked"})
+}
+
+func TestClientHyperLogLogTrackingSharedByVUs(t *testing.T) {
+	t.Parallel()
+
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	// Each VU gets its own instance of the same root module, as in k6.
+	rm := New()
+	vus := []testSetup{newTestSetupWithModule(t, rm), newTestSetupWithModule(t, rm)}
+
+	run := func(ts testSetup, script string) {
+		t.Helper()
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`{
+				const redis = new Client('redis://%s');
+				%s
+			}`, rs.Addr(), script))
+
+			return err
+		})
+		require.NoError(t, gotScriptErr)
+	}
+
+	run(vus[0], `redis.pfadd("visitors", ["a", "b"], { track: true });`)
+	run(vus[1], `redis.pfadd("visitors", "c", { track: true });`)
+
+	// The HyperLogLog holds the elements added by both VUs, and so does
+	// the tracked set it is verified against.
+	run(vus[1], `
+		redis.pfcount("visitors", { verify: true, tolerance: 0 }).then((count) => {
+			if (count !== 3) { throw 'unexpected count: ' + count }
+		});
+	`)
+}
+
+func TestClientHyperLogLogTrackingScope(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+	other := redistest.RunT(t)
+	other.UseKeyspace(redistest.NewKeyspace())
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+			const otherRedis = new Client('redis://%s');
+
+			(async () => {
+				// The sets tracked for the same key on different servers are distinct.
+				await redis.pfadd("visitors", ["a", "b"], { track: true });
+				await otherRedis.pfadd("visitors", "c", { track: true });
+				expect(await redis.pfcount("visitors", { verify: true, tolerance: 0 }), 2);
+				expect(await otherRedis.pfcount("visitors", { verify: true, tolerance: 0 }), 1);
+
+				// The tracked sets outlive their keys, until they are reset.
+				await redis.del("visitors");
+				await redis.pfadd("visitors", "d", { track: true });
+				await expectError(redis.pfcount("visitors", { verify: true, tolerance: 0 }),
+					"deviating from the tracked cardinality of 3");
+				await redis.del("visitors");
+				await redis.pfadd("visitors", "d", { track: true, reset: true });
+				expect(await redis.pfcount("visitors", { verify: true, tolerance: 0 }), 1);
+			})()
+		`, rs.Addr(), other.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+}
+
+func TestHLLTracker(t *testing.T) {
+	t.Parallel()
+
+	key := func(key string) hllKey { return hllKey{server: "server", key: key} }
+	keys := func(names ...string) []hllKey {
+		keys := make([]hllKey, 0, len(names))
+		for _, name := range names {
+			keys = append(keys, key(name))
+		}
+		return keys
+	}
+
+	var tracker hllTracker
+	tracker.add(key("a"), []string{"1", "2", "3"})
+	tracker.add(key("b"), []string{"3", "4"})
+	tracker.add(hllKey{server: "other", key: "a"}, []string{"5"})
+
+	assert.Equal(t, int64(3), tracker.count(keys("a")))
+	assert.Equal(t, int64(4), tracker.count(keys("a", "b", "missing")))
+	assert.Equal(t, int64(0), tracker.count(keys("missing")))
+
+	tracker.merge(key("c"), keys("b", "missing"))
+	tracker.merge(key("d"), keys("missing"))
+	assert.Equal(t, int64(2), tracker.count(keys("c")))
+	assert.NotContains(t, tracker.sets, key("d"))
+
+	tracker.reset(key("a"))
+	assert.Equal(t, int64(0), tracker.count(keys("a")))
+	assert.Equal(t, int64(1), tracker.count([]hllKey{{server: "other", key: "a"}}))
+
+	require.NoError(t, verifyCardinality(0, 0, 0))
+	require.NoError(t, verifyCardinality(1010, 1000, 0.01))
+	require.Error(t, verifyCardinality(1, 0, 0.5))
+	require.Error(t, verifyCardinality(980, 1000, 0.01))
+}
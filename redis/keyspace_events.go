@@ -0,0 +1,562 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/promises"
+	"go.k6.io/k6/v2/metrics"
+)
+
+// eventTagName is the name of the tag holding the type of the keyspace
+// event a metric sample relates to.
+const eventTagName = "event"
+
+// keyspaceEventsOptions holds the options of `client.onKeyspaceEvent`.
+type keyspaceEventsOptions struct {
+	// DB is the database whose keys are observed. It defaults to the
+	// database the client is connected to.
+	DB *int `json:"db,omitempty"`
+
+	// NotifyKeyspaceEvents, if set, is set as the server's
+	// notify-keyspace-events parameter before subscribing to the events.
+	NotifyKeyspaceEvents string `json:"notifyKeyspaceEvents,omitempty"`
+}
+
+// OnKeyspaceEvent subscribes to the keyspace notifications published by the
+// redis server for the keys matching the provided glob-style pattern, and
+// calls the provided callback with each of the events of the provided types.
+//
+// The events argument is either an event type, such as `expired`, an array
+// of event types, or null, to receive events of any type. The options object
+// accepts a `db` property, the database whose keys are observed, and a
+// `notifyKeyspaceEvents` property, which is set as the server's
+// notify-keyspace-events parameter before subscribing, such as `Kx`.
+//
+// The notifications are received on the keyspace channels of the keys if
+// the server publishes them (K flag), and on the keyevent channels of the
+// events otherwise (E flag). The promise rejects if the server publishes
+// neither. Once subscribed, the promise resolves to an object whose `stop`
+// method unsubscribes.
+//
+// The callback is called on the VU's event loop with an event object for
+// each notification, holding the `key` it relates to, the `type` of the
+// event, and the `db` the key belongs to. If the callback throws, the
+// subscription ends, and the error is propagated to the event loop.
+//
+// Each event is counted by the redis_keyspace_events metric. When expired
+// events are observed, the time-to-live of the keys is looked up in the
+// background using the PTTL command when an expire event is received for
+// them, and the time elapsed between their expected expiration and the
+// expired event is emitted as the redis_keyspace_expiry_lag metric.
+//
+// The subscription ends once the VU is stopped. Until then, the VU's
+// iteration does not end, unless `stop` is called.
+//
+// Cluster clients are not supported, as the nodes of a cluster only publish
+// the notifications of their own keys.
+func (c *Client) OnKeyspaceEvent(pattern string, events any, onEvent sobek.Value, options any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	callback, ok := sobek.AssertFunction(onEvent)
+	if !ok {
+		reject(errors.New("onKeyspaceEvent expects a callback function argument"))
+		return promise
+	}
+
+	types, err := readKeyspaceEventTypes(events)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := readKeyspaceEventsOptions(options)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if _, ok := c.redisClient.(*redis.ClusterClient); ok {
+		reject(errors.New("keyspace events are not supported by cluster clients"))
+		return promise
+	}
+
+	db := c.redisOptions.DB
+	if opts.DB != nil {
+		db = *opts.DB
+	}
+
+	// The commands the listener sends are not the user's, so they are not
+	// accounted for in the command metrics.
+	ctx := withInternalCommands(c.vu.Context())
+	enqueue := c.vu.RegisterCallback()
+
+	go func() {
+		l, err := c.listenKeyspaceEvents(ctx, pattern, types, db, opts)
+		if err != nil {
+			enqueue(func() error { return nil })
+			reject(err)
+			return
+		}
+
+		stopOnDone := context.AfterFunc(ctx, l.Stop)
+		defer stopOnDone()
+
+		resolve(l)
+		l.run(ctx, enqueue, callback)
+	}()
+
+	return promise
+}
+
+// keyspaceListener is a subscription to keyspace notifications, exposed to
+// JS as the object the promise returned by `client.onKeyspaceEvent`
+// resolves to.
+type keyspaceListener struct {
+	client *Client
+	pubsub *redis.PubSub
+
+	// pattern is the pattern of the observed keys, and types the types of
+	// the events to call the callback with. A nil types matches them all.
+	pattern string
+	types   []string
+
+	// keyevent is true if the listener is subscribed to the keyevent
+	// channels, and false if it is subscribed to the keyspace channels.
+	keyevent bool
+
+	// expirations holds the expirations of the observed keys, as looked
+	// up when receiving their expire event. It holds at most
+	// maxTrackedExpirations keys, and is guarded by mu.
+	mu          sync.Mutex
+	expirations map[string]expiration
+	seq         uint64
+
+	// lookups limits the number of concurrent expiration lookups to its
+	// capacity.
+	lookups chan struct{}
+
+	once sync.Once
+}
+
+// maxTrackedExpirations is the maximum number of keys whose expiration a
+// keyspace listener tracks at a time.
+const maxTrackedExpirations = 10000
+
+// maxExpirationLookups is the maximum number of PTTL commands a keyspace
+// listener sends concurrently to look up the expiration of keys.
+const maxExpirationLookups = 8
+
+// staleExpirationAge is the time after which the expired event of a key is
+// assumed to have been missed, and its expiration is no longer tracked.
+const staleExpirationAge = time.Minute
+
+// expiration is the tracked expiration of a key.
+type expiration struct {
+	// seq identifies the expire event the expiration is tracked for.
+	seq uint64
+
+	// at is the time at which the key is expected to expire. It is zero
+	// until the expiration was looked up.
+	at time.Time
+}
+
+// Stop unsubscribes from the keyspace notifications.
+func (l *keyspaceListener) Stop() {
+	l.once.Do(func() {
+		_ = l.pubsub.Close()
+	})
+}
+
+// listenKeyspaceEvents configures the server's keyspace notifications if
+// requested, and subscribes to the channels they are published on.
+func (c *Client) listenKeyspaceEvents(
+	ctx context.Context, pattern string, types []string, db int, opts *keyspaceEventsOptions,
+) (*keyspaceListener, error) {
+	if opts.NotifyKeyspaceEvents != "" {
+		err := c.redisClient.ConfigSet(ctx, "notify-keyspace-events", opts.NotifyKeyspaceEvents).Err()
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure keyspace notifications; reason: %w", err)
+		}
+	}
+
+	// Servers which do not allow reading their configuration are assumed
+	// to publish notifications on the keyspace channels.
+	keyevent := false
+	if config, err := c.redisClient.ConfigGet(ctx, "notify-keyspace-events").Result(); err == nil {
+		switch flags := config["notify-keyspace-events"]; {
+		case strings.Contains(flags, "K"):
+		case strings.Contains(flags, "E"):
+			keyevent = true
+		default:
+			return nil, errors.New("keyspace notifications are disabled on the server; " +
+				"they can be enabled using the notifyKeyspaceEvents option")
+		}
+	}
+
+	channel := fmt.Sprintf("__keyspace@%d__:%s", db, pattern)
+	if keyevent {
+		channel = fmt.Sprintf("__keyevent@%d__:*", db)
+	}
+
+	pubsub := c.redisClient.PSubscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("unable to subscribe to keyspace notifications; reason: %w", err)
+	}
+
+	return &keyspaceListener{
+		client:      c,
+		pubsub:      pubsub,
+		pattern:     pattern,
+		types:       types,
+		keyevent:    keyevent,
+		expirations: make(map[string]expiration),
+		lookups:     make(chan struct{}, maxExpirationLookups),
+	}, nil
+}
+
+// run receives the notifications, and calls the callback with the events
+// they describe, until the subscription ends.
+//
+// As for monitors, a single event loop callback is reserved at a time, and
+// the next one is reserved from the event loop by the previous one. Once the
+// provided context is done, the event loop no longer runs the callbacks, so
+// the subscription ends without waiting for the next one.
+func (l *keyspaceListener) run(ctx context.Context, enqueue func(func() error), callback sobek.Callable) {
+	rt := l.client.vu.Runtime()
+	next := make(chan func(func() error), 1)
+
+	for msg := range l.pubsub.Channel() {
+		event, ok := l.parse(msg)
+		if !ok {
+			continue
+		}
+
+		l.observe(ctx, event)
+
+		if l.types != nil && !slices.Contains(l.types, event.Type) {
+			continue
+		}
+
+		enqueue(func() error {
+			next <- l.client.vu.RegisterCallback()
+
+			if _, err := callback(sobek.Undefined(), rt.ToValue(event.toMap())); err != nil {
+				l.Stop()
+				return err
+			}
+
+			return nil
+		})
+
+		select {
+		case enqueue = <-next:
+		case <-ctx.Done():
+			l.Stop()
+			return
+		}
+	}
+
+	enqueue(func() error { return nil })
+}
+
+// keyspaceEvent is an event described by a keyspace notification.
+type keyspaceEvent struct {
+	Key  string
+	Type string
+	DB   int
+}
+
+// toMap returns the event object the callback is called with.
+func (e keyspaceEvent) toMap() map[string]any {
+	return map[string]any{"key": e.Key, "type": e.Type, "db": e.DB}
+}
+
+// parse returns the event described by the provided notification, and
+// false if it is not a notification about an observed key.
+func (l *keyspaceListener) parse(msg *redis.Message) (keyspaceEvent, bool) {
+	prefix := "__keyspace@"
+	if l.keyevent {
+		prefix = "__keyevent@"
+	}
+
+	rest, ok := strings.CutPrefix(msg.Channel, prefix)
+	if !ok {
+		return keyspaceEvent{}, false
+	}
+
+	dbStr, name, ok := strings.Cut(rest, "__:")
+	if !ok {
+		return keyspaceEvent{}, false
+	}
+
+	db, err := strconv.Atoi(dbStr)
+	if err != nil {
+		return keyspaceEvent{}, false
+	}
+
+	if !l.keyevent {
+		return keyspaceEvent{Key: name, Type: msg.Payload, DB: db}, true
+	}
+
+	// The server publishes the events of all keys on the keyevent channels.
+	if !matchPattern(l.pattern, msg.Payload) {
+		return keyspaceEvent{}, false
+	}
+
+	return keyspaceEvent{Key: msg.Payload, Type: name, DB: db}, true
+}
+
+// observe emits the metrics related to the provided event, and keeps
+// track of the expected expiration of the keys.
+func (l *keyspaceListener) observe(ctx context.Context, event keyspaceEvent) {
+	now := time.Now()
+	l.client.emitKeyspaceEventMetric(ctx, keyspaceEventsMetric, event.Type, now, 1)
+
+	if l.types != nil && !slices.Contains(l.types, "expired") {
+		return
+	}
+
+	switch event.Type {
+	case "expire":
+		l.trackExpiration(ctx, event.Key, now)
+	case "expired":
+		l.mu.Lock()
+		e, ok := l.expirations[event.Key]
+		delete(l.expirations, event.Key)
+		l.mu.Unlock()
+
+		if ok && !e.at.IsZero() {
+			lag := metrics.D(now.Sub(e.at))
+			l.client.emitKeyspaceEventMetric(ctx, keyspaceExpiryLagMetric, event.Type, now, lag)
+		}
+	case "set", "del", "persist", "evicted", "rename_from":
+		l.mu.Lock()
+		delete(l.expirations, event.Key)
+		l.mu.Unlock()
+	}
+}
+
+// trackExpiration looks up the expiration of the provided key, whose expire
+// event was received at `now`, and tracks it.
+//
+// The lookup is performed in the background, so that the events received
+// in the meantime are not delayed. The key is not tracked if too many keys
+// or lookups already are, or if its expired event is received before the
+// lookup completes.
+func (l *keyspaceListener) trackExpiration(ctx context.Context, key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.expirations, key)
+	if len(l.expirations) >= maxTrackedExpirations {
+		l.dropStaleExpirations(now)
+		if len(l.expirations) >= maxTrackedExpirations {
+			return
+		}
+	}
+
+	select {
+	case l.lookups <- struct{}{}:
+	default:
+		return
+	}
+
+	l.seq++
+	seq := l.seq
+	l.expirations[key] = expiration{seq: seq}
+
+	go func() {
+		defer func() { <-l.lookups }()
+
+		start := time.Now()
+		ttl, err := l.client.redisClient.PTTL(ctx, key).Result()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		// The key's expiration may have been forgotten, or tracked for a
+		// newer expire event, in the meantime.
+		if e, ok := l.expirations[key]; !ok || e.seq != seq {
+			return
+		}
+
+		if err != nil || ttl < 0 {
+			delete(l.expirations, key)
+			return
+		}
+
+		l.expirations[key] = expiration{seq: seq, at: start.Add(ttl)}
+	}()
+}
+
+// dropStaleExpirations stops tracking the expirations of the keys which
+// were expected to expire more than staleExpirationAge before `now`. It
+// must be called with mu held.
+func (l *keyspaceListener) dropStaleExpirations(now time.Time) {
+	for key, e := range l.expirations {
+		if !e.at.IsZero() && now.Sub(e.at) > staleExpirationAge {
+			delete(l.expirations, key)
+		}
+	}
+}
+
+// Selectors of the metrics emitted for keyspace events.
+func keyspaceEventsMetric(m *redisMetrics) *metrics.Metric    { return m.KeyspaceEvents }
+func keyspaceExpiryLagMetric(m *redisMetrics) *metrics.Metric { return m.KeyspaceExpiryLag }
+
+// emitKeyspaceEventMetric pushes a sample of the selected metric, related
+// to a keyspace event of the provided type.
+func (c *Client) emitKeyspaceEventMetric(
+	ctx context.Context, metric func(*redisMetrics) *metrics.Metric, eventType string, now time.Time, value float64,
+) {
+	state := c.vu.State()
+	if state == nil || c.metrics == nil {
+		return
+	}
+
+	ctm := c.tagsAndMeta(ctx)
+	ctm.SetTag(eventTagName, eventType)
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric(c.metrics),
+			Tags:   ctm.Tags,
+		},
+		Time:     now,
+		Metadata: ctm.Metadata,
+		Value:    value,
+	})
+}
+
+// readKeyspaceEventTypes reads the types of the events to observe from
+// their representation as exported from sobek.Runtime: a single type, an
+// array of types, or null, which matches all types.
+func readKeyspaceEventTypes(events any) ([]string, error) {
+	switch events := events.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if events == "*" {
+			return nil, nil
+		}
+		return []string{events}, nil
+	case []any:
+		types := make([]string, 0, len(events))
+		for _, event := range events {
+			typ, ok := event.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid keyspace event type: %v; expected string", event)
+			}
+			types = append(types, typ)
+		}
+		return types, nil
+	default:
+		return nil, fmt.Errorf("invalid keyspace event types type: %T; expected string or array", events)
+	}
+}
+
+// readKeyspaceEventsOptions validates and instantiates the
+// keyspaceEventsOptions from their map representation as exported from
+// sobek.Runtime.
+func readKeyspaceEventsOptions(options any) (*keyspaceEventsOptions, error) {
+	opts := &keyspaceEventsOptions{}
+	if err := decodeCommandOptions("keyspace events", options, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.DB != nil && *opts.DB < 0 {
+		return nil, fmt.Errorf("invalid keyspace events options; reason: db must not be negative, got %d", *opts.DB)
+	}
+
+	return opts, nil
+}
+
+// matchPattern reports whether the provided string matches the provided
+// Redis glob-style pattern, supporting the `*`, `?`, `[...]` and `\`
+// special characters.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 || len(s) == 0 {
+				return false
+			}
+			if !matchClass(pattern[1:end+1], s[0]) {
+				return false
+			}
+			pattern = pattern[end+1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
+
+// matchClass reports whether the provided character belongs to the
+// provided glob-style character class, such as `a-z` or `^abc`.
+func matchClass(class string, ch byte) bool {
+	negate := strings.HasPrefix(class, "^")
+	if negate {
+		class = class[1:]
+	}
+
+	match := false
+	for i := 0; i < len(class); i++ {
+		switch {
+		case class[i] == '\\' && i+1 < len(class):
+			i++
+			match = match || class[i] == ch
+		case i+2 < len(class) && class[i+1] == '-':
+			lo, hi := min(class[i], class[i+2]), max(class[i], class[i+2])
+			match = match || (lo <= ch && ch <= hi)
+			i += 2
+		default:
+			match = match || class[i] == ch
+		}
+	}
+
+	return match != negate
+}
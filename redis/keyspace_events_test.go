@@ -0,0 +1,309 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/metrics"
+)
+
+func TestClientOnKeyspaceEvent(t *testing.T) {
+	t.Parallel()
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client of the provided server, the `notify` function publishes a
+	// keyspace notification for an event and a key, the `sleep` function
+	// blocks for the provided number of milliseconds, and the `waitFor`
+	// function waits for the provided condition to hold.
+	runScript := func(t *testing.T, ts testSetup, rs *redistest.StubServer, script string) error {
+		t.Helper()
+
+		require.NoError(t, ts.rt.Set("notify", func(event, key string) {
+			rs.NotifyKeyspaceEvent(0, event, key)
+		}))
+		require.NoError(t, ts.rt.Set("sleep", func(ms int) {
+			time.Sleep(time.Duration(ms) * time.Millisecond)
+		}))
+
+		return ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				const waitFor = async (condition, what) => {
+					const deadline = Date.now() + 1000;
+					while (!condition()) {
+						if (Date.now() > deadline) { throw 'timed out waiting for ' + what }
+						await redis.sendCommand("PING");
+					}
+				};
+
+				%s
+			`, rs.Addr(), script))
+
+			return err
+		})
+	}
+
+	t.Run("keyspace channels", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, ts, rs, `
+			const events = [];
+
+			(async () => {
+				const listener = await redis.onKeyspaceEvent("session:*", ["expired"], event => {
+					events.push(event)
+				}, { notifyKeyspaceEvents: "KEA" });
+
+				await redis.sendCommand("SET", "session:1", "x", "PX", "50");
+				notify("set", "session:1");
+				notify("expire", "session:1");
+				notify("expire", "other");
+
+				sleep(100);
+				notify("expired", "other");
+				notify("expired", "session:1");
+
+				await waitFor(() => events.length > 0, "keyspace events");
+				listener.stop();
+
+				if (events.length !== 1 || events[0].key !== "session:1" || events[0].type !== "expired" || events[0].db !== 0) {
+					throw 'unexpected keyspace events: ' + JSON.stringify(events)
+				}
+			})()
+		`)
+		require.NoError(t, err)
+
+		counts, commands := map[string]float64{}, map[string]float64{}
+		var lags []metrics.Sample
+		for _, container := range metrics.GetBufferedSamples(ts.samples) {
+			for _, sample := range container.GetSamples() {
+				event, _ := sample.Tags.Get(eventTagName)
+				switch sample.Metric.Name {
+				case "redis_keyspace_events":
+					counts[event] += sample.Value
+				case "redis_keyspace_expiry_lag":
+					lags = append(lags, sample)
+				case "redis_commands":
+					command, _ := sample.Tags.Get(commandTagName)
+					commands[command] += sample.Value
+				}
+			}
+		}
+
+		// The commands sent by the listener are not accounted for.
+		assert.Contains(t, rs.GotCommands(), []string{"PTTL", "session:1"})
+		assert.Equal(t, float64(1), commands["set"])
+		for _, command := range []string{"config", "psubscribe", "pttl"} {
+			assert.NotContains(t, commands, command)
+		}
+
+		assert.Equal(t, map[string]float64{"set": 1, "expire": 1, "expired": 1}, counts)
+		require.Len(t, lags, 1)
+		assert.Greater(t, lags[0].Value, float64(0))
+		assert.Less(t, lags[0].Value, float64(1000))
+	})
+
+	t.Run("keyevent channels", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		err := runScript(t, ts, rs, `
+			const events = [];
+
+			(async () => {
+				await redis.sendCommand("CONFIG", "SET", "notify-keyspace-events", "Eg");
+
+				const listener = await redis.onKeyspaceEvent("session:[0-9]", null, event => {
+					events.push(event)
+				});
+
+				notify("del", "session:a");
+				notify("del", "session:1");
+
+				await waitFor(() => events.length > 0, "keyspace events");
+				listener.stop();
+
+				if (events.length !== 1 || events[0].key !== "session:1" || events[0].type !== "del" || events[0].db !== 0) {
+					throw 'unexpected keyspace events: ' + JSON.stringify(events)
+				}
+			})()
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("callback errors stop the subscription", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		err := runScript(t, ts, rs, `
+			redis.onKeyspaceEvent("*", "expired", () => { throw 'callback error' }, { notifyKeyspaceEvents: "Kx" })
+				.then(() => notify("expired", "foo"))
+		`)
+		require.ErrorContains(t, err, "callback error")
+	})
+
+	t.Run("invalid subscriptions are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		err := runScript(t, ts, rs, `
+			expectError(redis.onKeyspaceEvent("*", null, () => {}), "keyspace notifications are disabled")
+				.then(() => expectError(redis.onKeyspaceEvent("*", null, () => {}, { flags: "K" }), "unknown field"))
+				.then(() => expectError(redis.onKeyspaceEvent("*", [1], () => {}), "invalid keyspace event type"))
+				.then(() => expectError(redis.onKeyspaceEvent("*", null, "nope"), "expects a callback function"))
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("cluster clients are not supported", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		sc := redistest.RunClusterT(t, 2, 0)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({ cluster: { nodes: ['redis://%s', 'redis://%s'] } });
+
+				expectError(redis.onKeyspaceEvent("*", null, () => {}), "not supported by cluster clients")
+			`, sc.Addrs()[0], sc.Addrs()[1]))
+
+			return err
+		})
+		require.NoError(t, gotScriptErr)
+	})
+}
+
+func TestKeyspaceListenerRunStopsOnContextDone(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: rs.Addr().String()})
+	t.Cleanup(func() { _ = client.Close() })
+	require.NoError(t, client.ConfigSet(t.Context(), "notify-keyspace-events", "Kg").Err())
+
+	pubsub := client.PSubscribe(t.Context(), "__keyspace@0__:*")
+	_, err := pubsub.Receive(t.Context())
+	require.NoError(t, err)
+
+	l := &keyspaceListener{
+		client:      &Client{vu: ts.runtime.VU, redisClient: client},
+		pubsub:      pubsub,
+		pattern:     "*",
+		expirations: make(map[string]expiration),
+		lookups:     make(chan struct{}, maxExpirationLookups),
+	}
+
+	// Once the VU's context is done, the event loop no longer runs the
+	// callbacks enqueued by the listener.
+	ctx, cancel := context.WithCancel(context.Background())
+	enqueue := func(func() error) {}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.run(ctx, enqueue, nil)
+	}()
+
+	rs.NotifyKeyspaceEvent(0, "del", "foo")
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the listener did not stop once its context was done")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "foo", true},
+		{"foo", "foo", true},
+		{"foo", "foobar", false},
+		{"foo*", "foobar", true},
+		{"*bar", "foobar", true},
+		{"f*o*r", "foobar", true},
+		{"f?o", "foo", true},
+		{"f?o", "fo", false},
+		{"f[aeiou]o", "foo", true},
+		{"f[^aeiou]o", "foo", false},
+		{"f[a-z]o", "fxo", true},
+		{"f[a-z]o", "f1o", false},
+		{`f\*o`, "f*o", true},
+		{`f\*o`, "fxo", false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, matchPattern(tc.pattern, tc.s), "%q ~ %q", tc.pattern, tc.s)
+	}
+}
+
+func TestKeyspaceListenerTrackExpiration(t *testing.T) {
+	t.Parallel()
+
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("PTTL", func(c *redistest.Connection, _ []string) {
+		c.WriteInteger(1000)
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: rs.Addr().String()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	l := &keyspaceListener{
+		client:      &Client{redisClient: client},
+		expirations: make(map[string]expiration),
+		lookups:     make(chan struct{}, maxExpirationLookups),
+	}
+
+	tracked := func(key string) bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		e, ok := l.expirations[key]
+		return ok && !e.at.IsZero()
+	}
+
+	now := time.Now()
+	for i := range maxTrackedExpirations {
+		l.expirations[fmt.Sprint(i)] = expiration{at: now.Add(time.Minute)}
+	}
+
+	// No more keys are tracked once the limit is reached.
+	l.trackExpiration(t.Context(), "a", now)
+	assert.Len(t, l.expirations, maxTrackedExpirations)
+	assert.NotContains(t, l.expirations, "a")
+
+	// Keys whose expired event was missed make room for new ones.
+	l.expirations["0"] = expiration{at: now.Add(-2 * staleExpirationAge)}
+	l.trackExpiration(t.Context(), "a", now)
+	l.mu.Lock()
+	assert.NotContains(t, l.expirations, "0")
+	l.mu.Unlock()
+	assert.Eventually(t, func() bool { return tracked("a") }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,349 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/metrics"
+)
+
+// poolStatsInterval is the minimum interval at which a Client emits its
+// connection pool statistics as k6 metrics, within a single iteration.
+const poolStatsInterval = time.Second
+
+// addrTagName is the name of the tag holding the address(es) of the
+// redis server(s) a metric sample relates to.
+const addrTagName = "redis_addr"
+
+// commandTagName is the name of the tag holding the name of the redis
+// command a metric sample relates to.
+const commandTagName = "command"
+
+// redisMetrics holds the custom k6 metrics emitted by the redis module.
+type redisMetrics struct {
+	PoolTotalConns *metrics.Metric
+	PoolIdleConns  *metrics.Metric
+	PoolStaleConns *metrics.Metric
+	PoolTimeouts   *metrics.Metric
+	PoolHits       *metrics.Metric
+	PoolMisses     *metrics.Metric
+
+	Commands        *metrics.Metric
+	CommandDuration *metrics.Metric
+	CommandErrors   *metrics.Metric
+
+	KeyspaceEvents    *metrics.Metric
+	KeyspaceExpiryLag *metrics.Metric
+}
+
+// registerMetrics registers the redis module's custom metrics in the
+// provided registry.
+func registerMetrics(registry *metrics.Registry) (*redisMetrics, error) {
+	var (
+		err error
+		m   = &redisMetrics{}
+	)
+
+	if m.PoolTotalConns, err = registry.NewMetric("redis_pool_total_conns", metrics.Gauge); err != nil {
+		return nil, err
+	}
+
+	if m.PoolIdleConns, err = registry.NewMetric("redis_pool_idle_conns", metrics.Gauge); err != nil {
+		return nil, err
+	}
+
+	if m.PoolStaleConns, err = registry.NewMetric("redis_pool_stale_conns", metrics.Gauge); err != nil {
+		return nil, err
+	}
+
+	if m.PoolTimeouts, err = registry.NewMetric("redis_pool_timeouts", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.PoolHits, err = registry.NewMetric("redis_pool_hits", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.PoolMisses, err = registry.NewMetric("redis_pool_misses", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.Commands, err = registry.NewMetric("redis_commands", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.CommandDuration, err = registry.NewMetric("redis_command_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+
+	if m.CommandErrors, err = registry.NewMetric("redis_command_errors", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.KeyspaceEvents, err = registry.NewMetric("redis_keyspace_events", metrics.Counter); err != nil {
+		return nil, err
+	}
+
+	if m.KeyspaceExpiryLag, err = registry.NewMetric("redis_keyspace_expiry_lag", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// PoolStats holds the statistics of a Client's connection pool, as
+// returned by `client.poolStats()`.
+type PoolStats struct {
+	// Hits is the number of times a free connection was found in the pool.
+	Hits uint32 `js:"hits"`
+
+	// Misses is the number of times a free connection was NOT found in the pool.
+	Misses uint32 `js:"misses"`
+
+	// Timeouts is the number of times a wait timeout occurred.
+	Timeouts uint32 `js:"timeouts"`
+
+	// TotalConns is the number of total connections in the pool.
+	TotalConns uint32 `js:"totalConns"`
+
+	// IdleConns is the number of idle connections in the pool.
+	IdleConns uint32 `js:"idleConns"`
+
+	// StaleConns is the number of stale connections removed from the pool.
+	StaleConns uint32 `js:"staleConns"`
+}
+
+// PoolStats returns the current statistics of the client's connection pool.
+//
+// If the client is not connected yet, all the statistics are zero.
+func (c *Client) PoolStats() PoolStats {
+	if c.redisClient == nil {
+		return PoolStats{}
+	}
+
+	stats := c.redisClient.PoolStats()
+
+	return PoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}
+}
+
+// poolStatsEmission holds the state of the emission of a Client's
+// connection pool statistics.
+type poolStatsEmission struct {
+	mu sync.Mutex
+
+	// ctx is the VU context the statistics were last emitted in, and at
+	// is when they were.
+	ctx context.Context //nolint:containedctx
+	at  time.Time
+
+	// previous holds the statistics which were last emitted.
+	previous PoolStats
+}
+
+// emitPoolStatsIfDue emits the client's connection pool statistics,
+// unless they were already emitted during the VU's current iteration,
+// less than poolStatsInterval ago.
+//
+// It is called for each command processed by the client, so that the
+// statistics are emitted at least once per iteration in which the client
+// is used, and at most every poolStatsInterval during long iterations.
+func (c *Client) emitPoolStatsIfDue() {
+	if c.metrics == nil {
+		return
+	}
+
+	ctx := c.vu.Context()
+
+	e := &c.poolStatsEmission
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.ctx == ctx && time.Since(e.at) < poolStatsInterval {
+		return
+	}
+
+	e.previous = c.emitPoolStats(ctx, e.previous)
+	e.ctx, e.at = ctx, time.Now()
+}
+
+// emitPoolStats pushes the client's current connection pool statistics
+// as k6 metric samples, and returns them.
+//
+// Connection counts are emitted as gauges, while hits, misses and
+// timeouts are emitted as counters incremented by their difference with
+// the `previous` statistics.
+func (c *Client) emitPoolStats(ctx context.Context, previous PoolStats) PoolStats {
+	state := c.vu.State()
+	if state == nil {
+		return previous
+	}
+
+	current := c.PoolStats()
+
+	ctm := c.tagsAndMeta(ctx)
+
+	now := time.Now()
+	sample := func(metric *metrics.Metric, value uint32) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   ctm.Tags,
+			},
+			Time:     now,
+			Metadata: ctm.Metadata,
+			Value:    float64(value),
+		}
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.ConnectedSamples{
+		Samples: []metrics.Sample{
+			sample(c.metrics.PoolTotalConns, current.TotalConns),
+			sample(c.metrics.PoolIdleConns, current.IdleConns),
+			sample(c.metrics.PoolStaleConns, current.StaleConns),
+			sample(c.metrics.PoolTimeouts, current.Timeouts-previous.Timeouts),
+			sample(c.metrics.PoolHits, current.Hits-previous.Hits),
+			sample(c.metrics.PoolMisses, current.Misses-previous.Misses),
+		},
+		Tags: ctm.Tags,
+		Time: now,
+	})
+
+	return current
+}
+
+// tagsAndMeta returns the tags and metadata to attach to the metric samples
+// emitted by the client.
+//
+// The VU's current tags are merged with the client-level tags passed to the
+// Client constructor, and with the tags passed to the command method whose
+// execution the context relates to, if any, in that order of precedence.
+func (c *Client) tagsAndMeta(ctx context.Context) metrics.TagsAndMeta {
+	ctm := c.vu.State().Tags.GetCurrentValues()
+	ctm.SetTag(addrTagName, strings.Join(c.redisOptions.Addrs, ","))
+
+	for k, v := range c.tags {
+		ctm.SetTag(k, v)
+	}
+
+	if params, ok := ctx.Value(commandParamsKey{}).(*commandParams); ok {
+		for k, v := range params.Tags {
+			ctm.SetTag(k, v)
+		}
+	}
+
+	return ctm
+}
+
+// metricsHook is a redis.Hook emitting k6 metrics for each command
+// processed by the client.
+type metricsHook struct {
+	client *Client
+}
+
+var _ redis.Hook = &metricsHook{}
+
+// DialHook implements the redis.Hook interface.
+func (h *metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook implements the redis.Hook interface, and emits metrics
+// for the processed command.
+func (h *metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.client.emitCommandMetrics(ctx, cmd.Name(), start, err)
+		h.client.emitTraffic()
+		h.client.emitPoolStatsIfDue()
+
+		return err
+	}
+}
+
+// ProcessPipelineHook implements the redis.Hook interface, and emits
+// metrics for each of the commands of the processed pipeline.
+func (h *metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			h.client.emitCommandMetrics(ctx, cmd.Name(), start, cmd.Err())
+		}
+		h.client.emitTraffic()
+		h.client.emitPoolStatsIfDue()
+
+		return err
+	}
+}
+
+// internalCommandKey is the context key marking the commands the client
+// sends on its own behalf, such as the ones of keyspace event listeners,
+// which are not accounted for in the command metrics.
+type internalCommandKey struct{}
+
+// withInternalCommands returns a context marking the commands sent with it
+// as internal.
+func withInternalCommands(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalCommandKey{}, true)
+}
+
+// emitCommandMetrics pushes the metric samples related to the execution
+// of a single redis command, started at `start`, and which resulted in `err`.
+//
+// A redis.Nil error, which denotes an empty reply, is not counted as an error.
+// Internal commands are not accounted for.
+func (c *Client) emitCommandMetrics(ctx context.Context, command string, start time.Time, err error) {
+	state := c.vu.State()
+	if state == nil || c.metrics == nil {
+		return
+	}
+
+	if internal, _ := ctx.Value(internalCommandKey{}).(bool); internal {
+		return
+	}
+
+	now := time.Now()
+	ctm := c.tagsAndMeta(ctx)
+	ctm.SetTag(commandTagName, command)
+
+	sample := func(metric *metrics.Metric, value float64) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   ctm.Tags,
+			},
+			Time:     now,
+			Metadata: ctm.Metadata,
+			Value:    value,
+		}
+	}
+
+	samples := []metrics.Sample{
+		sample(c.metrics.Commands, 1),
+		sample(c.metrics.CommandDuration, metrics.D(now.Sub(start))),
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		samples = append(samples, sample(c.metrics.CommandErrors, 1))
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.ConnectedSamples{
+		Samples: samples,
+		Tags:    ctm.Tags,
+		Time:    now,
+	})
+}
@@ -0,0 +1,302 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/lib/netext"
+	"go.k6.io/k6/v2/metrics"
+)
+
+func TestClientPoolStats(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+			let stats = redis.poolStats();
+			if (stats.totalConns !== 0) { throw 'unexpected totalConns before connecting: ' + stats.totalConns }
+
+			redis.sendCommand("PING")
+				.then(() => redis.sendCommand("PING"))
+				.then(() => {
+					stats = redis.poolStats();
+					if (stats.totalConns !== 1) { throw 'unexpected totalConns: ' + stats.totalConns }
+					if (stats.idleConns !== 1) { throw 'unexpected idleConns: ' + stats.idleConns }
+					if (stats.misses !== 1) { throw 'unexpected misses: ' + stats.misses }
+					if (stats.hits !== 1) { throw 'unexpected hits: ' + stats.hits }
+					if (stats.timeouts !== 0) { throw 'unexpected timeouts: ' + stats.timeouts }
+				})
+		`, rs.Addr()))
+
+		return err
+	})
+
+	assert.NoError(t, gotScriptErr)
+	assert.Equal(t, 2, rs.HandledCommandsCount())
+}
+
+func TestClientEmitPoolStats(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	registry := metrics.NewRegistry()
+	m, err := registerMetrics(registry)
+	require.NoError(t, err)
+
+	opts, err := readOptions("redis://" + rs.Addr().String())
+	require.NoError(t, err)
+
+	// We instantiate the underlying client ourselves, instead of calling
+	// connect, so that no emission from the metrics hook interferes with
+	// the test.
+	c := &Client{vu: ts.runtime.VU, redisOptions: opts, metrics: m}
+	c.redisClient = redis.NewUniversalClient(opts)
+	t.Cleanup(func() { _ = c.redisClient.Close() })
+
+	ctx := ts.runtime.VU.Context()
+	require.NoError(t, c.redisClient.Ping(ctx).Err())
+
+	previous := c.emitPoolStats(ctx, PoolStats{})
+	require.NoError(t, c.redisClient.Ping(ctx).Err())
+	c.emitPoolStats(ctx, previous)
+
+	containers := metrics.GetBufferedSamples(ts.samples)
+	require.Len(t, containers, 2)
+
+	values := func(container metrics.SampleContainer) map[string]float64 {
+		got := map[string]float64{}
+		for _, sample := range container.GetSamples() {
+			got[sample.Metric.Name] = sample.Value
+
+			addr, ok := sample.Tags.Get(addrTagName)
+			assert.True(t, ok)
+			assert.Equal(t, rs.Addr().String(), addr)
+		}
+		return got
+	}
+
+	assert.Equal(t, map[string]float64{
+		"redis_pool_total_conns": 1,
+		"redis_pool_idle_conns":  1,
+		"redis_pool_stale_conns": 0,
+		"redis_pool_timeouts":    0,
+		"redis_pool_hits":        0,
+		"redis_pool_misses":      1,
+	}, values(containers[0]))
+
+	// Counters are emitted as the difference with the previous emission.
+	assert.Equal(t, map[string]float64{
+		"redis_pool_total_conns": 1,
+		"redis_pool_idle_conns":  1,
+		"redis_pool_stale_conns": 0,
+		"redis_pool_timeouts":    0,
+		"redis_pool_hits":        1,
+		"redis_pool_misses":      0,
+	}, values(containers[1]))
+}
+
+func TestClientEmitPoolStatsPerIteration(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	require.NoError(t, ts.rt.Set("addr", rs.Addr().String()))
+	_, err := ts.rt.RunString(`const redis = new Client('redis://' + addr);`)
+	require.NoError(t, err)
+
+	// Each iteration runs in its own VU context, which is cancelled once
+	// the iteration is over.
+	iteration := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		ts.runtime.VU.CtxField = ctx
+		defer cancel()
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(`
+				redis.sendCommand("PING").then(() => redis.sendCommand("PING"));
+			`)
+
+			return err
+		})
+		require.NoError(t, gotScriptErr)
+	}
+
+	iteration()
+	iteration()
+	iteration()
+
+	var hits []float64
+	for _, container := range metrics.GetBufferedSamples(ts.samples) {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric.Name == "redis_pool_hits" {
+				hits = append(hits, sample.Value)
+			}
+		}
+	}
+
+	// The statistics are emitted once per iteration, as its commands are
+	// processed less than poolStatsInterval apart.
+	assert.Equal(t, []float64{0, 2, 2}, hits)
+}
+
+func TestClientCommandMetricsTags(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+		c.WriteBulkString("bar")
+	})
+	rs.RegisterCommandHandler("DEL", func(c *redistest.Connection, args []string) {
+		c.WriteInteger(len(args))
+	})
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client({
+				socket: {
+					host: '%s',
+					port: %d,
+				},
+				tags: {
+					client_tag: 'client',
+					overridden: 'client',
+				},
+			});
+
+			redis.get("foo", { tags: { call_tag: 'lookup', overridden: 'call' } })
+				.then(res => { if (res !== "bar") { throw 'unexpected value for get result: ' + res } })
+				.then(() => redis.del("foo", "bar", { tags: { call_tag: 'cleanup' } }))
+				.then(res => { if (res !== 2) { throw 'unexpected value for del result: ' + res } })
+				.then(() => redis.incr("foo"))
+				.then(
+					res => { throw 'expected incr to fail' },
+					err => { if (!String(err).includes('unknown command')) { throw 'unexpected error: ' + err } }
+				)
+				.then(() => redis.get("foo", { unknown: true }))
+				.then(
+					res => { throw 'expected get to reject unknown parameters' },
+					err => { if (!String(err).includes('invalid command parameters')) { throw 'unexpected error: ' + err } }
+				)
+		`, rs.Addr().IP.String(), rs.Addr().Port))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+	assert.Equal(t, [][]string{
+		{"HELLO", "2"},
+		{"GET", "foo"},
+		{"DEL", "foo", "bar"},
+		{"INCR", "foo"},
+	}, rs.GotCommands())
+
+	type commandSample struct {
+		metric string
+		tags   map[string]string
+	}
+
+	// Samples are also emitted for the commands the client sends when
+	// initializing a connection, which we are not interested in here.
+	var got []commandSample
+	for _, container := range metrics.GetBufferedSamples(ts.samples) {
+		for _, sample := range container.GetSamples() {
+			if command, _ := sample.Tags.Get(commandTagName); !slices.Contains([]string{"get", "del", "incr"}, command) {
+				continue
+			}
+			got = append(got, commandSample{metric: sample.Metric.Name, tags: sample.Tags.Map()})
+		}
+	}
+
+	addr := rs.Addr().String()
+	getTags := map[string]string{
+		addrTagName: addr, commandTagName: "get",
+		"client_tag": "client", "call_tag": "lookup", "overridden": "call",
+	}
+	delTags := map[string]string{
+		addrTagName: addr, commandTagName: "del",
+		"client_tag": "client", "call_tag": "cleanup", "overridden": "client",
+	}
+	incrTags := map[string]string{
+		addrTagName: addr, commandTagName: "incr",
+		"client_tag": "client", "overridden": "client",
+	}
+
+	assert.Equal(t, []commandSample{
+		{metric: "redis_commands", tags: getTags},
+		{metric: "redis_command_duration", tags: getTags},
+		{metric: "redis_commands", tags: delTags},
+		{metric: "redis_command_duration", tags: delTags},
+		{metric: "redis_commands", tags: incrTags},
+		{metric: "redis_command_duration", tags: incrTags},
+		{metric: "redis_command_errors", tags: incrTags},
+	}, got)
+}
+
+func TestClientDataSentReceived(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	rs := redistest.RunT(t)
+
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(fmt.Sprintf(`
+			const redis = new Client('redis://%s');
+
+			redis.sendCommand("PING", "hello");
+		`, rs.Addr()))
+
+		return err
+	})
+
+	require.NoError(t, gotScriptErr)
+
+	sums, counts := map[string]float64{}, map[string]int{}
+	for _, container := range metrics.GetBufferedSamples(ts.samples) {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric.Name == "redis_commands" {
+				counts[sample.Metric.Name]++
+			}
+			if sample.Metric != ts.state.BuiltinMetrics.DataSent && sample.Metric != ts.state.BuiltinMetrics.DataReceived {
+				continue
+			}
+
+			addr, ok := sample.Tags.Get(addrTagName)
+			assert.True(t, ok)
+			assert.Equal(t, rs.Addr().String(), addr)
+
+			sums[sample.Metric.Name] += sample.Value
+			counts[sample.Metric.Name]++
+		}
+	}
+
+	// The bytes are accumulated, and emitted at most once per command,
+	// including the connection initialization ones.
+	assert.LessOrEqual(t, counts[metrics.DataSentName], counts["redis_commands"])
+	assert.LessOrEqual(t, counts[metrics.DataReceivedName], counts["redis_commands"])
+
+	// The PING command alone is 25 bytes long, and its reply 11 bytes long,
+	// on top of which come the connection initialization commands.
+	assert.Greater(t, sums[metrics.DataSentName], float64(25))
+	assert.Greater(t, sums[metrics.DataReceivedName], float64(11))
+
+	// The traffic must not be accounted for by the VU's dialer as well.
+	dialer, ok := ts.state.Dialer.(*netext.Dialer)
+	require.True(t, ok)
+	assert.Zero(t, dialer.BytesWritten)
+	assert.Zero(t, dialer.BytesRead)
+}
@@ -3,6 +3,7 @@ package redis
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/grafana/sobek"
 	"go.k6.io/k6/v2/js/common"
@@ -12,11 +13,22 @@ import (
 type (
 	// RootModule is the global module instance that will create Client
 	// instances for each VU.
-	RootModule struct{}
+	RootModule struct {
+		// recorders holds the trace files clients record their
+		// commands to, shared by all VUs.
+		recorders recorders
+
+		// hllSets holds the elements added to tracked HyperLogLogs, shared
+		// by all VUs as the HyperLogLogs are.
+		hllSets hllTracker
+	}
 
 	// ModuleInstance represents an instance of the JS module.
 	ModuleInstance struct {
-		vu modules.VU
+		vu        modules.VU
+		metrics   *redisMetrics
+		recorders *recorders
+		hllSets   *hllTracker
 
 		*Client
 	}
@@ -35,8 +47,19 @@ func New() *RootModule {
 
 // NewModuleInstance implements the modules.Module interface and returns
 // a new instance for each VU.
-func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
-	return &ModuleInstance{vu: vu, Client: &Client{vu: vu}}
+func (rm *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	m, err := registerMetrics(vu.InitEnv().Registry)
+	if err != nil {
+		common.Throw(vu.Runtime(), err)
+	}
+
+	return &ModuleInstance{
+		vu:        vu,
+		metrics:   m,
+		recorders: &rm.recorders,
+		hllSets:   &rm.hllSets,
+		Client:    &Client{vu: vu, metrics: m, hllSets: &rm.hllSets},
+	}
 }
 
 // Exports implements the modules.Instance interface and returns
@@ -63,7 +86,9 @@ func (mi *ModuleInstance) Exports() modules.Exports {
 //
 // To support being instantiated in the init context, while not
 // producing any IO, as it is the convention in k6, the produced
-// Client is initially configured, but in a disconnected state.
+// Client is initially configured, but in a disconnected state. The only
+// exception is the trace file set by the `record` option, which is created
+// in the init context.
 // The connection is automatically established when using any of the Redis
 // commands exposed by the Client.
 func (mi *ModuleInstance) NewClient(call sobek.ConstructorCall) *sobek.Object {
@@ -73,7 +98,12 @@ func (mi *ModuleInstance) NewClient(call sobek.ConstructorCall) *sobek.Object {
 		common.Throw(rt, errors.New("must specify one argument"))
 	}
 
-	opts, err := readOptions(call.Arguments[0].Export())
+	copts, rest, err := readClientOptions(call.Arguments[0].Export())
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	opts, err := readOptions(rest)
 	if err != nil {
 		common.Throw(rt, err)
 	}
@@ -82,6 +112,24 @@ func (mi *ModuleInstance) NewClient(call sobek.ConstructorCall) *sobek.Object {
 		vu:           mi.vu,
 		redisOptions: opts,
 		redisClient:  nil,
+		metrics:      mi.metrics,
+		tags:         copts.Tags,
+		tracing:      copts.Tracing,
+		hllSets:      mi.hllSets,
+	}
+
+	if copts.Record != "" {
+		// As for the files opened by k6, the trace file's path is resolved
+		// relative to the script, which can only be done in the init context.
+		initEnv := mi.vu.InitEnv()
+		if initEnv == nil {
+			common.Throw(rt, errors.New("the record option can only be used in the init context"))
+		}
+
+		client.recorder, err = mi.recorders.open(initEnv.GetAbsFilePath(copts.Record))
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("unable to record commands; reason: %w", err))
+		}
 	}
 
 	return rt.ToValue(client).ToObject(rt)
@@ -0,0 +1,309 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// Monitor streams the commands processed by the redis server, as reported
+// by the MONITOR command, to the provided callback.
+//
+// As MONITOR takes over the connection it is sent on, a dedicated connection
+// is opened for it. The returned promise resolves, once the server started
+// streaming commands, to an object whose `stop` method closes it.
+//
+// The callback is called on the VU's event loop with an event object for
+// each command, holding its `timestamp` in milliseconds since the Unix
+// epoch, the `db` it was issued on, the `client` address it was issued from,
+// its `command` name and its `args`. If the callback throws, monitoring
+// stops, and the error is propagated to the event loop.
+//
+// Monitoring stops once the VU is stopped. Until then, the VU's iteration
+// does not end, unless `stop` is called.
+//
+// Only single-node clients are supported.
+func (c *Client) Monitor(onLine sobek.Value) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	callback, ok := sobek.AssertFunction(onLine)
+	if !ok {
+		reject(errors.New("monitor expects a callback function argument"))
+		return promise
+	}
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	if c.redisOptions.MasterName != "" || c.redisOptions.IsClusterMode || len(c.redisOptions.Addrs) != 1 {
+		reject(errors.New("monitor is only supported by single-node clients"))
+		return promise
+	}
+
+	ctx := c.vu.Context()
+	enqueue := c.vu.RegisterCallback()
+
+	go func() {
+		m, err := c.openMonitor(ctx, c.redisOptions.Addrs[0])
+		if err != nil {
+			enqueue(func() error { return nil })
+			reject(err)
+			return
+		}
+
+		stopOnDone := context.AfterFunc(ctx, m.Stop)
+		defer stopOnDone()
+
+		resolve(m)
+		m.run(ctx, c, enqueue, callback)
+	}()
+
+	return promise
+}
+
+// monitor is a connection the MONITOR command was sent on, exposed to JS
+// as the object the promise returned by `client.monitor` resolves to.
+type monitor struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+}
+
+// Stop closes the monitor's connection.
+func (m *monitor) Stop() {
+	m.once.Do(func() {
+		_ = m.conn.Close()
+	})
+}
+
+// openMonitor opens a connection to the server at the provided address,
+// authenticates on it if needed, and sends the MONITOR command on it.
+func (c *Client) openMonitor(ctx context.Context, addr string) (*monitor, error) {
+	conn, err := c.redisOptions.Dialer(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &monitor{conn: conn, reader: bufio.NewReader(conn)}
+
+	if c.redisOptions.Password != "" {
+		args := []string{"AUTH", c.redisOptions.Password}
+		if c.redisOptions.Username != "" {
+			args = []string{"AUTH", c.redisOptions.Username, c.redisOptions.Password}
+		}
+
+		if err := m.call(args...); err != nil {
+			m.Stop()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := m.call("MONITOR"); err != nil {
+		m.Stop()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// call sends the provided command on the monitor's connection, and reads
+// its reply, which is expected to be a simple string.
+func (m *monitor) call(args ...string) error {
+	var req strings.Builder
+	fmt.Fprintf(&req, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&req, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := m.conn.Write([]byte(req.String())); err != nil {
+		return err
+	}
+
+	_, err := m.readLine()
+
+	return err
+}
+
+// readLine reads a simple string reply from the monitor's connection.
+func (m *monitor) readLine() (string, error) {
+	line, err := m.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", errors.New(line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply from the server: %q", line)
+	}
+}
+
+// run reads the lines streamed by the server, and calls the callback with
+// the events they describe, until the connection is closed.
+//
+// A single event loop callback is reserved at a time, and the next one is
+// reserved from the event loop by the previous one, once it ran. Once the
+// provided context is done, the event loop no longer runs the callbacks, so
+// the monitor is stopped without waiting for the next one.
+func (m *monitor) run(ctx context.Context, c *Client, enqueue func(func() error), callback sobek.Callable) {
+	rt := c.vu.Runtime()
+	next := make(chan func(func() error), 1)
+
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			// The connection was closed, or is unusable.
+			m.Stop()
+			enqueue(func() error { return nil })
+			return
+		}
+
+		event, err := parseMonitorLine(line)
+		if err != nil {
+			continue
+		}
+
+		enqueue(func() error {
+			next <- c.vu.RegisterCallback()
+
+			if _, err := callback(sobek.Undefined(), rt.ToValue(event)); err != nil {
+				m.Stop()
+				return err
+			}
+
+			return nil
+		})
+
+		select {
+		case enqueue = <-next:
+		case <-ctx.Done():
+			m.Stop()
+			return
+		}
+	}
+}
+
+// parseMonitorLine parses a line streamed by the MONITOR command, of the
+// form `1339518083.107412 [0 127.0.0.1:60866] "set" "foo" "bar"`, into an
+// event object.
+func parseMonitorLine(line string) (map[string]any, error) {
+	ts, rest, ok := strings.Cut(line, " [")
+	if !ok {
+		return nil, fmt.Errorf("invalid monitor line %q", line)
+	}
+
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid monitor line timestamp %q", ts)
+	}
+
+	source, quoted, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return nil, fmt.Errorf("invalid monitor line %q", line)
+	}
+
+	dbStr, client, _ := strings.Cut(source, " ")
+	db, err := strconv.Atoi(dbStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid monitor line database %q", dbStr)
+	}
+
+	args, err := parseMonitorArgs(quoted)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invalid monitor line %q", line)
+	}
+
+	return map[string]any{
+		"timestamp": seconds * 1e3,
+		"db":        db,
+		"client":    client,
+		"command":   args[0],
+		"args":      args[1:],
+	}, nil
+}
+
+// parseMonitorArgs parses the space separated, quoted, arguments of a line
+// streamed by the MONITOR command.
+func parseMonitorArgs(s string) ([]string, error) {
+	invalid := fmt.Errorf("invalid monitor arguments %q", s)
+	args := []string{}
+
+	for i := 0; i < len(s); {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+
+		if s[i] != '"' {
+			return nil, invalid
+		}
+		i++
+
+		var arg strings.Builder
+		for {
+			if i >= len(s) {
+				return nil, invalid
+			}
+
+			ch := s[i]
+			if ch == '"' {
+				i++
+				break
+			}
+
+			if ch != '\\' || i+1 >= len(s) {
+				arg.WriteByte(ch)
+				i++
+				continue
+			}
+
+			esc := s[i+1]
+			i += 2
+			switch esc {
+			case 'n':
+				arg.WriteByte('\n')
+			case 'r':
+				arg.WriteByte('\r')
+			case 't':
+				arg.WriteByte('\t')
+			case 'a':
+				arg.WriteByte('\a')
+			case 'b':
+				arg.WriteByte('\b')
+			case 'x':
+				if i+2 > len(s) {
+					return nil, invalid
+				}
+				b, err := strconv.ParseUint(s[i:i+2], 16, 8)
+				if err != nil {
+					return nil, invalid
+				}
+				arg.WriteByte(byte(b))
+				i += 2
+			default:
+				arg.WriteByte(esc)
+			}
+		}
+
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientMonitor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commands are streamed to the callback", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RequirePassword("secret")
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://:secret@%s');
+				const events = [];
+
+				(async () => {
+					const monitor = await redis.monitor(event => { events.push(event) });
+
+					await redis.set("foo", "bar \"baz\"", 0);
+					await redis.get("foo");
+
+					const find = command => events.find(event => event.command === command);
+					const deadline = Date.now() + 1000;
+					while (!find("get")) {
+						if (Date.now() > deadline) { throw 'missing monitor events: ' + JSON.stringify(events) }
+						await redis.sendCommand("PING");
+					}
+					monitor.stop();
+
+					const set = find("set");
+					const get = find("get");
+					if (set.command !== "set" || JSON.stringify(set.args) !== JSON.stringify(["foo", "bar \"baz\""])) {
+						throw 'unexpected set event: ' + JSON.stringify(set)
+					}
+					if (get.command !== "get" || JSON.stringify(get.args) !== JSON.stringify(["foo"])) {
+						throw 'unexpected get event: ' + JSON.stringify(get)
+					}
+					if (set.db !== 0 || !set.client.startsWith("127.0.0.1:")) {
+						throw 'unexpected set event source: ' + JSON.stringify(set)
+					}
+					if (Math.abs(set.timestamp - Date.now()) > 10000) {
+						throw 'unexpected set event timestamp: ' + set.timestamp
+					}
+				})()
+			`, rs.Addr()))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+		assert.Contains(t, rs.GotCommands(), []string{"MONITOR"})
+	})
+
+	t.Run("callback errors stop monitoring", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				redis.monitor(() => { throw 'monitor callback failure' })
+					.then(() => redis.sendCommand("PING"))
+			`, rs.Addr()))
+
+			return err
+		})
+
+		require.ErrorContains(t, gotScriptErr, "monitor callback failure")
+	})
+
+	t.Run("cluster clients are not supported", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		other := redistest.RunT(t)
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({ cluster: { nodes: ['redis://%s', 'redis://%s'] } });
+
+				redis.monitor(() => {})
+					.then(
+						() => { throw 'expected monitor to be rejected' },
+						err => { if (!String(err).includes("single-node")) { throw 'unexpected error: ' + err } },
+					)
+			`, rs.Addr(), other.Addr()))
+
+			return err
+		})
+
+		require.NoError(t, gotScriptErr)
+	})
+}
+
+func TestMonitorRunStopsOnContextDone(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestSetup(t)
+	conn, server := net.Pipe()
+	t.Cleanup(func() { _ = server.Close() })
+
+	m := &monitor{conn: conn, reader: bufio.NewReader(conn)}
+
+	// Once the VU's context is done, the event loop no longer runs the
+	// callbacks enqueued by the monitor.
+	ctx, cancel := context.WithCancel(context.Background())
+	enqueue := func(func() error) {}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.run(ctx, &Client{vu: ts.runtime.VU}, enqueue, nil)
+	}()
+
+	_, err := server.Write([]byte("+1339518083.107412 [0 127.0.0.1:60866] \"ping\"\r\n"))
+	require.NoError(t, err)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the monitor did not stop once its context was done")
+	}
+}
+
+func TestParseMonitorLine(t *testing.T) {
+	t.Parallel()
+
+	event, err := parseMonitorLine(`1339518083.107412 [3 127.0.0.1:60866] "set" "foo" "a \"b\"\\\n\x00c"`)
+	require.NoError(t, err)
+	assert.InDelta(t, 1339518083107.412, event["timestamp"], 0.001)
+	assert.Equal(t, 3, event["db"])
+	assert.Equal(t, "127.0.0.1:60866", event["client"])
+	assert.Equal(t, "set", event["command"])
+	assert.Equal(t, []string{"foo", "a \"b\"\\\n\x00c"}, event["args"])
+
+	event, err = parseMonitorLine(`1339518083.107412 [0 lua] "ping"`)
+	require.NoError(t, err)
+	assert.Equal(t, "lua", event["client"])
+	assert.Equal(t, []string{}, event["args"])
+
+	for _, line := range []string{
+		`not a monitor line`,
+		`1339518083.107412 [0 lua] ping`,
+		`1339518083.107412 [0 lua] "ping`,
+		`1339518083.107412 [0 lua] "\xzz"`,
+		`1339518083.107412 [x lua] "ping"`,
+	} {
+		_, err := parseMonitorLine(line)
+		assert.Error(t, err, line)
+	}
+}
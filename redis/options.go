@@ -7,11 +7,33 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// clientOptions holds the options of the Client which are specific to
+// the k6 module, as opposed to the ones configuring the underlying redis
+// client.
+type clientOptions struct {
+	// Tags are added to all the metric samples emitted by the client.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Tracing enables the creation of an OpenTelemetry span for each
+	// command processed by the client.
+	Tracing bool `json:"tracing,omitempty"`
+
+	// Record is the path of a file the commands processed by the client
+	// are recorded to, to be replayed later on using `client.replay`.
+	// Relative paths are resolved relative to the script.
+	Record string `json:"record,omitempty"`
+}
+
+// clientOptionsKeys holds the names of the properties of the Client
+// constructor's options object which are parsed as clientOptions.
+var clientOptionsKeys = []string{"tags", "tracing", "record"}
+
 type singleNodeOptions struct {
 	Socket          *socketOptions `json:"socket,omitempty"`
 	Username        string         `json:"username,omitempty"`
@@ -142,6 +164,40 @@ func newOptionsFromString(url string) (*redis.UniversalOptions, error) {
 	return toUniversalOptions(opts)
 }
 
+// readClientOptions extracts the clientOptions from the Client constructor's
+// options. It returns them along with the remaining options, which should
+// be parsed using readOptions.
+//
+// Options passed as a URL string have no clientOptions.
+func readClientOptions(options any) (*clientOptions, any, error) {
+	obj, ok := options.(map[string]any)
+	if !ok {
+		return &clientOptions{}, options, nil
+	}
+
+	copts := map[string]any{}
+	rest := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if slices.Contains(clientOptionsKeys, k) {
+			copts[k] = v
+			continue
+		}
+		rest[k] = v
+	}
+
+	jsonStr, err := json.Marshal(copts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to serialize options to JSON %w", err)
+	}
+
+	opts := &clientOptions{}
+	if err := json.Unmarshal(jsonStr, opts); err != nil {
+		return nil, nil, fmt.Errorf("invalid options; reason: %w", err)
+	}
+
+	return opts, rest, nil
+}
+
 func readOptions(options any) (*redis.UniversalOptions, error) {
 	var (
 		opts *redis.UniversalOptions
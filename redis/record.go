@@ -0,0 +1,422 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/promises"
+	"go.k6.io/k6/v2/lib/fsext"
+)
+
+// traceEntry is a command recorded in a trace file, written as a single
+// line of JSON.
+type traceEntry struct {
+	// Time is the time at which the command was issued, in milliseconds
+	// since the Unix epoch.
+	Time float64 `json:"time"`
+
+	// Duration is the time it took for the command to be processed, in
+	// milliseconds.
+	Duration float64 `json:"duration"`
+
+	// Command and Args are the name and the arguments of the command.
+	Command string     `json:"command"`
+	Args    []traceArg `json:"args"`
+}
+
+// traceArg is an argument of a recorded command, as sent to the server.
+//
+// It is written as a JSON string if it is valid UTF-8, and as an object of
+// the form `{"base64": "..."}` otherwise, so that binary arguments, such as
+// the payloads of RESTORE, are replayed as they were issued. As the server
+// receives all the arguments as strings, numbers are recorded as strings,
+// which keeps large integers exact, and are read as such when replayed.
+type traceArg string
+
+// newTraceArg returns the traceArg of the provided go-redis command
+// argument, formatted as go-redis sends it to the server.
+func newTraceArg(arg any) traceArg {
+	switch arg := arg.(type) {
+	case string:
+		return traceArg(arg)
+	case []byte:
+		return traceArg(arg)
+	case nil:
+		return ""
+	case int:
+		return traceArg(strconv.FormatInt(int64(arg), 10))
+	case int64:
+		return traceArg(strconv.FormatInt(arg, 10))
+	case uint64:
+		return traceArg(strconv.FormatUint(arg, 10))
+	case float64:
+		return traceArg(strconv.FormatFloat(arg, 'f', -1, 64))
+	case float32:
+		return traceArg(strconv.FormatFloat(float64(arg), 'f', -1, 32))
+	case bool:
+		if arg {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return traceArg(arg.Format(time.RFC3339Nano))
+	case time.Duration:
+		return traceArg(strconv.FormatInt(arg.Nanoseconds(), 10))
+	case encoding.BinaryMarshaler:
+		b, err := arg.MarshalBinary()
+		if err != nil {
+			return ""
+		}
+		return traceArg(b)
+	default:
+		return traceArg(fmt.Sprint(arg))
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a traceArg) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(string(a)) {
+		return json.Marshal(string(a))
+	}
+
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString([]byte(a))})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+//
+// Besides strings and base64 objects, numbers are accepted as well, so
+// that traces can be written by hand. They are read as written.
+func (a *traceArg) UnmarshalJSON(data []byte) error {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case string:
+		*a = traceArg(value)
+	case json.Number:
+		*a = traceArg(value.String())
+	case map[string]any:
+		encoded, ok := value["base64"].(string)
+		if !ok || len(value) != 1 {
+			return errors.New("expected a string, a number, or an object holding a base64 string")
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return err
+		}
+		*a = traceArg(decoded)
+	default:
+		return fmt.Errorf("invalid argument %s; expected a string, a number, or an object holding a base64 string", data)
+	}
+
+	return nil
+}
+
+// recorders holds the recorders writing to the trace files clients record
+// their commands to, so that clients of all VUs recording to the same file
+// share the same recorder.
+type recorders struct {
+	mu    sync.Mutex
+	files map[string]*recorder
+}
+
+// open returns the recorder writing to the trace file at the provided
+// absolute path, creating the file, or truncating it, the first time it is
+// opened.
+//
+// The file remains open for the rest of the test run, as clients are
+// never explicitly closed.
+func (r *recorders) open(path string) (*recorder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rec, ok := r.files[path]; ok {
+		return rec, nil
+	}
+
+	file, err := fsext.NewOsFs().Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.files == nil {
+		r.files = make(map[string]*recorder)
+	}
+	rec := &recorder{file: file}
+	r.files[path] = rec
+
+	return rec, nil
+}
+
+// recorder writes trace entries to a trace file.
+type recorder struct {
+	mu   sync.Mutex
+	file io.Writer
+}
+
+// record writes the provided entry to the trace file.
+func (r *recorder) record(entry traceEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.file.Write(append(line, '\n'))
+
+	return err
+}
+
+// recordCallKey is the context key holding the flag claimed by the
+// recordingHook when recording the command the context relates to.
+//
+// As for the JS hooks, commands issued by go-redis while processing the
+// command method's one, such as those initializing a new connection, are
+// thus not recorded.
+type recordCallKey struct{}
+
+// recordingHook is a redis.Hook recording the commands processed by the
+// client to a trace file.
+type recordingHook struct {
+	client   *Client
+	recorder *recorder
+}
+
+var _ redis.Hook = &recordingHook{}
+
+// DialHook implements the redis.Hook interface.
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements the redis.Hook interface, and records the
+// command once it was processed.
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		claimed, ok := ctx.Value(recordCallKey{}).(*atomic.Bool)
+		if !ok || !claimed.CompareAndSwap(false, true) {
+			return next(ctx, cmd)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmd)
+
+		duration := time.Since(start)
+
+		args := make([]traceArg, 0, len(cmd.Args())-1)
+		for _, arg := range cmd.Args()[1:] {
+			args = append(args, newTraceArg(arg))
+		}
+
+		recordErr := h.recorder.record(traceEntry{
+			Time:     float64(start.UnixMicro()) / 1e3,
+			Duration: float64(duration) / float64(time.Millisecond),
+			Command:  cmd.Name(),
+			Args:     args,
+		})
+		if state := h.client.vu.State(); recordErr != nil && state != nil {
+			// Failing to record a command should not fail the command itself.
+			state.Logger.WithError(recordErr).Warn("unable to record redis command")
+		}
+
+		return err
+	}
+}
+
+// ProcessPipelineHook implements the redis.Hook interface.
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+// replayOptions holds the options of `client.replay`.
+type replayOptions struct {
+	// Speed is the factor the pace of the recorded commands is multiplied
+	// by. It defaults to 1, which replays them at their original pace.
+	Speed float64 `json:"speed,omitempty"`
+
+	// Loop replays the trace over and over, until the VU is stopped.
+	Loop bool `json:"loop,omitempty"`
+}
+
+// Replay re-issues the commands recorded in the provided trace file, as
+// produced by a client created with the `record` option, respecting the
+// time elapsed between them.
+//
+// The options object accepts a `speed` property, by which the pace of the
+// commands is multiplied, and a `loop` property, which makes the trace be
+// replayed over and over until the VU is stopped.
+//
+// The commands are issued without waiting for the previous ones to be
+// replied to. The promise resolves, once all of them were, to an object
+// holding the number of `commands` issued, and the number of `errors` they
+// produced. The hooks registered using `client.addHook` are not called for
+// the replayed commands.
+func (c *Client) Replay(file string, options any) *sobek.Promise {
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	opts, err := readReplayOptions(options)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	entries, err := readTrace(file)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx := c.vu.Context()
+
+	go func() {
+		commands, failures, err := c.replay(ctx, entries, opts)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(map[string]any{"commands": commands, "errors": failures})
+	}()
+
+	return promise
+}
+
+// replay issues the provided trace entries, and returns the number of
+// commands issued, and the number of them which failed.
+func (c *Client) replay(ctx context.Context, entries []traceEntry, opts *replayOptions) (int64, int64, error) {
+	var (
+		wg       sync.WaitGroup
+		commands atomic.Int64
+		failures atomic.Int64
+	)
+
+	issue := func(entry traceEntry) {
+		commands.Add(1)
+		wg.Go(func() {
+			args := make([]any, 0, len(entry.Args)+1)
+			args = append(args, entry.Command)
+			for _, arg := range entry.Args {
+				args = append(args, string(arg))
+			}
+			if err := c.redisClient.Do(ctx, args...).Err(); err != nil && !errors.Is(err, redis.Nil) {
+				failures.Add(1)
+			}
+		})
+	}
+
+	for len(entries) > 0 {
+		start := time.Now()
+
+		for _, entry := range entries {
+			offset := time.Duration((entry.Time - entries[0].Time) * float64(time.Millisecond) / opts.Speed)
+
+			timer := time.NewTimer(time.Until(start.Add(offset)))
+			select {
+			case <-timer.C:
+				issue(entry)
+			case <-ctx.Done():
+				timer.Stop()
+				wg.Wait()
+
+				// Looping replays only end once the VU is stopped.
+				if opts.Loop {
+					return commands.Load(), failures.Load(), nil
+				}
+
+				return commands.Load(), failures.Load(), ctx.Err()
+			}
+		}
+
+		if !opts.Loop {
+			break
+		}
+	}
+
+	wg.Wait()
+
+	return commands.Load(), failures.Load(), nil
+}
+
+// readReplayOptions validates and instantiates the replayOptions from their
+// map representation as exported from sobek.Runtime.
+func readReplayOptions(options any) (*replayOptions, error) {
+	opts := &replayOptions{Speed: 1}
+	if err := decodeCommandOptions("replay", options, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Speed <= 0 {
+		return nil, fmt.Errorf("invalid replay options; reason: speed must be positive, got %v", opts.Speed)
+	}
+
+	return opts, nil
+}
+
+// readTrace reads the entries of the provided trace file, ordered by the
+// time at which their command was issued.
+func readTrace(path string) ([]traceEntry, error) {
+	file, err := fsext.NewOsFs().Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open trace file; reason: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	var entries []traceEntry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry traceEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid trace file entry at line %d; reason: %w", line, err)
+		}
+
+		if entry.Command == "" {
+			return nil, fmt.Errorf("invalid trace file entry at line %d; reason: missing command", line)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read trace file; reason: %w", err)
+	}
+
+	slices.SortStableFunc(entries, func(a, b traceEntry) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,277 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/lib/fsext"
+)
+
+func TestClientRecord(t *testing.T) {
+	t.Parallel()
+
+	ts := newInitContextTestSetup(t)
+	rs := redistest.RunT(t)
+	rs.UseKeyspace(redistest.NewKeyspace())
+
+	// The trace file's path is relative to the script, whose directory
+	// differs from the process' working directory.
+	dir := t.TempDir()
+	ts.runtime.VU.InitEnvField.CWD = &url.URL{Scheme: "file", Path: filepath.ToSlash(dir) + "/"}
+	trace := filepath.Join(dir, "traces", "trace.jsonl")
+	require.NoError(t, fsext.NewOsFs().MkdirAll(filepath.Dir(trace), 0o700))
+
+	_, err := ts.rt.RunString(fmt.Sprintf(`
+		const redis = new Client({
+			socket: { host: '%s', port: %d },
+			record: "traces/trace.jsonl",
+		});
+	`, rs.Addr().IP, rs.Addr().Port))
+	require.NoError(t, err)
+
+	// The trace file is created in the init context.
+	exists, err := fsext.Exists(fsext.NewOsFs(), trace)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	ts.moveToVUContext()
+	gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+		_, err := ts.rt.RunString(`
+			redis.set("foo", "bar", 10)
+				.then(() => redis.get("foo"))
+				.then(() => redis.sendCommand("LPUSH", "list", 1, 2))
+		`)
+
+		return err
+	})
+	require.NoError(t, gotScriptErr)
+
+	// Clients can only record their commands if created in the init
+	// context.
+	_, err = ts.rt.RunString(`new Client({ socket: { host: 'localhost', port: 6379 }, record: "other.jsonl" })`)
+	require.ErrorContains(t, err, "only be used in the init context")
+
+	content, err := fsext.ReadFile(fsext.NewOsFs(), trace)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 3)
+
+	var entries []traceEntry
+	for _, line := range lines {
+		var entry traceEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	assert.Equal(t, "set", entries[0].Command)
+	assert.Equal(t, []traceArg{"foo", "bar", "ex", "10"}, entries[0].Args)
+	assert.Equal(t, "get", entries[1].Command)
+	assert.Equal(t, []traceArg{"foo"}, entries[1].Args)
+	assert.Equal(t, "lpush", entries[2].Command)
+	assert.Equal(t, []traceArg{"list", "1", "2"}, entries[2].Args)
+
+	for i, entry := range entries {
+		assert.Positive(t, entry.Duration)
+		if i > 0 {
+			assert.GreaterOrEqual(t, entry.Time, entries[i-1].Time+entries[i-1].Duration)
+		}
+	}
+}
+
+func TestClientReplay(t *testing.T) {
+	t.Parallel()
+
+	// writeTrace writes a trace of the provided commands, issued at the
+	// provided interval, and returns its path.
+	writeTrace := func(t *testing.T, interval time.Duration, commands ...[]traceArg) string {
+		t.Helper()
+
+		var content []byte
+		start := float64(time.Now().UnixMilli())
+		for i, command := range commands {
+			line, err := json.Marshal(traceEntry{
+				Time:     start + float64(i)*float64(interval)/float64(time.Millisecond),
+				Duration: 1,
+				Command:  string(command[0]),
+				Args:     command[1:],
+			})
+			require.NoError(t, err)
+			content = append(append(content, line...), '\n')
+		}
+
+		path := filepath.Join(t.TempDir(), "trace.jsonl")
+		require.NoError(t, fsext.WriteFile(fsext.NewOsFs(), path, content, 0o600))
+
+		return path
+	}
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client of the provided server.
+	runScript := func(t *testing.T, ts testSetup, rs *redistest.StubServer, script string) error {
+		t.Helper()
+
+		return ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+				%s
+			`, rs.Addr(), script))
+
+			return err
+		})
+	}
+
+	t.Run("commands are replayed at their original pace", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+		require.NoError(t, ts.rt.Set("trace", writeTrace(t, 50*time.Millisecond,
+			[]traceArg{"set", "foo", "bar"},
+			[]traceArg{"get", "foo"},
+			[]traceArg{"incr", "foo"},
+		)))
+
+		start := time.Now()
+		err := runScript(t, ts, rs, `
+			redis.replay(trace, { speed: 2 })
+				.then(res => {
+					if (res.commands !== 3 || res.errors !== 1) {
+						throw 'unexpected replay result: ' + JSON.stringify(res)
+					}
+				})
+		`)
+		require.NoError(t, err)
+
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, [][]string{
+			{"HELLO", "2"},
+			{"SET", "foo", "bar"},
+			{"GET", "foo"},
+			{"INCR", "foo"},
+		}, rs.GotCommands())
+	})
+
+	t.Run("looping replays run until the VU is stopped", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+		t.Cleanup(cancel)
+		ts.runtime.VU.CtxField = ctx
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+		require.NoError(t, ts.rt.Set("trace", writeTrace(t, 10*time.Millisecond,
+			[]traceArg{"incr", "foo"},
+			[]traceArg{"incr", "foo"},
+		)))
+
+		err := runScript(t, ts, rs, `
+			redis.replay(trace, { loop: true })
+				.then(res => {
+					if (res.commands <= 2) {
+						throw 'unexpected replay result: ' + JSON.stringify(res)
+					}
+				})
+		`)
+		require.NoError(t, err)
+		assert.Greater(t, rs.HandledCommandsCount(), 2)
+	})
+
+	t.Run("recorded commands are replayed as they were issued", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("SET", func(c *redistest.Connection, _ []string) {
+			c.WriteOK()
+		})
+
+		path := filepath.Join(t.TempDir(), "trace.jsonl")
+		rec, err := (&recorders{}).open(path)
+		require.NoError(t, err)
+
+		// Binary payloads, such as the ones of DUMP and RESTORE, and
+		// integers which are not exactly representable as JS numbers.
+		binary := []byte{0x00, 0xff, 0xfe, '\n', 'x'}
+		large := int64(1<<53 + 1)
+
+		client := redis.NewClient(&redis.Options{Addr: rs.Addr().String()})
+		t.Cleanup(func() { _ = client.Close() })
+		client.AddHook(&recordingHook{client: &Client{vu: ts.runtime.VU}, recorder: rec})
+
+		for _, args := range [][]any{
+			{"set", "binary", binary},
+			{"set", "large", large},
+		} {
+			ctx := context.WithValue(t.Context(), recordCallKey{}, &atomic.Bool{})
+			require.NoError(t, client.Do(ctx, args...).Err())
+		}
+
+		content, err := fsext.ReadFile(fsext.NewOsFs(), path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"args":["binary",{"base64":"AP/+Cng="}]`)
+		assert.Contains(t, string(content), `"args":["large","9007199254740993"]`)
+
+		require.NoError(t, ts.rt.Set("trace", path))
+		err = runScript(t, ts, rs, `
+			redis.replay(trace)
+				.then(res => {
+					if (res.commands !== 2 || res.errors !== 0) {
+						throw 'unexpected replay result: ' + JSON.stringify(res)
+					}
+				})
+		`)
+		require.NoError(t, err)
+
+		// The commands are received twice, once as recorded, once as
+		// replayed.
+		want := [][]string{
+			{"SET", "binary", string(binary)},
+			{"SET", "large", "9007199254740993"},
+		}
+		got := rs.GotCommands()
+		assert.Equal(t, want, got[1:3])
+		assert.Equal(t, want, got[len(got)-2:])
+	})
+
+	t.Run("invalid replays are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		require.NoError(t, ts.rt.Set("trace", writeTrace(t, 0, []traceArg{"ping"})))
+
+		err := runScript(t, ts, rs, `
+			expectError(redis.replay(trace, { speed: 0 }), "speed must be positive")
+				.then(() => expectError(redis.replay(trace, { pace: 2 }), "unknown field"))
+				.then(() => expectError(redis.replay(trace + ".missing"), "unable to open trace file"))
+		`)
+		require.NoError(t, err)
+	})
+}
+
+func TestTraceArgUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var args []traceArg
+	require.NoError(t, json.Unmarshal([]byte(`["foo", 10, 9007199254740993, 1.5, {"base64": "AP8="}]`), &args))
+	assert.Equal(t, []traceArg{"foo", "10", "9007199254740993", "1.5", "\x00\xff"}, args)
+
+	for _, invalid := range []string{`[null]`, `[true]`, `[{}]`, `[{"base64": "!"}]`, `[{"base64": "AP8=", "x": 1}]`} {
+		assert.Error(t, json.Unmarshal([]byte(invalid), &args), invalid)
+	}
+}
@@ -0,0 +1,182 @@
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Errors replied by a Redis server to clients failing to authenticate.
+var (
+	// ErrNoAuth is replied to commands sent on a connection which did not
+	// authenticate, when the server requires it.
+	ErrNoAuth = errors.New("NOAUTH Authentication required.")
+
+	// ErrWrongPass is replied to authentication attempts with an unknown
+	// user, or a wrong password.
+	ErrWrongPass = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+)
+
+// defaultUser is the name of the user connections are authenticated as
+// when no username is provided.
+const defaultUser = "default"
+
+// User describes a user allowed to connect to the stub server, in the
+// fashion of the Redis ACL SETUSER command.
+type User struct {
+	// Name is the name of the user. If empty, the user is the "default"
+	// user, which clients authenticate as when only providing a password.
+	Name string
+
+	// Password is the password of the user. If empty, the user can
+	// authenticate with any password.
+	//
+	// Connections are authenticated as the default user, without sending
+	// any AUTH command, if it has no password.
+	Password string
+
+	// Commands lists the commands the user is allowed to run. If empty,
+	// the user is allowed to run all the commands. Other commands are
+	// replied to with a NOPERM error.
+	//
+	// The AUTH and HELLO commands are always allowed.
+	Commands []string
+}
+
+// AddUser adds a user allowed to connect to the server, replacing any
+// user of the same name. From then on, the server requires clients to
+// authenticate as one of its users, using the AUTH or HELLO commands,
+// before running commands.
+func (rs *StubServer) AddUser(user User) {
+	if user.Name == "" {
+		user.Name = defaultUser
+	}
+
+	user.Commands = slices.Clone(user.Commands)
+	for i, command := range user.Commands {
+		user.Commands[i] = strings.ToUpper(command)
+	}
+
+	rs.Lock()
+	if rs.users == nil {
+		rs.users = make(map[string]User)
+	}
+	rs.users[user.Name] = user
+	rs.Unlock()
+
+	rs.RegisterCommandHandler("AUTH", rs.handleAuth)
+	rs.RegisterCommandHandler("ACL", rs.handleACL)
+}
+
+// RequirePassword makes the server require clients to authenticate using
+// the provided password, in the fashion of the Redis requirepass option.
+func (rs *StubServer) RequirePassword(password string) {
+	rs.AddUser(User{Password: password})
+}
+
+// authenticate authenticates the connection as the provided user, if the
+// provided password is the user's.
+func (rs *StubServer) authenticate(c *Connection, username, password string) error {
+	rs.Lock()
+	user, ok := rs.users[username]
+	rs.Unlock()
+
+	if !ok || (user.Password != "" && user.Password != password) {
+		return ErrWrongPass
+	}
+
+	c.user = username
+
+	return nil
+}
+
+// authorize replies with an error, and returns false, if the connection
+// is not allowed to run the provided command.
+func (rs *StubServer) authorize(c *Connection, command string) bool {
+	if command == "AUTH" || command == "HELLO" {
+		return true
+	}
+
+	user, ok := rs.connectionUser(c)
+	if !ok {
+		c.WriteError(ErrNoAuth)
+		return false
+	}
+
+	if len(user.Commands) > 0 && !slices.Contains(user.Commands, command) {
+		c.WriteError(fmt.Errorf(
+			"NOPERM User %s has no permissions to run the '%s' command", user.Name, strings.ToLower(command),
+		))
+		return false
+	}
+
+	return true
+}
+
+// connectionUser returns the user the connection is authenticated as, or
+// false if it is not authenticated while the server requires it.
+func (rs *StubServer) connectionUser(c *Connection) (User, bool) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if rs.users == nil {
+		return User{Name: defaultUser}, true
+	}
+
+	if c.user != "" {
+		// The user may have been removed since the connection authenticated.
+		user, ok := rs.users[c.user]
+		return user, ok
+	}
+
+	// Connections are implicitly authenticated as the default user, as
+	// long as it does not require a password.
+	user, ok := rs.users[defaultUser]
+
+	return user, ok && user.Password == ""
+}
+
+// handleAuth handles the AUTH command, taking either a password, or a
+// username and a password.
+func (rs *StubServer) handleAuth(c *Connection, args []string) {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = defaultUser, args[0]
+	case 2:
+		username, password = args[0], args[1]
+	default:
+		c.WriteError(errors.New("ERR wrong number of arguments for 'auth' command"))
+		return
+	}
+
+	if err := rs.authenticate(c, username, password); err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	c.WriteOK()
+}
+
+// handleACL handles the ACL command's WHOAMI and USERS subcommands.
+func (rs *StubServer) handleACL(c *Connection, args []string) {
+	if len(args) == 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'acl' command"))
+		return
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "WHOAMI":
+		user, _ := rs.connectionUser(c)
+		c.WriteBulkString(user.Name)
+	case "USERS":
+		rs.Lock()
+		users := sortedKeys(rs.users)
+		rs.Unlock()
+
+		c.WriteArray(users...)
+	default:
+		c.WriteError(fmt.Errorf("ERR unknown subcommand '%s'", args[0]))
+	}
+}
@@ -0,0 +1,120 @@
+package redistest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubServerAuth(t *testing.T) {
+	t.Parallel()
+
+	newServer := func(t *testing.T, users ...User) *StubServer {
+		t.Helper()
+
+		rs := RunT(t)
+		rs.UseKeyspace(NewKeyspace())
+		for _, user := range users {
+			rs.AddUser(user)
+		}
+
+		return rs
+	}
+
+	newClient := func(t *testing.T, rs *StubServer, opts redis.Options) *redis.Client {
+		t.Helper()
+
+		opts.Addr = rs.Addr().String()
+		if opts.Protocol == 0 {
+			opts.Protocol = 2
+		}
+
+		client := redis.NewClient(&opts)
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client
+	}
+
+	t.Run("servers without users accept all clients", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t)
+		client := newClient(t, rs, redis.Options{})
+
+		assert.NoError(t, client.Set(context.Background(), "foo", "bar", 0).Err())
+	})
+
+	t.Run("password", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t)
+		rs.RequirePassword("secret")
+		ctx := context.Background()
+
+		client := newClient(t, rs, redis.Options{})
+		assert.ErrorContains(t, client.Get(ctx, "foo").Err(), "NOAUTH")
+
+		client = newClient(t, rs, redis.Options{Password: "wrong"})
+		assert.ErrorContains(t, client.Get(ctx, "foo").Err(), "WRONGPASS")
+
+		client = newClient(t, rs, redis.Options{Password: "secret"})
+		assert.ErrorIs(t, client.Get(ctx, "foo").Err(), redis.Nil)
+		assert.Contains(t, rs.GotCommands(), []string{"AUTH", "secret"})
+	})
+
+	t.Run("users", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t,
+			User{Name: "alice", Password: "secret"},
+			User{Name: "bob", Password: "secret", Commands: []string{"get", "acl"}},
+		)
+		ctx := context.Background()
+
+		client := newClient(t, rs, redis.Options{Username: "alice", Password: "wrong"})
+		assert.ErrorContains(t, client.Ping(ctx).Err(), "WRONGPASS")
+
+		client = newClient(t, rs, redis.Options{Username: "alice", Password: "secret"})
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+		assert.Equal(t, "alice", client.ACLWhoAmI(ctx).Val())
+
+		client = newClient(t, rs, redis.Options{Username: "bob", Password: "secret"})
+		assert.Equal(t, "bar", client.Get(ctx, "foo").Val())
+		assert.EqualError(t, client.Set(ctx, "foo", "baz", 0).Err(),
+			"NOPERM User bob has no permissions to run the 'set' command")
+		assert.Equal(t, "bob", client.ACLWhoAmI(ctx).Val())
+	})
+
+	t.Run("default user without password", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, User{Commands: []string{"ping", "acl"}}, User{Name: "alice", Password: "secret"})
+		ctx := context.Background()
+
+		client := newClient(t, rs, redis.Options{})
+		assert.NoError(t, client.Ping(ctx).Err())
+		assert.Equal(t, "default", client.ACLWhoAmI(ctx).Val())
+		assert.ErrorContains(t, client.Get(ctx, "foo").Err(), "NOPERM")
+	})
+
+	t.Run("hello", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, User{Name: "alice", Password: "secret"})
+		rs.EnableRESP3()
+		ctx := context.Background()
+
+		client := newClient(t, rs, redis.Options{Username: "alice", Password: "wrong", Protocol: 3})
+		assert.ErrorContains(t, client.Ping(ctx).Err(), "WRONGPASS")
+
+		client = newClient(t, rs, redis.Options{Username: "alice", Password: "secret", Protocol: 3})
+		require.NoError(t, client.Ping(ctx).Err())
+		assert.Contains(t, rs.GotCommands(), []string{"HELLO", "3", "auth", "alice", "secret"})
+
+		client = newClient(t, rs, redis.Options{Protocol: 3})
+		assert.ErrorContains(t, client.Do(ctx, "HELLO", "3").Err(), "NOAUTH HELLO must be called")
+	})
+}
@@ -0,0 +1,360 @@
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ClusterSlotsCount is the number of hash slots of a Redis cluster.
+const ClusterSlotsCount = 16384
+
+// KeySlot returns the hash slot of the provided key, as computed by Redis
+// cluster. Only the hash tag of the key, if it has one, is hashed.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key) % ClusterSlotsCount)
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) checksum used by Redis cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// StubCluster is a set of StubServers emulating a Redis cluster.
+//
+// The hash slots are evenly split among the master nodes, each of which can
+// have replicas. All the nodes answer the CLUSTER SLOTS, CLUSTER SHARDS,
+// CLUSTER KEYSLOT and COMMAND commands, the latter allowing clients to
+// extract keys from commands and to tell read-only commands apart.
+//
+// A node receiving a command for a key whose slot it does not serve replies
+// with a MOVED redirection to the master serving it. Replicas only serve the
+// read-only commands sent on connections on which the READONLY command was
+// issued, and redirect the others to their master. Slots being migrated
+// using MigrateSlot are redirected using ASK redirections.
+//
+// Nodes do not hold any data by default: command handlers are registered
+// on them using RegisterCommandHandler or UseKeyspace.
+type StubCluster struct {
+	// Masters holds the master nodes of the cluster.
+	Masters []*StubServer
+
+	// Replicas holds the replica nodes of each master, at the same index
+	// as the master in Masters.
+	Replicas [][]*StubServer
+
+	mu        sync.Mutex
+	owners    [ClusterSlotsCount]int
+	migrating map[int]int
+}
+
+// RunClusterT starts a new StubCluster of the provided number of master
+// nodes, each having the provided number of replicas, for a given test
+// context. It registers the test cleanup after your test is done.
+func RunClusterT(t testing.TB, masters, replicas int) *StubCluster {
+	t.Helper()
+
+	if masters < 1 {
+		t.Fatalf("could not start StubCluster; reason: at least one master is required")
+	}
+
+	sc := &StubCluster{
+		Masters:   make([]*StubServer, masters),
+		Replicas:  make([][]*StubServer, masters),
+		migrating: make(map[int]int),
+	}
+
+	for i := range masters {
+		sc.Masters[i] = RunT(t)
+		for range replicas {
+			sc.Replicas[i] = append(sc.Replicas[i], RunT(t))
+		}
+	}
+
+	for slot := range ClusterSlotsCount {
+		sc.owners[slot] = slot * masters / ClusterSlotsCount
+	}
+
+	for i, master := range sc.Masters {
+		sc.setupNode(master, i, false)
+		for _, replica := range sc.Replicas[i] {
+			sc.setupNode(replica, i, true)
+		}
+	}
+
+	return sc
+}
+
+// Nodes returns all the nodes of the cluster, masters first.
+func (sc *StubCluster) Nodes() []*StubServer {
+	nodes := append([]*StubServer(nil), sc.Masters...)
+	for _, replicas := range sc.Replicas {
+		nodes = append(nodes, replicas...)
+	}
+
+	return nodes
+}
+
+// Addrs returns the addresses of the master nodes of the cluster.
+func (sc *StubCluster) Addrs() []string {
+	addrs := make([]string, 0, len(sc.Masters))
+	for _, master := range sc.Masters {
+		addrs = append(addrs, master.Addr().String())
+	}
+
+	return addrs
+}
+
+// RegisterCommandHandler registers a handler for a redis command on all
+// the nodes of the cluster.
+func (sc *StubCluster) RegisterCommandHandler(command string, handler func(*Connection, []string)) {
+	for _, node := range sc.Nodes() {
+		node.RegisterCommandHandler(command, handler)
+	}
+}
+
+// UseKeyspace registers an in-memory Keyspace on each master of the
+// cluster, shared with its replicas.
+func (sc *StubCluster) UseKeyspace() {
+	for i, master := range sc.Masters {
+		ks := NewKeyspace()
+		master.UseKeyspace(ks)
+		for _, replica := range sc.Replicas[i] {
+			replica.UseKeyspace(ks)
+		}
+	}
+}
+
+// SlotOwner returns the index, in Masters, of the master serving the
+// provided slot.
+func (sc *StubCluster) SlotOwner(slot int) int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.owners[slot]
+}
+
+// MoveSlot makes the master at the provided index in Masters serve the
+// provided slot. Its previous master redirects clients using MOVED
+// redirections from then on.
+func (sc *StubCluster) MoveSlot(slot, master int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.owners[slot] = master
+	delete(sc.migrating, slot)
+}
+
+// MigrateSlot starts migrating the provided slot to the master at the
+// provided index in Masters. Its current master redirects clients using
+// ASK redirections, until the migration is completed using MoveSlot.
+func (sc *StubCluster) MigrateSlot(slot, master int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.migrating[slot] = master
+}
+
+// setupNode registers the cluster commands handlers on the provided node,
+// serving the slots of the master at the provided index.
+func (sc *StubCluster) setupNode(node *StubServer, master int, replica bool) {
+	node.RegisterCommandHandler("CLUSTER", sc.handleCluster)
+	node.RegisterCommandHandler("COMMAND", func(c *Connection, _ []string) {
+		writeCommandsInfo(c)
+	})
+	node.RegisterCommandHandler("READONLY", func(c *Connection, _ []string) {
+		c.readOnly = true
+		c.WriteOK()
+	})
+	node.RegisterCommandHandler("READWRITE", func(c *Connection, _ []string) {
+		c.readOnly = false
+		c.WriteOK()
+	})
+	node.RegisterCommandHandler("ASKING", func(c *Connection, _ []string) {
+		c.asking = true
+		c.WriteOK()
+	})
+
+	node.Lock()
+	node.redirect = func(c *Connection, command string, args []string) bool {
+		return sc.redirect(c, master, replica, command, args)
+	}
+	node.Unlock()
+}
+
+// redirect replies with a redirection if the command's keys are not served
+// by the node, and returns true if it did.
+func (sc *StubCluster) redirect(c *Connection, master int, replica bool, command string, args []string) bool {
+	asking := c.asking
+	if command != "ASKING" {
+		c.asking = false
+	}
+
+	info := lookupCommandInfo(command)
+	keys := info.keys(args)
+	if len(keys) == 0 {
+		return false
+	}
+
+	slot := KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if KeySlot(key) != slot {
+			c.WriteError(errors.New("CROSSSLOT Keys in request don't hash to the same slot"))
+			return true
+		}
+	}
+
+	sc.mu.Lock()
+	owner := sc.owners[slot]
+	target, isMigrating := sc.migrating[slot]
+	sc.mu.Unlock()
+
+	switch {
+	case isMigrating && target == master && asking && !replica:
+		return false
+	case isMigrating && owner == master && !replica:
+		c.WriteError(fmt.Errorf("ASK %d %s", slot, sc.Masters[target].Addr()))
+		return true
+	case owner == master && (!replica || (c.readOnly && info.readOnly)):
+		return false
+	default:
+		c.WriteError(fmt.Errorf("MOVED %d %s", slot, sc.Masters[owner].Addr()))
+		return true
+	}
+}
+
+// handleCluster handles the CLUSTER command's subcommands.
+func (sc *StubCluster) handleCluster(c *Connection, args []string) {
+	if len(args) == 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'cluster' command"))
+		return
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SLOTS":
+		sc.writeSlots(c)
+	case "SHARDS":
+		sc.writeShards(c)
+	case "KEYSLOT":
+		if len(args) != 2 {
+			c.WriteError(errors.New("ERR wrong number of arguments for 'cluster|keyslot' command"))
+			return
+		}
+		c.WriteInteger(KeySlot(args[1]))
+	case "INFO":
+		c.WriteBulkString(fmt.Sprintf(
+			"cluster_state:ok\r\ncluster_slots_assigned:%d\r\ncluster_known_nodes:%d\r\ncluster_size:%d\r\n",
+			ClusterSlotsCount, len(sc.Nodes()), len(sc.Masters),
+		))
+	default:
+		c.WriteError(fmt.Errorf("ERR unknown subcommand '%s'", args[0]))
+	}
+}
+
+// slotRange is a range of slots served by a master.
+type slotRange struct {
+	start, end, master int
+}
+
+// slotRanges returns the ranges of contiguous slots served by the same master.
+func (sc *StubCluster) slotRanges() []slotRange {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var ranges []slotRange
+	for slot, owner := range sc.owners {
+		if n := len(ranges); n > 0 && ranges[n-1].master == owner && ranges[n-1].end == slot-1 {
+			ranges[n-1].end = slot
+			continue
+		}
+		ranges = append(ranges, slotRange{start: slot, end: slot, master: owner})
+	}
+
+	return ranges
+}
+
+// nodeID returns the identifier of a node of the cluster.
+func nodeID(master, replica int) string {
+	return fmt.Sprintf("%020d%020d", master+1, replica)
+}
+
+func (sc *StubCluster) writeSlots(c *Connection) {
+	ranges := sc.slotRanges()
+
+	reply := make([]any, 0, len(ranges))
+	for _, r := range ranges {
+		entry := []any{r.start, r.end, nodeEntry(sc.Masters[r.master], nodeID(r.master, 0))}
+		for i, replica := range sc.Replicas[r.master] {
+			entry = append(entry, nodeEntry(replica, nodeID(r.master, i+1)))
+		}
+		reply = append(reply, entry)
+	}
+
+	c.WriteValue(reply)
+}
+
+func (sc *StubCluster) writeShards(c *Connection) {
+	ranges := sc.slotRanges()
+
+	reply := make([]any, 0, len(sc.Masters))
+	for master := range sc.Masters {
+		var slots []any
+		for _, r := range ranges {
+			if r.master == master {
+				slots = append(slots, r.start, r.end)
+			}
+		}
+
+		nodes := []any{shardNode(sc.Masters[master], nodeID(master, 0), "master")}
+		for i, replica := range sc.Replicas[master] {
+			nodes = append(nodes, shardNode(replica, nodeID(master, i+1), "replica"))
+		}
+
+		reply = append(reply, map[string]any{"slots": slots, "nodes": nodes})
+	}
+
+	c.WriteValue(reply)
+}
+
+// nodeEntry returns the description of a node in the CLUSTER SLOTS reply.
+func nodeEntry(node *StubServer, id string) []any {
+	return []any{node.Addr().IP.String(), node.Addr().Port, id}
+}
+
+// shardNode returns the description of a node in the CLUSTER SHARDS reply.
+func shardNode(node *StubServer, id, role string) map[string]any {
+	host, port, _ := net.SplitHostPort(node.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	return map[string]any{
+		"id":                 id,
+		"ip":                 host,
+		"endpoint":           host,
+		"port":               p,
+		"role":               role,
+		"replication-offset": 0,
+		"health":             "online",
+	}
+}
@@ -0,0 +1,200 @@
+package redistest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySlot(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 12739, KeySlot("123456789"))
+	assert.Equal(t, 12182, KeySlot("foo"))
+	assert.Equal(t, KeySlot("user1000"), KeySlot("{user1000}.following"))
+	assert.NotEqual(t, KeySlot("foo"), KeySlot("{}foo"))
+}
+
+func TestStubCluster(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, sc *StubCluster, opts redis.ClusterOptions) *redis.ClusterClient {
+		t.Helper()
+
+		opts.Addrs = sc.Addrs()
+		opts.Protocol = 2
+
+		client := redis.NewClusterClient(&opts)
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client
+	}
+
+	// gotKeys returns the keys of the commands of the provided name
+	// received by the node.
+	gotKeys := func(node *StubServer, command string) []string {
+		var keys []string
+		for _, cmd := range node.GotCommands() {
+			if cmd[0] == command {
+				keys = append(keys, cmd[1])
+			}
+		}
+		return keys
+	}
+
+	t.Run("commands are routed to the master serving their key", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 3, 0)
+		sc.UseKeyspace()
+		client := newClient(t, sc, redis.ClusterOptions{})
+
+		ctx := context.Background()
+		for i := range 20 {
+			key := fmt.Sprintf("key-%d", i)
+			require.NoError(t, client.Set(ctx, key, i, 0).Err())
+			assert.Contains(t, gotKeys(sc.Masters[sc.SlotOwner(KeySlot(key))], "SET"), key)
+			assert.Equal(t, fmt.Sprint(i), client.Get(ctx, key).Val())
+		}
+
+		for _, master := range sc.Masters {
+			assert.NotEmpty(t, gotKeys(master, "SET"))
+		}
+	})
+
+	t.Run("moved slots are redirected", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 2, 0)
+		sc.UseKeyspace()
+		client := newClient(t, sc, redis.ClusterOptions{})
+
+		ctx := context.Background()
+		slot := KeySlot("foo")
+		owner := sc.SlotOwner(slot)
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+
+		sc.MoveSlot(slot, 1-owner)
+		require.NoError(t, client.Set(ctx, "foo", "baz", 0).Err())
+
+		assert.Equal(t, []string{"foo", "foo"}, gotKeys(sc.Masters[owner], "SET"))
+		assert.Equal(t, []string{"foo"}, gotKeys(sc.Masters[1-owner], "SET"))
+	})
+
+	t.Run("migrating slots are redirected", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 2, 0)
+		sc.UseKeyspace()
+		client := newClient(t, sc, redis.ClusterOptions{})
+
+		ctx := context.Background()
+		slot := KeySlot("foo")
+		owner := sc.SlotOwner(slot)
+		require.NoError(t, client.Ping(ctx).Err())
+
+		sc.MigrateSlot(slot, 1-owner)
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+
+		commands := sc.Masters[1-owner].GotCommands()
+		i := slices.IndexFunc(commands, func(cmd []string) bool { return cmd[0] == "SET" })
+		require.Positive(t, i)
+		assert.Equal(t, []string{"ASKING"}, commands[i-1])
+		assert.Equal(t, owner, sc.SlotOwner(slot))
+	})
+
+	t.Run("redirections are limited", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 2, 0)
+		slot := KeySlot("foo")
+		for i, master := range sc.Masters {
+			master.InjectFault(Fault{
+				Command: "GET",
+				Error:   fmt.Errorf("MOVED %d %s", slot, sc.Masters[1-i].Addr()),
+			})
+		}
+		client := newClient(t, sc, redis.ClusterOptions{MaxRedirects: 2})
+
+		err := client.Get(context.Background(), "foo").Err()
+		assert.ErrorContains(t, err, "MOVED")
+		assert.Equal(t, 3, sc.Masters[0].InjectedFaultsCount()+sc.Masters[1].InjectedFaultsCount())
+	})
+
+	t.Run("replicas serve read-only commands", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 2, 1)
+		sc.UseKeyspace()
+		client := newClient(t, sc, redis.ClusterOptions{ReadOnly: true})
+
+		ctx := context.Background()
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+		assert.Equal(t, "bar", client.Get(ctx, "foo").Val())
+
+		owner := sc.SlotOwner(KeySlot("foo"))
+		assert.Equal(t, []string{"foo"}, gotKeys(sc.Masters[owner], "SET"))
+		assert.Empty(t, gotKeys(sc.Masters[owner], "GET"))
+		assert.Equal(t, []string{"foo"}, gotKeys(sc.Replicas[owner][0], "GET"))
+	})
+
+	t.Run("replicas redirect commands on read-write connections", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 1, 1)
+		sc.UseKeyspace()
+
+		client := redis.NewClient(&redis.Options{Addr: sc.Replicas[0][0].Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+
+		ctx := context.Background()
+		assert.ErrorContains(t, client.Get(ctx, "foo").Err(), "MOVED 12182 "+sc.Masters[0].Addr().String())
+
+		require.NoError(t, client.ReadOnly(ctx).Err())
+		assert.ErrorIs(t, client.Get(ctx, "foo").Err(), redis.Nil)
+		assert.ErrorContains(t, client.Set(ctx, "foo", "bar", 0).Err(), "MOVED")
+	})
+
+	t.Run("cross slot commands are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 1, 0)
+		sc.UseKeyspace()
+
+		client := redis.NewClient(&redis.Options{Addr: sc.Masters[0].Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+
+		ctx := context.Background()
+		assert.ErrorContains(t, client.MGet(ctx, "foo", "bar").Err(), "CROSSSLOT")
+		assert.NoError(t, client.MGet(ctx, "{tag}foo", "{tag}bar").Err())
+	})
+
+	t.Run("topology", func(t *testing.T) {
+		t.Parallel()
+
+		sc := RunClusterT(t, 2, 1)
+		client := newClient(t, sc, redis.ClusterOptions{})
+		ctx := context.Background()
+
+		slots, err := client.ClusterSlots(ctx).Result()
+		require.NoError(t, err)
+		require.Len(t, slots, 2)
+		assert.Equal(t, 0, slots[0].Start)
+		assert.Equal(t, ClusterSlotsCount/2-1, slots[0].End)
+		assert.Equal(t, sc.Masters[0].Addr().String(), slots[0].Nodes[0].Addr)
+		assert.Equal(t, sc.Replicas[0][0].Addr().String(), slots[0].Nodes[1].Addr)
+
+		shards, err := client.ClusterShards(ctx).Result()
+		require.NoError(t, err)
+		require.Len(t, shards, 2)
+		assert.Equal(t, []redis.SlotRange{{Start: ClusterSlotsCount / 2, End: ClusterSlotsCount - 1}}, shards[1].Slots)
+		assert.Equal(t, "replica", shards[1].Nodes[1].Role)
+
+		assert.Equal(t, int64(12182), client.ClusterKeySlot(ctx, "foo").Val())
+	})
+}
@@ -0,0 +1,280 @@
+package redistest
+
+import (
+	"strconv"
+	"strings"
+)
+
+// commandInfo describes a command the way the COMMAND command does, which
+// clients rely upon to extract the keys of a command, and to know whether
+// it can be served by a replica.
+type commandInfo struct {
+	// arity is the number of arguments of the command, including its name.
+	// A negative arity -N means that at least N arguments are expected.
+	arity int
+
+	// readOnly is true if the command does not modify the keyspace.
+	readOnly bool
+
+	// firstKey, lastKey and step describe the positions of the keys in the
+	// command's arguments, the name of the command being at position 0. The
+	// firstKey of commands without keys is 0, and a negative lastKey counts
+	// from the last argument.
+	firstKey, lastKey, step int
+
+	// numKeysAt is the position of the argument holding the number of keys
+	// of commands with movable keys, which immediately follow it. Their
+	// firstKey is 0, as Redis reports it.
+	numKeysAt int
+}
+
+// flags returns the flags of the command, as replied by the COMMAND command.
+func (ci commandInfo) flags() []string {
+	if ci.readOnly {
+		return []string{"readonly"}
+	}
+
+	return []string{"write"}
+}
+
+// keys returns the keys of the command, provided its arguments, excluding
+// its name.
+func (ci commandInfo) keys(args []string) []string {
+	if ci.numKeysAt > 0 && len(args) >= ci.numKeysAt {
+		n, err := strconv.Atoi(args[ci.numKeysAt-1])
+		if err != nil || n <= 0 {
+			return nil
+		}
+
+		return args[ci.numKeysAt:min(ci.numKeysAt+n, len(args))]
+	}
+
+	if ci.firstKey == 0 || len(args) < ci.firstKey {
+		return nil
+	}
+
+	last := ci.lastKey
+	if last < 0 {
+		last += len(args) + 1
+	}
+	last = min(last, len(args))
+
+	var keys []string
+	for i := ci.firstKey; i <= last; i += max(ci.step, 1) {
+		keys = append(keys, args[i-1])
+	}
+
+	return keys
+}
+
+// lookupCommandInfo returns the commandInfo of the provided command. Commands
+// which are not known are assumed to be write commands taking a single key,
+// as their first argument.
+func lookupCommandInfo(command string) commandInfo {
+	if info, ok := commandsInfo[command]; ok {
+		return info
+	}
+
+	return commandInfo{arity: -2, firstKey: 1, lastKey: 1, step: 1}
+}
+
+func keylessCommand(arity int) commandInfo {
+	return commandInfo{arity: arity, readOnly: true}
+}
+
+func readCommand(arity, firstKey, lastKey, step int) commandInfo {
+	return commandInfo{arity: arity, readOnly: true, firstKey: firstKey, lastKey: lastKey, step: step}
+}
+
+func writeCommand(arity, firstKey, lastKey, step int) commandInfo {
+	return commandInfo{arity: arity, firstKey: firstKey, lastKey: lastKey, step: step}
+}
+
+// commandsInfo holds the commandInfo of the commands known to the stub server.
+//
+//nolint:gochecknoglobals
+var commandsInfo = map[string]commandInfo{
+	// Connection and server commands
+	"ASKING":    keylessCommand(1),
+	"AUTH":      keylessCommand(-2),
+	"CLIENT":    keylessCommand(-2),
+	"CLUSTER":   keylessCommand(-2),
+	"COMMAND":   keylessCommand(-1),
+	"DBSIZE":    keylessCommand(1),
+	"ECHO":      keylessCommand(2),
+	"FLUSHALL":  {arity: -1},
+	"FLUSHDB":   {arity: -1},
+	"HELLO":     keylessCommand(-1),
+	"INFO":      keylessCommand(-1),
+	"KEYS":      keylessCommand(2),
+	"PING":      keylessCommand(-1),
+	"RANDOMKEY": keylessCommand(1),
+	"READONLY":  keylessCommand(1),
+	"READWRITE": keylessCommand(1),
+	"SCAN":      keylessCommand(-2),
+	"SELECT":    keylessCommand(2),
+
+	// Generic keys commands
+	"COPY":        writeCommand(-3, 1, 2, 1),
+	"DEL":         writeCommand(-2, 1, -1, 1),
+	"DUMP":        readCommand(2, 1, 1, 1),
+	"EXISTS":      readCommand(-2, 1, -1, 1),
+	"EXPIRE":      writeCommand(-3, 1, 1, 1),
+	"EXPIREAT":    writeCommand(-3, 1, 1, 1),
+	"EXPIRETIME":  readCommand(2, 1, 1, 1),
+	"OBJECT":      readCommand(-2, 2, 2, 1),
+	"PERSIST":     writeCommand(2, 1, 1, 1),
+	"PEXPIRE":     writeCommand(-3, 1, 1, 1),
+	"PEXPIREAT":   writeCommand(-3, 1, 1, 1),
+	"PEXPIRETIME": readCommand(2, 1, 1, 1),
+	"PTTL":        readCommand(2, 1, 1, 1),
+	"RENAME":      writeCommand(3, 1, 2, 1),
+	"RENAMENX":    writeCommand(3, 1, 2, 1),
+	"RESTORE":     writeCommand(-4, 1, 1, 1),
+	"TOUCH":       readCommand(-2, 1, -1, 1),
+	"TTL":         readCommand(2, 1, 1, 1),
+	"TYPE":        readCommand(2, 1, 1, 1),
+	"UNLINK":      writeCommand(-2, 1, -1, 1),
+
+	// Strings commands
+	"APPEND":      writeCommand(3, 1, 1, 1),
+	"DECR":        writeCommand(2, 1, 1, 1),
+	"DECRBY":      writeCommand(3, 1, 1, 1),
+	"GET":         readCommand(2, 1, 1, 1),
+	"GETDEL":      writeCommand(2, 1, 1, 1),
+	"GETEX":       writeCommand(-2, 1, 1, 1),
+	"GETRANGE":    readCommand(4, 1, 1, 1),
+	"GETSET":      writeCommand(3, 1, 1, 1),
+	"INCR":        writeCommand(2, 1, 1, 1),
+	"INCRBY":      writeCommand(3, 1, 1, 1),
+	"INCRBYFLOAT": writeCommand(3, 1, 1, 1),
+	"MGET":        readCommand(-2, 1, -1, 1),
+	"MSET":        writeCommand(-3, 1, -1, 2),
+	"MSETNX":      writeCommand(-3, 1, -1, 2),
+	"PSETEX":      writeCommand(4, 1, 1, 1),
+	"SET":         writeCommand(-3, 1, 1, 1),
+	"SETEX":       writeCommand(4, 1, 1, 1),
+	"SETNX":       writeCommand(3, 1, 1, 1),
+	"SETRANGE":    writeCommand(4, 1, 1, 1),
+	"STRLEN":      readCommand(2, 1, 1, 1),
+
+	// Bitmaps commands
+	"BITCOUNT": readCommand(-2, 1, 1, 1),
+	"BITFIELD": writeCommand(-2, 1, 1, 1),
+	"BITOP":    writeCommand(-4, 2, -1, 1),
+	"BITPOS":   readCommand(-3, 1, 1, 1),
+	"GETBIT":   readCommand(3, 1, 1, 1),
+	"SETBIT":   writeCommand(4, 1, 1, 1),
+
+	// HyperLogLogs commands
+	"PFADD":   writeCommand(-2, 1, 1, 1),
+	"PFCOUNT": readCommand(-2, 1, -1, 1),
+	"PFMERGE": writeCommand(-2, 1, -1, 1),
+
+	// Geospatial indexes commands
+	"GEOADD":         writeCommand(-5, 1, 1, 1),
+	"GEODIST":        readCommand(-4, 1, 1, 1),
+	"GEOHASH":        readCommand(-2, 1, 1, 1),
+	"GEOPOS":         readCommand(-2, 1, 1, 1),
+	"GEOSEARCH":      readCommand(-7, 1, 1, 1),
+	"GEOSEARCHSTORE": writeCommand(-8, 1, 2, 1),
+
+	// Lists commands
+	"BLMOVE":     writeCommand(6, 1, 2, 1),
+	"BLPOP":      writeCommand(-3, 1, -2, 1),
+	"BRPOP":      writeCommand(-3, 1, -2, 1),
+	"BRPOPLPUSH": writeCommand(4, 1, 2, 1),
+	"LINDEX":     readCommand(3, 1, 1, 1),
+	"LINSERT":    writeCommand(5, 1, 1, 1),
+	"LLEN":       readCommand(2, 1, 1, 1),
+	"LMOVE":      writeCommand(5, 1, 2, 1),
+	"LMPOP":      {arity: -4, numKeysAt: 1},
+	"LPOP":       writeCommand(-2, 1, 1, 1),
+	"LPOS":       readCommand(-3, 1, 1, 1),
+	"LPUSH":      writeCommand(-3, 1, 1, 1),
+	"LPUSHX":     writeCommand(-3, 1, 1, 1),
+	"LRANGE":     readCommand(4, 1, 1, 1),
+	"LREM":       writeCommand(4, 1, 1, 1),
+	"LSET":       writeCommand(4, 1, 1, 1),
+	"LTRIM":      writeCommand(4, 1, 1, 1),
+	"RPOP":       writeCommand(-2, 1, 1, 1),
+	"RPUSH":      writeCommand(-3, 1, 1, 1),
+	"RPUSHX":     writeCommand(-3, 1, 1, 1),
+
+	// Hashes commands
+	"HDEL":         writeCommand(-3, 1, 1, 1),
+	"HEXISTS":      readCommand(3, 1, 1, 1),
+	"HEXPIRE":      writeCommand(-6, 1, 1, 1),
+	"HGET":         readCommand(3, 1, 1, 1),
+	"HGETALL":      readCommand(2, 1, 1, 1),
+	"HGETEX":       writeCommand(-5, 1, 1, 1),
+	"HINCRBY":      writeCommand(4, 1, 1, 1),
+	"HINCRBYFLOAT": writeCommand(4, 1, 1, 1),
+	"HKEYS":        readCommand(2, 1, 1, 1),
+	"HLEN":         readCommand(2, 1, 1, 1),
+	"HMGET":        readCommand(-3, 1, 1, 1),
+	"HPERSIST":     writeCommand(-5, 1, 1, 1),
+	"HPEXPIRE":     writeCommand(-6, 1, 1, 1),
+	"HPTTL":        readCommand(-5, 1, 1, 1),
+	"HRANDFIELD":   readCommand(-2, 1, 1, 1),
+	"HSCAN":        readCommand(-3, 1, 1, 1),
+	"HSET":         writeCommand(-4, 1, 1, 1),
+	"HSETEX":       writeCommand(-6, 1, 1, 1),
+	"HSETNX":       writeCommand(4, 1, 1, 1),
+	"HSTRLEN":      readCommand(3, 1, 1, 1),
+	"HTTL":         readCommand(-5, 1, 1, 1),
+	"HVALS":        readCommand(2, 1, 1, 1),
+
+	// Sets commands
+	"SADD":        writeCommand(-3, 1, 1, 1),
+	"SCARD":       readCommand(2, 1, 1, 1),
+	"SDIFF":       readCommand(-2, 1, -1, 1),
+	"SDIFFSTORE":  writeCommand(-3, 1, -1, 1),
+	"SINTER":      readCommand(-2, 1, -1, 1),
+	"SINTERCARD":  {arity: -3, readOnly: true, numKeysAt: 1},
+	"SINTERSTORE": writeCommand(-3, 1, -1, 1),
+	"SISMEMBER":   readCommand(3, 1, 1, 1),
+	"SMEMBERS":    readCommand(2, 1, 1, 1),
+	"SMISMEMBER":  readCommand(-3, 1, 1, 1),
+	"SMOVE":       writeCommand(4, 1, 2, 1),
+	"SPOP":        writeCommand(-2, 1, 1, 1),
+	"SRANDMEMBER": readCommand(-2, 1, 1, 1),
+	"SREM":        writeCommand(-3, 1, 1, 1),
+	"SSCAN":       readCommand(-3, 1, 1, 1),
+	"SUNION":      readCommand(-2, 1, -1, 1),
+	"SUNIONSTORE": writeCommand(-3, 1, -1, 1),
+
+	// Sorted sets commands
+	"ZADD":    writeCommand(-4, 1, 1, 1),
+	"ZCARD":   readCommand(2, 1, 1, 1),
+	"ZINCRBY": writeCommand(4, 1, 1, 1),
+	"ZRANGE":  readCommand(-4, 1, 1, 1),
+	"ZRANK":   readCommand(3, 1, 1, 1),
+	"ZREM":    writeCommand(-3, 1, 1, 1),
+	"ZSCAN":   readCommand(-3, 1, 1, 1),
+	"ZSCORE":  readCommand(3, 1, 1, 1),
+
+	// Functions commands
+	"FCALL":    {arity: -3, numKeysAt: 2},
+	"FCALL_RO": {arity: -3, readOnly: true, numKeysAt: 2},
+	"FUNCTION": {arity: -2},
+}
+
+// writeCommandsInfo writes the reply to the COMMAND command, describing
+// the commands known to the stub server, in the format of Redis 5.
+func writeCommandsInfo(c *Connection) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteArrayHeader(len(commandsInfo))
+		for _, name := range sortedKeys(commandsInfo) {
+			info := commandsInfo[name]
+
+			w.WriteArrayHeader(6)
+			w.WriteBulkString(strings.ToLower(name))
+			w.WriteInteger(info.arity)
+			w.WriteValue(info.flags())
+			w.WriteInteger(info.firstKey)
+			w.WriteInteger(info.lastKey)
+			w.WriteInteger(info.step)
+		}
+	})
+}
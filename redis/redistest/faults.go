@@ -0,0 +1,173 @@
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Errors replied by a Redis server which is not able to process commands,
+// to be injected using a Fault.
+var (
+	// ErrLoading is replied by a Redis server still loading its dataset.
+	ErrLoading = errors.New("LOADING Redis is loading the dataset in memory")
+
+	// ErrBusy is replied by a Redis server busy running a script.
+	ErrBusy = errors.New("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSCRIPT.")
+
+	// ErrReadOnly is replied by a Redis replica to write commands.
+	ErrReadOnly = errors.New("READONLY You can't write against a read only replica.")
+)
+
+// Fault describes a misbehavior of the server, injected using
+// StubServer.InjectFault when it receives a command.
+//
+// The Latency is applied first, after which the server either replies with
+// the Error, drops the connection, replies partially, hangs, or, if none of
+// those is set, handles the command normally.
+type Fault struct {
+	// Command is the name of the command the fault applies to. If empty,
+	// the fault applies to all commands.
+	Command string
+
+	// Times is the number of times the fault is injected, after which it
+	// no longer applies. If zero, the fault is always injected.
+	Times int
+
+	// Probability is the probability, between 0 and 1, for the fault to be
+	// injected when a matching command is received. If zero, the fault is
+	// injected every time.
+	Probability float64
+
+	// Latency is the delay after which the command is processed.
+	Latency time.Duration
+
+	// Error is replied instead of processing the command, such as
+	// ErrLoading, ErrBusy or ErrReadOnly.
+	Error error
+
+	// Drop closes the connection without processing the command.
+	Drop bool
+
+	// Partial processes the command, but only writes the first half of
+	// its reply before closing the connection.
+	Partial bool
+
+	// Hang leaves the command unanswered, and stops reading from the
+	// connection until it is closed by the client, or the server is closed.
+	//
+	// A Hang fault applying to all commands thus emulates a server which
+	// accepts connections, but never replies on them.
+	Hang bool
+}
+
+// faultRule holds a fault injected in the server, and how many times it was.
+type faultRule struct {
+	fault    Fault
+	injected int
+}
+
+// InjectFault makes the server misbehave, as described by the provided
+// Fault, when receiving matching commands.
+//
+// Faults are evaluated in the order they were injected, and only the first
+// one which applies to a command is injected.
+func (rs *StubServer) InjectFault(fault Fault) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	fault.Command = strings.ToUpper(fault.Command)
+	rs.faults = append(rs.faults, &faultRule{fault: fault})
+}
+
+// ClearFaults removes all the faults injected in the server.
+func (rs *StubServer) ClearFaults() {
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.faults = nil
+}
+
+// InjectedFaultsCount returns the number of times faults were injected
+// since the server started.
+func (rs *StubServer) InjectedFaultsCount() int {
+	rs.Lock()
+	defer rs.Unlock()
+
+	return rs.injectedFaults
+}
+
+// matchFault returns the fault to inject when receiving the provided
+// command, if any.
+func (rs *StubServer) matchFault(command string) (Fault, bool) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	for _, rule := range rs.faults {
+		if rule.fault.Command != "" && rule.fault.Command != command {
+			continue
+		}
+
+		if rule.fault.Times > 0 && rule.injected >= rule.fault.Times {
+			continue
+		}
+
+		if rule.fault.Probability > 0 && rand.Float64() >= rule.fault.Probability { //nolint:gosec
+			continue
+		}
+
+		rule.injected++
+		rs.injectedFaults++
+
+		return rule.fault, true
+	}
+
+	return Fault{}, false
+}
+
+// handleFaultyCommand handles the provided command, injecting the
+// provided fault. It returns false if the connection must be closed.
+func (rs *StubServer) handleFaultyCommand(c *Connection, fault Fault, cmd string, args []string) bool {
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+
+	switch {
+	case fault.Error != nil:
+		c.WriteError(fault.Error)
+	case fault.Drop:
+		return false
+	case fault.Hang:
+		// Consume whatever the client sends until the connection is closed.
+		_, _ = io.Copy(io.Discard, c.reader)
+		return false
+	case fault.Partial:
+		var buf bytes.Buffer
+
+		c.mutex.Lock()
+		writer := c.writer
+		c.writer = bufio.NewWriter(&buf)
+		c.mutex.Unlock()
+
+		rs.handleCommand(c, cmd, args)
+
+		c.mutex.Lock()
+		_ = c.writer.Flush()
+		c.writer = writer
+		c.mutex.Unlock()
+
+		reply := buf.Bytes()
+		_, _ = c.writer.Write(reply[:len(reply)/2])
+		_ = c.flush()
+
+		return false
+	default:
+		rs.handleCommand(c, cmd, args)
+	}
+
+	return true
+}
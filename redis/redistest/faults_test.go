@@ -0,0 +1,150 @@
+package redistest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubServerFaults(t *testing.T) {
+	t.Parallel()
+
+	newServer := func(t *testing.T, faults ...Fault) *StubServer {
+		t.Helper()
+
+		rs := RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *Connection, _ []string) {
+			c.WriteBulkString("some fairly long value")
+		})
+		for _, fault := range faults {
+			rs.InjectFault(fault)
+		}
+
+		return rs
+	}
+
+	newClient := func(t *testing.T, rs *StubServer, opts redis.Options) *redis.Client {
+		t.Helper()
+
+		opts.Addr = rs.Addr().String()
+		opts.Protocol = 2
+		opts.MinRetryBackoff = time.Millisecond
+		opts.MaxRetryBackoff = time.Millisecond
+
+		client := redis.NewClient(&opts)
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client
+	}
+
+	t.Run("retriable errors are retried", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "get", Error: ErrLoading, Times: 2})
+		client := newClient(t, rs, redis.Options{MaxRetries: 3})
+
+		got, err := client.Get(context.Background(), "foo").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "some fairly long value", got)
+		assert.Equal(t, 2, rs.InjectedFaultsCount())
+		assert.Equal(t, 1, rs.HandledCommandsCount())
+		assert.Equal(t, [][]string{
+			{"HELLO", "2"},
+			{"GET", "foo"},
+			{"GET", "foo"},
+			{"GET", "foo"},
+		}, rs.GotCommands())
+	})
+
+	t.Run("non-retriable errors are replied", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "GET", Error: ErrBusy})
+		client := newClient(t, rs, redis.Options{MaxRetries: 3})
+
+		err := client.Get(context.Background(), "foo").Err()
+		assert.ErrorContains(t, err, "BUSY")
+		assert.Equal(t, 1, rs.InjectedFaultsCount())
+	})
+
+	t.Run("retries are exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "GET", Error: ErrReadOnly})
+		client := newClient(t, rs, redis.Options{MaxRetries: 2})
+
+		err := client.Get(context.Background(), "foo").Err()
+		assert.ErrorContains(t, err, "READONLY")
+		assert.Equal(t, 3, rs.InjectedFaultsCount())
+	})
+
+	t.Run("dropped connections", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "GET", Drop: true, Times: 1})
+		client := newClient(t, rs, redis.Options{MaxRetries: 1})
+
+		got, err := client.Get(context.Background(), "foo").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "some fairly long value", got)
+		assert.Equal(t, 2, rs.HandledConnectionsCount())
+	})
+
+	t.Run("partial replies", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "GET", Partial: true})
+		client := newClient(t, rs, redis.Options{MaxRetries: -1})
+
+		err := client.Get(context.Background(), "foo").Err()
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, redis.Nil)
+		assert.Equal(t, 1, rs.InjectedFaultsCount())
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t,
+			Fault{Command: "GET", Latency: 500 * time.Millisecond, Times: 1},
+			Fault{Command: "GET", Latency: 10 * time.Millisecond},
+		)
+		client := newClient(t, rs, redis.Options{MaxRetries: -1, ReadTimeout: 100 * time.Millisecond})
+
+		err := client.Get(context.Background(), "foo").Err()
+		assert.ErrorContains(t, err, "i/o timeout")
+
+		start := time.Now()
+		got, err := client.Get(context.Background(), "foo").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "some fairly long value", got)
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	})
+
+	t.Run("accept then hang", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Hang: true})
+		client := newClient(t, rs, redis.Options{MaxRetries: -1, ReadTimeout: 100 * time.Millisecond})
+
+		err := client.Get(context.Background(), "foo").Err()
+		assert.ErrorContains(t, err, "i/o timeout")
+		assert.Equal(t, 1, rs.HandledConnectionsCount())
+		assert.Zero(t, rs.HandledCommandsCount())
+	})
+
+	t.Run("cleared faults", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newServer(t, Fault{Command: "GET", Error: ErrLoading})
+		rs.ClearFaults()
+		client := newClient(t, rs, redis.Options{MaxRetries: -1})
+
+		require.NoError(t, client.Get(context.Background(), "foo").Err())
+		assert.Zero(t, rs.InjectedFaultsCount())
+	})
+}
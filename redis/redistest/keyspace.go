@@ -0,0 +1,4521 @@
+package redistest
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keyspace is an in-memory emulation of a Redis keyspace.
+//
+// Once registered on a StubServer using UseKeyspace, it handles the commands
+// operating on strings, lists, hashes, sets and sorted sets, as well as the
+// generic keys commands (DEL, EXISTS, EXPIRE, TTL, TYPE, KEYS, etc.), the
+// way a real Redis server would. This allows tests to exercise multi-command
+// flows without registering a handler for each of the commands involved.
+//
+// Keys, and hash fields, expire lazily, when they are accessed after their
+// expiration time.
+//
+// The emulation only supports a single database: the SELECT command is
+// accepted, but has no effect.
+type Keyspace struct {
+	// Now returns the current time, against which the expiration of keys
+	// is evaluated. It defaults to time.Now, and can be overridden to
+	// test expiration deterministically.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*keyspaceEntry
+}
+
+// NewKeyspace returns a new, empty, Keyspace.
+func NewKeyspace() *Keyspace {
+	return &Keyspace{
+		Now:     time.Now,
+		entries: make(map[string]*keyspaceEntry),
+	}
+}
+
+// UseKeyspace registers handlers for all the commands supported by the
+// provided Keyspace on the server.
+//
+// Handlers registered afterwards using RegisterCommandHandler take
+// precedence, which allows to override the emulation of specific commands.
+func (rs *StubServer) UseKeyspace(ks *Keyspace) {
+	for name, command := range keyspaceCommands {
+		rs.RegisterCommandHandler(name, func(c *Connection, args []string) {
+			ks.handle(name, command, c, args)
+		})
+	}
+}
+
+// Len returns the number of keys in the keyspace, excluding expired ones.
+func (ks *Keyspace) Len() int {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.expireAll()
+
+	return len(ks.entries)
+}
+
+// Keyspace errors, as returned by a real Redis server.
+var (
+	errWrongType    = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger   = errors.New("ERR value is not an integer or out of range")
+	errNotFloat     = errors.New("ERR value is not a valid float")
+	errSyntax       = errors.New("ERR syntax error")
+	errNoSuchKey    = errors.New("ERR no such key")
+	errOutOfRange   = errors.New("ERR index out of range")
+	errInvalidExpir = errors.New("ERR invalid expire time in 'set' command")
+	errOverflow     = errors.New("ERR increment or decrement would overflow")
+)
+
+type (
+	listValue []string
+	hashValue map[string]string
+	setValue  map[string]struct{}
+	zsetValue map[string]float64
+)
+
+// keyspaceEntry holds the value of a key, which is either a string, a
+// listValue, a hashValue, a setValue or a zsetValue, along with its
+// expiration time, if any.
+type keyspaceEntry struct {
+	value     any
+	expiresAt time.Time
+
+	// fieldsExpireAt holds the expiration time of the fields of a hash
+	// value which have one.
+	fieldsExpireAt map[string]time.Time
+
+	// accessedAt is the time the key was last accessed by a command, as
+	// reported by the OBJECT IDLETIME command.
+	accessedAt time.Time
+}
+
+// keyspaceCommand describes a command emulated by the Keyspace.
+type keyspaceCommand struct {
+	// arity is the number of arguments the command expects, excluding its
+	// name. A negative arity -N means that at least N arguments are expected.
+	arity int
+
+	// fn handles the command. It is called with the Keyspace locked.
+	fn func(ks *Keyspace, c *Connection, args []string)
+}
+
+// keyspaceCommands holds the commands emulated by the Keyspace.
+var keyspaceCommands = map[string]keyspaceCommand{
+	// Generic keys commands
+	"DEL":         {-1, (*Keyspace).del},
+	"UNLINK":      {-1, (*Keyspace).del},
+	"EXISTS":      {-1, (*Keyspace).exists},
+	"EXPIRE":      {2, (*Keyspace).expire},
+	"PEXPIRE":     {2, (*Keyspace).pexpire},
+	"EXPIREAT":    {2, (*Keyspace).expireAt},
+	"PEXPIREAT":   {2, (*Keyspace).pexpireAt},
+	"TTL":         {1, (*Keyspace).ttl},
+	"PTTL":        {1, (*Keyspace).pttl},
+	"EXPIRETIME":  {1, (*Keyspace).expireTime},
+	"PEXPIRETIME": {1, (*Keyspace).pexpireTime},
+	"PERSIST":     {1, (*Keyspace).persist},
+	"TYPE":        {1, (*Keyspace).typ},
+	"RENAME":      {2, (*Keyspace).rename},
+	"RENAMENX":    {2, (*Keyspace).renameNX},
+	"COPY":        {-2, (*Keyspace).copy},
+	"TOUCH":       {-1, (*Keyspace).exists},
+	"OBJECT":      {2, (*Keyspace).object},
+	"DUMP":        {1, (*Keyspace).dump},
+	"RESTORE":     {-3, (*Keyspace).restore},
+	"KEYS":        {1, (*Keyspace).keys},
+	"RANDOMKEY":   {0, (*Keyspace).randomKey},
+	"DBSIZE":      {0, (*Keyspace).dbSize},
+	"FLUSHDB":     {0, (*Keyspace).flush},
+	"FLUSHALL":    {0, (*Keyspace).flush},
+	"SELECT":      {1, (*Keyspace).selectDB},
+	"SCAN":        {-1, (*Keyspace).scan},
+
+	// Strings commands
+	"GET":         {1, (*Keyspace).get},
+	"SET":         {-2, (*Keyspace).set},
+	"SETNX":       {2, (*Keyspace).setNX},
+	"SETEX":       {3, (*Keyspace).setEx},
+	"PSETEX":      {3, (*Keyspace).psetEx},
+	"GETSET":      {2, (*Keyspace).getSet},
+	"GETDEL":      {1, (*Keyspace).getDel},
+	"GETEX":       {-1, (*Keyspace).getEx},
+	"MGET":        {-1, (*Keyspace).mget},
+	"MSET":        {-2, (*Keyspace).mset},
+	"MSETNX":      {-2, (*Keyspace).msetNX},
+	"INCR":        {1, (*Keyspace).incr},
+	"DECR":        {1, (*Keyspace).decr},
+	"INCRBY":      {2, (*Keyspace).incrBy},
+	"DECRBY":      {2, (*Keyspace).decrBy},
+	"INCRBYFLOAT": {2, (*Keyspace).incrByFloat},
+	"APPEND":      {2, (*Keyspace).append},
+	"STRLEN":      {1, (*Keyspace).strlen},
+	"GETRANGE":    {3, (*Keyspace).getRange},
+	"SETRANGE":    {3, (*Keyspace).setRange},
+
+	// Bitmaps commands
+	"SETBIT":   {3, (*Keyspace).setBit},
+	"GETBIT":   {2, (*Keyspace).getBit},
+	"BITCOUNT": {-1, (*Keyspace).bitCount},
+	"BITPOS":   {-2, (*Keyspace).bitPos},
+	"BITOP":    {-3, (*Keyspace).bitOp},
+	"BITFIELD": {-1, (*Keyspace).bitfield},
+
+	// HyperLogLogs commands
+	"PFADD":   {-1, (*Keyspace).pfadd},
+	"PFCOUNT": {-1, (*Keyspace).pfcount},
+	"PFMERGE": {-1, (*Keyspace).pfmerge},
+
+	// Geospatial indexes commands
+	"GEOADD":         {-4, (*Keyspace).geoadd},
+	"GEOPOS":         {-1, (*Keyspace).geopos},
+	"GEODIST":        {-3, (*Keyspace).geodist},
+	"GEOHASH":        {-1, (*Keyspace).geohash},
+	"GEOSEARCH":      {-6, (*Keyspace).geosearch},
+	"GEOSEARCHSTORE": {-7, (*Keyspace).geosearchStore},
+
+	// Lists commands
+	"LPUSH":   {-2, (*Keyspace).lpush},
+	"RPUSH":   {-2, (*Keyspace).rpush},
+	"LPOP":    {-1, (*Keyspace).lpop},
+	"RPOP":    {-1, (*Keyspace).rpop},
+	"LRANGE":  {3, (*Keyspace).lrange},
+	"LINDEX":  {2, (*Keyspace).lindex},
+	"LSET":    {3, (*Keyspace).lset},
+	"LREM":    {3, (*Keyspace).lrem},
+	"LLEN":    {1, (*Keyspace).llen},
+	"LPUSHX":  {-2, (*Keyspace).lpushX},
+	"RPUSHX":  {-2, (*Keyspace).rpushX},
+	"LINSERT": {4, (*Keyspace).linsert},
+	"LTRIM":   {3, (*Keyspace).ltrim},
+	"LPOS":    {-2, (*Keyspace).lpos},
+	"LMOVE":   {4, (*Keyspace).lmove},
+	"LMPOP":   {-3, (*Keyspace).lmpop},
+
+	// Blocking lists commands
+	"BLPOP":      {-2, (*Keyspace).blpop},
+	"BRPOP":      {-2, (*Keyspace).brpop},
+	"BLMOVE":     {5, (*Keyspace).blmove},
+	"BRPOPLPUSH": {3, (*Keyspace).brpoplpush},
+
+	// Hashes commands
+	"HSET":         {-3, (*Keyspace).hset},
+	"HSETNX":       {3, (*Keyspace).hsetNX},
+	"HGET":         {2, (*Keyspace).hget},
+	"HMGET":        {-2, (*Keyspace).hmget},
+	"HDEL":         {-2, (*Keyspace).hdel},
+	"HEXISTS":      {2, (*Keyspace).hexists},
+	"HGETALL":      {1, (*Keyspace).hgetall},
+	"HKEYS":        {1, (*Keyspace).hkeys},
+	"HVALS":        {1, (*Keyspace).hvals},
+	"HLEN":         {1, (*Keyspace).hlen},
+	"HINCRBY":      {3, (*Keyspace).hincrBy},
+	"HSCAN":        {-2, (*Keyspace).hscan},
+	"HINCRBYFLOAT": {3, (*Keyspace).hincrByFloat},
+	"HSTRLEN":      {2, (*Keyspace).hstrlen},
+	"HRANDFIELD":   {-1, (*Keyspace).hrandField},
+	"HEXPIRE":      {-5, (*Keyspace).hexpire},
+	"HPEXPIRE":     {-5, (*Keyspace).hpexpire},
+	"HTTL":         {-4, (*Keyspace).httl},
+	"HPTTL":        {-4, (*Keyspace).hpttl},
+	"HPERSIST":     {-4, (*Keyspace).hpersist},
+	"HGETEX":       {-4, (*Keyspace).hgetEx},
+	"HSETEX":       {-5, (*Keyspace).hsetEx},
+
+	// Sets commands
+	"SADD":        {-2, (*Keyspace).sadd},
+	"SREM":        {-2, (*Keyspace).srem},
+	"SISMEMBER":   {2, (*Keyspace).sismember},
+	"SMEMBERS":    {1, (*Keyspace).smembers},
+	"SCARD":       {1, (*Keyspace).scard},
+	"SRANDMEMBER": {-1, (*Keyspace).srandmember},
+	"SPOP":        {-1, (*Keyspace).spop},
+	"SSCAN":       {-2, (*Keyspace).sscan},
+	"SMISMEMBER":  {-2, (*Keyspace).smismember},
+	"SMOVE":       {3, (*Keyspace).smove},
+	"SINTER":      {-1, (*Keyspace).sinter},
+	"SUNION":      {-1, (*Keyspace).sunion},
+	"SDIFF":       {-1, (*Keyspace).sdiff},
+	"SINTERSTORE": {-2, (*Keyspace).sinterStore},
+	"SUNIONSTORE": {-2, (*Keyspace).sunionStore},
+	"SDIFFSTORE":  {-2, (*Keyspace).sdiffStore},
+	"SINTERCARD":  {-2, (*Keyspace).sinterCard},
+
+	// Sorted sets commands
+	"ZADD":    {-3, (*Keyspace).zadd},
+	"ZSCORE":  {2, (*Keyspace).zscore},
+	"ZINCRBY": {3, (*Keyspace).zincrBy},
+	"ZREM":    {-2, (*Keyspace).zrem},
+	"ZCARD":   {1, (*Keyspace).zcard},
+	"ZRANGE":  {-3, (*Keyspace).zrange},
+	"ZRANK":   {2, (*Keyspace).zrank},
+	"ZSCAN":   {-2, (*Keyspace).zscan},
+}
+
+// handle validates the number of arguments of the command, and calls its
+// handler with the Keyspace locked. The keys of the command are then marked
+// as accessed, but for the OBJECT command, which inspects their access
+// time, and the RESTORE command, which sets it.
+func (ks *Keyspace) handle(name string, command keyspaceCommand, c *Connection, args []string) {
+	if (command.arity >= 0 && len(args) != command.arity) || (command.arity < 0 && len(args) < -command.arity) {
+		c.WriteError(fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	command.fn(ks, c, args)
+
+	if name == "OBJECT" || name == "RESTORE" {
+		return
+	}
+
+	for _, key := range lookupCommandInfo(name).keys(args) {
+		if entry, ok := ks.entries[key]; ok {
+			entry.accessedAt = ks.Now()
+		}
+	}
+}
+
+// lookup returns the entry of the provided key, or nil if it does not
+// exist or has expired.
+func (ks *Keyspace) lookup(key string) *keyspaceEntry {
+	entry, ok := ks.entries[key]
+	if !ok {
+		return nil
+	}
+
+	if !entry.expiresAt.IsZero() && !ks.Now().Before(entry.expiresAt) {
+		delete(ks.entries, key)
+		return nil
+	}
+
+	// Hash fields expire lazily too, along with their hash once empty.
+	if hash, ok := entry.value.(hashValue); ok && len(entry.fieldsExpireAt) > 0 {
+		for field, expiresAt := range entry.fieldsExpireAt {
+			if !ks.Now().Before(expiresAt) {
+				delete(hash, field)
+				delete(entry.fieldsExpireAt, field)
+			}
+		}
+
+		if len(hash) == 0 {
+			delete(ks.entries, key)
+			return nil
+		}
+	}
+
+	return entry
+}
+
+// expireAll deletes all the expired keys.
+func (ks *Keyspace) expireAll() {
+	for key := range ks.entries {
+		ks.lookup(key)
+	}
+}
+
+// lookupValue returns the value of the provided key, if it exists and is
+// of type T. If the key holds a value of another type, a WRONGTYPE error
+// is written to the connection, and ok is false.
+func lookupValue[T any](ks *Keyspace, c *Connection, key string) (value T, exists bool, ok bool) {
+	entry := ks.lookup(key)
+	if entry == nil {
+		return value, false, true
+	}
+
+	value, ok = entry.value.(T)
+	if !ok {
+		c.WriteError(errWrongType)
+		return value, true, false
+	}
+
+	return value, true, true
+}
+
+// store sets the value of the provided key, preserving its expiration
+// time, if any. If the value is an empty collection, the key is deleted
+// instead, as Redis does.
+func (ks *Keyspace) store(key string, value any) {
+	empty := false
+	switch v := value.(type) {
+	case listValue:
+		empty = len(v) == 0
+	case hashValue:
+		empty = len(v) == 0
+	case setValue:
+		empty = len(v) == 0
+	case zsetValue:
+		empty = len(v) == 0
+	}
+
+	if empty {
+		delete(ks.entries, key)
+		return
+	}
+
+	if entry := ks.lookup(key); entry != nil {
+		if _, ok := value.(hashValue); !ok {
+			entry.fieldsExpireAt = nil
+		}
+		entry.value = value
+		return
+	}
+
+	ks.entries[key] = &keyspaceEntry{value: value}
+}
+
+func (ks *Keyspace) del(c *Connection, args []string) {
+	n := 0
+	for _, key := range args {
+		if ks.lookup(key) != nil {
+			delete(ks.entries, key)
+			n++
+		}
+	}
+
+	c.WriteInteger(n)
+}
+
+func (ks *Keyspace) exists(c *Connection, args []string) {
+	n := 0
+	for _, key := range args {
+		if ks.lookup(key) != nil {
+			n++
+		}
+	}
+
+	c.WriteInteger(n)
+}
+
+func (ks *Keyspace) expire(c *Connection, args []string) {
+	ks.expireIn(c, args, time.Second)
+}
+
+func (ks *Keyspace) pexpire(c *Connection, args []string) {
+	ks.expireIn(c, args, time.Millisecond)
+}
+
+// expireIn sets the expiration of the key to the provided amount of units
+// from now. Non-positive amounts delete the key.
+func (ks *Keyspace) expireIn(c *Connection, args []string, unit time.Duration) {
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	entry := ks.lookup(args[0])
+	if entry == nil {
+		c.WriteInteger(0)
+		return
+	}
+
+	if amount <= 0 {
+		delete(ks.entries, args[0])
+	} else {
+		entry.expiresAt = ks.Now().Add(time.Duration(amount) * unit)
+	}
+
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) expireAt(c *Connection, args []string) {
+	ks.expireAtTime(c, args, func(n int64) time.Time { return time.Unix(n, 0) })
+}
+
+func (ks *Keyspace) pexpireAt(c *Connection, args []string) {
+	ks.expireAtTime(c, args, time.UnixMilli)
+}
+
+// expireAtTime sets the expiration of the key to the Unix time obtained
+// using the provided function. Times in the past delete the key.
+func (ks *Keyspace) expireAtTime(c *Connection, args []string, unixTime func(int64) time.Time) {
+	timestamp, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	entry := ks.lookup(args[0])
+	if entry == nil {
+		c.WriteInteger(0)
+		return
+	}
+
+	expiresAt := unixTime(timestamp)
+	if !ks.Now().Before(expiresAt) {
+		delete(ks.entries, args[0])
+	} else {
+		entry.expiresAt = expiresAt
+	}
+
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) expireTime(c *Connection, args []string) {
+	ks.expirationTime(c, args[0], time.Time.Unix)
+}
+
+func (ks *Keyspace) pexpireTime(c *Connection, args []string) {
+	ks.expirationTime(c, args[0], time.Time.UnixMilli)
+}
+
+// expirationTime writes the Unix time at which the key expires, obtained
+// using the provided function, -2 if it does not exist, or -1 if it has
+// no expiration.
+func (ks *Keyspace) expirationTime(c *Connection, key string, unixTime func(time.Time) int64) {
+	entry := ks.lookup(key)
+	switch {
+	case entry == nil:
+		c.WriteInteger(-2)
+	case entry.expiresAt.IsZero():
+		c.WriteInteger(-1)
+	default:
+		c.WriteInteger(int(unixTime(entry.expiresAt)))
+	}
+}
+
+func (ks *Keyspace) ttl(c *Connection, args []string) {
+	ks.timeToLive(c, args[0], time.Second)
+}
+
+func (ks *Keyspace) pttl(c *Connection, args []string) {
+	ks.timeToLive(c, args[0], time.Millisecond)
+}
+
+// timeToLive writes the remaining time to live of the key in the provided
+// unit, -2 if it does not exist, or -1 if it has no expiration.
+func (ks *Keyspace) timeToLive(c *Connection, key string, unit time.Duration) {
+	entry := ks.lookup(key)
+	switch {
+	case entry == nil:
+		c.WriteInteger(-2)
+	case entry.expiresAt.IsZero():
+		c.WriteInteger(-1)
+	default:
+		remaining := entry.expiresAt.Sub(ks.Now())
+		c.WriteInteger(int((remaining + unit/2) / unit))
+	}
+}
+
+func (ks *Keyspace) persist(c *Connection, args []string) {
+	entry := ks.lookup(args[0])
+	if entry == nil || entry.expiresAt.IsZero() {
+		c.WriteInteger(0)
+		return
+	}
+
+	entry.expiresAt = time.Time{}
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) typ(c *Connection, args []string) {
+	entry := ks.lookup(args[0])
+	if entry == nil {
+		c.WriteSimpleString("none")
+		return
+	}
+
+	c.WriteSimpleString(typeName(entry.value))
+}
+
+// typeName returns the name of the type of the provided value, as
+// reported by the TYPE command.
+func typeName(value any) string {
+	switch value.(type) {
+	case listValue:
+		return "list"
+	case hashValue:
+		return "hash"
+	case setValue:
+		return "set"
+	case zsetValue:
+		return "zset"
+	default:
+		return "string"
+	}
+}
+
+func (ks *Keyspace) rename(c *Connection, args []string) {
+	if ks.lookup(args[0]) == nil {
+		c.WriteError(errNoSuchKey)
+		return
+	}
+
+	ks.moveEntry(args[0], args[1])
+	c.WriteOK()
+}
+
+func (ks *Keyspace) renameNX(c *Connection, args []string) {
+	if ks.lookup(args[0]) == nil {
+		c.WriteError(errNoSuchKey)
+		return
+	}
+
+	if ks.lookup(args[1]) != nil {
+		c.WriteInteger(0)
+		return
+	}
+
+	ks.moveEntry(args[0], args[1])
+	c.WriteInteger(1)
+}
+
+// moveEntry moves the entry of the source key, along with its expiration
+// time, to the destination key, replacing it if it exists.
+func (ks *Keyspace) moveEntry(source, destination string) {
+	entry := ks.entries[source]
+	delete(ks.entries, source)
+	ks.entries[destination] = entry
+}
+
+func (ks *Keyspace) copy(c *Connection, args []string) {
+	source, destination := args[0], args[1]
+
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "REPLACE":
+			replace = true
+		case option == "DB" && i+1 < len(args):
+			i++
+			db, err := strconv.Atoi(args[i])
+			if err != nil {
+				c.WriteError(errNotInteger)
+				return
+			}
+			if db != 0 {
+				c.WriteError(errors.New("ERR DB index is out of range"))
+				return
+			}
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	if source == destination {
+		c.WriteError(errors.New("ERR source and destination objects are the same"))
+		return
+	}
+
+	entry := ks.lookup(source)
+	if entry == nil || (!replace && ks.lookup(destination) != nil) {
+		c.WriteInteger(0)
+		return
+	}
+
+	ks.entries[destination] = &keyspaceEntry{
+		value:          cloneValue(entry.value),
+		expiresAt:      entry.expiresAt,
+		fieldsExpireAt: maps.Clone(entry.fieldsExpireAt),
+	}
+	c.WriteInteger(1)
+}
+
+// cloneValue returns a deep copy of the provided value.
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case listValue:
+		return slices.Clone(v)
+	case hashValue:
+		return maps.Clone(v)
+	case setValue:
+		return maps.Clone(v)
+	case zsetValue:
+		return maps.Clone(v)
+	default:
+		return value
+	}
+}
+
+// object handles the ENCODING, FREQ and IDLETIME subcommands of the OBJECT
+// command.
+func (ks *Keyspace) object(c *Connection, args []string) {
+	subcommand := strings.ToUpper(args[0])
+	if subcommand != "ENCODING" && subcommand != "FREQ" && subcommand != "IDLETIME" {
+		c.WriteError(fmt.Errorf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[0]))
+		return
+	}
+
+	entry := ks.lookup(args[1])
+	if entry == nil {
+		c.WriteNull()
+		return
+	}
+
+	switch subcommand {
+	case "ENCODING":
+		c.WriteBulkString(encodingName(entry.value))
+	case "FREQ":
+		// As a Redis server running with the default maxmemory policy.
+		c.WriteError(errors.New("ERR An LFU maxmemory policy is not selected, access frequency not tracked. " +
+			"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."))
+	case "IDLETIME":
+		c.WriteInteger(int(ks.Now().Sub(entry.accessedAt) / time.Second))
+	}
+}
+
+// encodingName returns the name of the encoding of the provided value, as
+// reported by the OBJECT ENCODING command for small values.
+func encodingName(value any) string {
+	switch v := value.(type) {
+	case string:
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return "int"
+		}
+		if len(v) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case setValue:
+		for member := range v {
+			if _, err := strconv.ParseInt(member, 10, 64); err != nil {
+				return "listpack"
+			}
+		}
+		return "intset"
+	default:
+		return "listpack"
+	}
+}
+
+// dumpPayload is the serialized form of a value, as replied by the DUMP
+// command and accepted by the RESTORE command. Unlike the RDB format used
+// by Redis, it can only be restored by a Keyspace.
+type dumpPayload struct {
+	Type   string             `json:"type"`
+	String string             `json:"string,omitempty"`
+	List   []string           `json:"list,omitempty"`
+	Hash   map[string]string  `json:"hash,omitempty"`
+	Set    []string           `json:"set,omitempty"`
+	Zset   map[string]float64 `json:"zset,omitempty"`
+}
+
+// errBadPayload is returned by the RESTORE command for invalid payloads.
+var errBadPayload = errors.New("ERR DUMP payload version or checksum are wrong")
+
+func (ks *Keyspace) dump(c *Connection, args []string) {
+	entry := ks.lookup(args[0])
+	if entry == nil {
+		c.WriteNull()
+		return
+	}
+
+	payload := dumpPayload{Type: typeName(entry.value)}
+	switch v := entry.value.(type) {
+	case string:
+		payload.String = v
+	case listValue:
+		payload.List = v
+	case hashValue:
+		payload.Hash = v
+	case setValue:
+		payload.Set = sortedKeys(v)
+	case zsetValue:
+		payload.Zset = v
+	}
+
+	serialized, err := json.Marshal(payload)
+	if err != nil {
+		c.WriteError(fmt.Errorf("ERR %w", err))
+		return
+	}
+
+	c.WriteBulkString(string(serialized))
+}
+
+// restore handles the RESTORE command, and its REPLACE, ABSTTL and IDLETIME
+// options. The FREQ option is rejected, as for a Redis server running with
+// the default maxmemory policy.
+//
+//nolint:cyclop
+func (ks *Keyspace) restore(c *Connection, args []string) {
+	key := args[0]
+
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if ttl < 0 {
+		c.WriteError(errors.New("ERR Invalid TTL value, must be >= 0"))
+		return
+	}
+
+	var (
+		replace, absTTL bool
+		idleTime        int64
+	)
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "REPLACE":
+			replace = true
+		case option == "ABSTTL":
+			absTTL = true
+		case option == "IDLETIME" && i+1 < len(args):
+			i++
+			if idleTime, err = strconv.ParseInt(args[i], 10, 64); err != nil || idleTime < 0 {
+				c.WriteError(errors.New("ERR Invalid IDLETIME value, must be >= 0"))
+				return
+			}
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	var payload dumpPayload
+	if err := json.Unmarshal([]byte(args[2]), &payload); err != nil {
+		c.WriteError(errBadPayload)
+		return
+	}
+
+	var value any
+	switch payload.Type {
+	case "string":
+		value = payload.String
+	case "list":
+		value = listValue(payload.List)
+	case "hash":
+		value = hashValue(payload.Hash)
+	case "set":
+		set := make(setValue, len(payload.Set))
+		for _, member := range payload.Set {
+			set[member] = struct{}{}
+		}
+		value = set
+	case "zset":
+		value = zsetValue(payload.Zset)
+	default:
+		c.WriteError(errBadPayload)
+		return
+	}
+
+	if !replace && ks.lookup(key) != nil {
+		c.WriteError(errors.New("BUSYKEY Target key name already exists."))
+		return
+	}
+
+	var expiresAt time.Time
+	switch {
+	case ttl == 0:
+	case absTTL:
+		expiresAt = time.UnixMilli(ttl)
+	default:
+		expiresAt = ks.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+
+	delete(ks.entries, key)
+	if !expiresAt.IsZero() && !ks.Now().Before(expiresAt) {
+		c.WriteOK()
+		return
+	}
+
+	ks.entries[key] = &keyspaceEntry{
+		value:      value,
+		expiresAt:  expiresAt,
+		accessedAt: ks.Now().Add(-time.Duration(idleTime) * time.Second),
+	}
+	c.WriteOK()
+}
+
+func (ks *Keyspace) keys(c *Connection, args []string) {
+	pattern, err := compilePattern(args[0])
+	if err != nil {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	ks.expireAll()
+
+	var keys []string
+	for key := range ks.entries {
+		if pattern.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	writeStrings(c, keys)
+}
+
+func (ks *Keyspace) randomKey(c *Connection, _ []string) {
+	ks.expireAll()
+
+	if len(ks.entries) == 0 {
+		c.WriteNull()
+		return
+	}
+
+	keys := make([]string, 0, len(ks.entries))
+	for key := range ks.entries {
+		keys = append(keys, key)
+	}
+
+	c.WriteBulkString(keys[rand.IntN(len(keys))]) //nolint:gosec
+}
+
+func (ks *Keyspace) dbSize(c *Connection, _ []string) {
+	ks.expireAll()
+	c.WriteInteger(len(ks.entries))
+}
+
+func (ks *Keyspace) flush(c *Connection, _ []string) {
+	ks.entries = make(map[string]*keyspaceEntry)
+	c.WriteOK()
+}
+
+func (ks *Keyspace) selectDB(c *Connection, args []string) {
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	c.WriteOK()
+}
+
+// scanOptions holds the options of the SCAN, HSCAN, SSCAN and ZSCAN
+// commands.
+type scanOptions struct {
+	cursor   int
+	count    int
+	pattern  *regexp.Regexp
+	typ      string
+	noValues bool
+}
+
+// parseScanOptions parses the cursor and the options of a command of the
+// SCAN family, which accepts the provided flags on top of MATCH and COUNT.
+// If they are invalid, an error is written to the connection, and ok is
+// false.
+func parseScanOptions(c *Connection, args []string, flags ...string) (opts scanOptions, ok bool) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		c.WriteError(errors.New("ERR invalid cursor"))
+		return opts, false
+	}
+	opts = scanOptions{cursor: cursor, count: 10}
+
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "NOVALUES" && slices.Contains(flags, option):
+			opts.noValues = true
+		case i+1 >= len(args):
+			c.WriteError(errSyntax)
+			return opts, false
+		case option == "MATCH":
+			i++
+			if opts.pattern, err = compilePattern(args[i]); err != nil {
+				c.WriteError(errSyntax)
+				return opts, false
+			}
+		case option == "COUNT":
+			i++
+			if opts.count, err = strconv.Atoi(args[i]); err != nil || opts.count < 1 {
+				c.WriteError(errSyntax)
+				return opts, false
+			}
+		case option == "TYPE" && slices.Contains(flags, option):
+			i++
+			opts.typ = strings.ToLower(args[i])
+		default:
+			c.WriteError(errSyntax)
+			return opts, false
+		}
+	}
+
+	return opts, true
+}
+
+// page returns the elements of the page of the provided sorted elements
+// starting at the cursor, and the cursor of the next page, which is 0 once
+// all the elements were returned.
+//
+// As Redis does, the MATCH pattern is applied to the elements of the page,
+// which can thus be empty even if elements remain.
+func (opts scanOptions) page(elements []string) ([]string, int) {
+	start := min(opts.cursor, len(elements))
+	end := min(start+opts.count, len(elements))
+
+	next := end
+	if end == len(elements) {
+		next = 0
+	}
+
+	var page []string
+	for _, element := range elements[start:end] {
+		if opts.pattern == nil || opts.pattern.MatchString(element) {
+			page = append(page, element)
+		}
+	}
+
+	return page, next
+}
+
+// writeScanReply writes the reply of a command of the SCAN family.
+func writeScanReply(c *Connection, next int, elements []string) {
+	if elements == nil {
+		elements = []string{}
+	}
+
+	c.WriteValue([]any{strconv.Itoa(next), elements})
+}
+
+func (ks *Keyspace) scan(c *Connection, args []string) {
+	opts, ok := parseScanOptions(c, args, "TYPE")
+	if !ok {
+		return
+	}
+
+	ks.expireAll()
+
+	keys, next := opts.page(sortedKeys(ks.entries))
+	if opts.typ != "" {
+		keys = slices.DeleteFunc(keys, func(key string) bool {
+			return typeName(ks.entries[key].value) != opts.typ
+		})
+	}
+
+	writeScanReply(c, next, keys)
+}
+
+func (ks *Keyspace) get(c *Connection, args []string) {
+	value, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(value)
+}
+
+// set handles the SET command, and its NX, XX, GET, EX, PX, EXAT, PXAT
+// and KEEPTTL options.
+//
+//nolint:cyclop,funlen
+func (ks *Keyspace) set(c *Connection, args []string) {
+	key, value := args[0], args[1]
+
+	var (
+		nx, xx, get, keepTTL bool
+		expiresAt            time.Time
+	)
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) || !expiresAt.IsZero() {
+				c.WriteError(errSyntax)
+				return
+			}
+			i++
+
+			amount, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				c.WriteError(errNotInteger)
+				return
+			}
+			if amount <= 0 {
+				c.WriteError(errInvalidExpir)
+				return
+			}
+
+			switch option {
+			case "EX":
+				expiresAt = ks.Now().Add(time.Duration(amount) * time.Second)
+			case "PX":
+				expiresAt = ks.Now().Add(time.Duration(amount) * time.Millisecond)
+			case "EXAT":
+				expiresAt = time.Unix(amount, 0)
+			case "PXAT":
+				expiresAt = time.UnixMilli(amount)
+			}
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	if (nx && xx) || (keepTTL && !expiresAt.IsZero()) {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	entry := ks.lookup(key)
+
+	var previous *string
+	if entry != nil && get {
+		s, ok := entry.value.(string)
+		if !ok {
+			c.WriteError(errWrongType)
+			return
+		}
+		previous = &s
+	}
+
+	if (nx && entry != nil) || (xx && entry == nil) {
+		writeNullable(c, previous)
+		return
+	}
+
+	if keepTTL && entry != nil {
+		entry.value = value
+	} else {
+		ks.entries[key] = &keyspaceEntry{value: value, expiresAt: expiresAt}
+	}
+
+	if get {
+		writeNullable(c, previous)
+		return
+	}
+
+	c.WriteOK()
+}
+
+func (ks *Keyspace) setNX(c *Connection, args []string) {
+	if ks.lookup(args[0]) != nil {
+		c.WriteInteger(0)
+		return
+	}
+
+	ks.entries[args[0]] = &keyspaceEntry{value: args[1]}
+	c.WriteInteger(1)
+}
+func (ks *Keyspace) setEx(c *Connection, args []string) {
+	ks.setExpiring(c, "setex", args, time.Second)
+}
+
+func (ks *Keyspace) psetEx(c *Connection, args []string) {
+	ks.setExpiring(c, "psetex", args, time.Millisecond)
+}
+
+// setExpiring handles the named SETEX or PSETEX command, whose time to live
+// is expressed in the provided unit.
+func (ks *Keyspace) setExpiring(c *Connection, name string, args []string, unit time.Duration) {
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if amount <= 0 {
+		c.WriteError(fmt.Errorf("ERR invalid expire time in '%s' command", name))
+		return
+	}
+
+	ks.entries[args[0]] = &keyspaceEntry{value: args[2], expiresAt: ks.Now().Add(time.Duration(amount) * unit)}
+	c.WriteOK()
+}
+
+func (ks *Keyspace) getSet(c *Connection, args []string) {
+	previous, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	ks.entries[args[0]] = &keyspaceEntry{value: args[1]}
+
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(previous)
+}
+
+func (ks *Keyspace) getDel(c *Connection, args []string) {
+	value, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	delete(ks.entries, args[0])
+	c.WriteBulkString(value)
+}
+func (ks *Keyspace) getEx(c *Connection, args []string) {
+	opts, rest, ok := ks.parseFieldExpiration(c, args[1:], "PERSIST")
+	if !ok {
+		return
+	}
+	if len(rest) > 0 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	value, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	switch entry := ks.entries[args[0]]; {
+	case opts.persist:
+		entry.expiresAt = time.Time{}
+	case opts.expiresAt.IsZero():
+	case !ks.Now().Before(opts.expiresAt):
+		delete(ks.entries, args[0])
+	default:
+		entry.expiresAt = opts.expiresAt
+	}
+
+	c.WriteBulkString(value)
+}
+
+func (ks *Keyspace) mget(c *Connection, args []string) {
+	values := make([]*string, 0, len(args))
+	for _, key := range args {
+		entry := ks.lookup(key)
+		if entry == nil {
+			values = append(values, nil)
+			continue
+		}
+
+		// Keys holding a non-string value are reported as missing.
+		if s, ok := entry.value.(string); ok {
+			values = append(values, &s)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	writeNullableStrings(c, values)
+}
+
+func (ks *Keyspace) mset(c *Connection, args []string) {
+	if len(args)%2 != 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'mset' command"))
+		return
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		ks.entries[args[i]] = &keyspaceEntry{value: args[i+1]}
+	}
+
+	c.WriteOK()
+}
+func (ks *Keyspace) msetNX(c *Connection, args []string) {
+	if len(args)%2 != 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'msetnx' command"))
+		return
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if ks.lookup(args[i]) != nil {
+			c.WriteInteger(0)
+			return
+		}
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		ks.entries[args[i]] = &keyspaceEntry{value: args[i+1]}
+	}
+
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) incr(c *Connection, args []string) {
+	ks.incrementBy(c, args[0], 1)
+}
+
+func (ks *Keyspace) decr(c *Connection, args []string) {
+	ks.incrementBy(c, args[0], -1)
+}
+
+func (ks *Keyspace) incrBy(c *Connection, args []string) {
+	increment, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	ks.incrementBy(c, args[0], increment)
+}
+
+func (ks *Keyspace) decrBy(c *Connection, args []string) {
+	decrement, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || decrement == math.MinInt64 {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	ks.incrementBy(c, args[0], -decrement)
+}
+func (ks *Keyspace) incrByFloat(c *Connection, args []string) {
+	increment, err := parseFloat(args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	value, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	var current float64
+	if exists {
+		if current, err = parseFloat(value); err != nil {
+			c.WriteError(err)
+			return
+		}
+	}
+
+	current += increment
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		c.WriteError(errors.New("ERR increment would produce NaN or Infinity"))
+		return
+	}
+
+	ks.store(args[0], formatFloat(current))
+	c.WriteBulkString(formatFloat(current))
+}
+
+// incrementBy increments the integer stored as a string at `key`, and
+// writes its new value.
+func (ks *Keyspace) incrementBy(c *Connection, key string, increment int64) {
+	value, exists, ok := lookupValue[string](ks, c, key)
+	if !ok {
+		return
+	}
+
+	var current int64
+	if exists {
+		var err error
+		if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+	}
+
+	if (increment > 0 && current > math.MaxInt64-increment) || (increment < 0 && current < math.MinInt64-increment) {
+		c.WriteError(errOverflow)
+		return
+	}
+
+	current += increment
+	ks.store(key, strconv.FormatInt(current, 10))
+	c.WriteInteger(int(current))
+}
+
+func (ks *Keyspace) append(c *Connection, args []string) {
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	value += args[1]
+	ks.store(args[0], value)
+	c.WriteInteger(len(value))
+}
+
+func (ks *Keyspace) strlen(c *Connection, args []string) {
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(value))
+}
+func (ks *Keyspace) getRange(c *Connection, args []string) {
+	start, errStart := strconv.Atoi(args[1])
+	end, errEnd := strconv.Atoi(args[2])
+	if errStart != nil || errEnd != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	// Negative offsets count from the end of the string, and the range is
+	// clamped to its bounds, as Redis does.
+	if start < 0 {
+		start = max(len(value)+start, 0)
+	}
+	if end < 0 {
+		end = len(value) + end
+	}
+	end = min(end, len(value)-1)
+
+	if start > end || len(value) == 0 {
+		c.WriteBulkString("")
+		return
+	}
+
+	c.WriteBulkString(value[start : end+1])
+}
+
+func (ks *Keyspace) setRange(c *Connection, args []string) {
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	if offset < 0 {
+		c.WriteError(errors.New("ERR offset is out of range"))
+		return
+	}
+
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	// An empty value leaves the key untouched, and does not create it.
+	if args[2] == "" {
+		c.WriteInteger(len(value))
+		return
+	}
+
+	buf := []byte(value)
+	if end := offset + len(args[2]); end > len(buf) {
+		buf = append(buf, make([]byte, end-len(buf))...)
+	}
+	copy(buf[offset:], args[2])
+
+	ks.store(args[0], string(buf))
+	c.WriteInteger(len(buf))
+}
+
+// errBitOffset is returned for invalid bit offsets.
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// maxBitOffset is the largest bit offset of a string, as limited by the
+// maximum size of a string in Redis, 512MB.
+const maxBitOffset = 512<<23 - 1
+
+// parseBitOffset parses the bit offset of the SETBIT and GETBIT commands.
+func parseBitOffset(s string) (int, error) {
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 || offset > maxBitOffset {
+		return 0, errBitOffset
+	}
+
+	return offset, nil
+}
+
+// getBit returns the bit of the provided string at the provided offset,
+// bits being numbered from the most significant bit of the first byte.
+func getBit(value []byte, offset int) int {
+	if offset/8 >= len(value) {
+		return 0
+	}
+
+	return int(value[offset/8]>>(7-offset%8)) & 1
+}
+
+// setBit sets the bit of the provided string at the provided offset,
+// growing it as needed, and returns the resulting string.
+func setBit(value []byte, offset, bit int) []byte {
+	if n := offset/8 + 1; n > len(value) {
+		value = append(value, make([]byte, n-len(value))...)
+	}
+
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		value[offset/8] |= mask
+	} else {
+		value[offset/8] &^= mask
+	}
+
+	return value
+}
+
+func (ks *Keyspace) setBit(c *Connection, args []string) {
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	if args[2] != "0" && args[2] != "1" {
+		c.WriteError(errors.New("ERR bit is not an integer or out of range"))
+		return
+	}
+
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	buf := []byte(value)
+	previous := getBit(buf, offset)
+	ks.store(args[0], string(setBit(buf, offset, int(args[2][0]-'0'))))
+	c.WriteInteger(previous)
+}
+
+func (ks *Keyspace) getBit(c *Connection, args []string) {
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		c.WriteError(err)
+		return
+	}
+
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(getBit([]byte(value), offset))
+}
+
+// bitRange holds the range of bits a BITCOUNT or BITPOS command operates
+// on, both ends being inclusive.
+type bitRange struct {
+	start, end int
+
+	// endSet is true if the end of the range was provided.
+	endSet bool
+}
+
+// parseBitRange parses the optional `start [end [BYTE|BIT]]` arguments of
+// the BITCOUNT and BITPOS commands, for a string of the provided length,
+// in bytes. Negative offsets count from the end of the string. It writes
+// an error to the connection if they are invalid.
+func parseBitRange(c *Connection, args []string, length int) (r bitRange, ok bool) {
+	r = bitRange{start: 0, end: length*8 - 1}
+	if len(args) == 0 {
+		return r, true
+	}
+	if len(args) > 3 {
+		c.WriteError(errSyntax)
+		return r, false
+	}
+
+	unit, bits := 8, length*8
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			unit = 1
+		default:
+			c.WriteError(errSyntax)
+			return r, false
+		}
+	}
+
+	offsets := make([]int, 0, 2)
+	for _, arg := range args[:min(len(args), 2)] {
+		offset, err := strconv.Atoi(arg)
+		if err != nil {
+			c.WriteError(errNotInteger)
+			return r, false
+		}
+		if offset < 0 {
+			offset += bits / unit
+		}
+		offsets = append(offsets, max(offset, 0))
+	}
+
+	r.start = offsets[0] * unit
+	if len(offsets) == 2 {
+		r.end, r.endSet = min(offsets[1]*unit+unit-1, bits-1), true
+	}
+
+	return r, true
+}
+
+func (ks *Keyspace) bitCount(c *Connection, args []string) {
+	if len(args) == 2 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	r, ok := parseBitRange(c, args[1:], len(value))
+	if !ok {
+		return
+	}
+
+	buf, n := []byte(value), 0
+	for offset := r.start; offset <= r.end; offset++ {
+		n += getBit(buf, offset)
+	}
+
+	c.WriteInteger(n)
+}
+
+func (ks *Keyspace) bitPos(c *Connection, args []string) {
+	if args[1] != "0" && args[1] != "1" {
+		c.WriteError(errors.New("ERR The bit argument must be 1 or 0."))
+		return
+	}
+	bit := int(args[1][0] - '0')
+
+	value, exists, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	r, ok := parseBitRange(c, args[2:], len(value))
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteInteger(-bit)
+		return
+	}
+
+	buf := []byte(value)
+	for offset := r.start; offset <= r.end; offset++ {
+		if getBit(buf, offset) == bit {
+			c.WriteInteger(offset)
+			return
+		}
+	}
+
+	// When looking for a clear bit without an explicit end, the string is
+	// considered to be padded with zeros on the right.
+	if bit == 0 && !r.endSet && r.start <= r.end {
+		c.WriteInteger(len(buf) * 8)
+		return
+	}
+
+	c.WriteInteger(-1)
+}
+
+// bitOperations holds the operations of the BITOP command, applied byte
+// by byte to the first key, and to the union of the other keys.
+var bitOperations = map[string]func(first, others byte, all []byte) byte{
+	"AND": func(_, _ byte, all []byte) byte {
+		result := byte(0xff)
+		for _, b := range all {
+			result &= b
+		}
+		return result
+	},
+	"OR": func(first, others byte, _ []byte) byte { return first | others },
+	"XOR": func(_, _ byte, all []byte) byte {
+		var result byte
+		for _, b := range all {
+			result ^= b
+		}
+		return result
+	},
+	"NOT":   func(first, _ byte, _ []byte) byte { return ^first },
+	"DIFF":  func(first, others byte, _ []byte) byte { return first &^ others },
+	"DIFF1": func(first, others byte, _ []byte) byte { return others &^ first },
+	"ANDOR": func(first, others byte, _ []byte) byte { return first & others },
+	"ONE": func(_, _ byte, all []byte) byte {
+		var once, more byte
+		for _, b := range all {
+			more |= once & b
+			once ^= b
+		}
+		return once &^ more
+	},
+}
+
+func (ks *Keyspace) bitOp(c *Connection, args []string) {
+	name := strings.ToUpper(args[0])
+	operation, ok := bitOperations[name]
+	if !ok {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	keys := args[2:]
+	switch {
+	case name == "NOT" && len(keys) != 1:
+		c.WriteError(errors.New("ERR BITOP NOT must be called with a single source key."))
+		return
+	case (name == "DIFF" || name == "DIFF1" || name == "ANDOR") && len(keys) < 2:
+		c.WriteError(fmt.Errorf("ERR BITOP %s must be called with at least two source keys.", name))
+		return
+	}
+
+	values := make([][]byte, 0, len(keys))
+	length := 0
+	for _, key := range keys {
+		value, _, ok := lookupValue[string](ks, c, key)
+		if !ok {
+			return
+		}
+
+		values = append(values, []byte(value))
+		length = max(length, len(value))
+	}
+
+	result := make([]byte, length)
+	all := make([]byte, len(values))
+	for i := range result {
+		for j, value := range values {
+			all[j] = 0
+			if i < len(value) {
+				all[j] = value[i]
+			}
+		}
+
+		var others byte
+		for _, b := range all[1:] {
+			others |= b
+		}
+
+		result[i] = operation(all[0], others, all)
+	}
+
+	delete(ks.entries, args[1])
+	if length > 0 {
+		ks.store(args[1], string(result))
+	}
+
+	c.WriteInteger(length)
+}
+
+// bitfieldType is the type of an integer operated on by the BITFIELD
+// command.
+type bitfieldType struct {
+	signed bool
+	width  int
+}
+
+// parseBitfieldType parses a BITFIELD type, such as i8 or u16.
+func parseBitfieldType(s string) (bitfieldType, error) {
+	errInvalid := errors.New("ERR Invalid bitfield type. Use something like i16 u8. " +
+		"Note that u64 is not supported but i64 is.")
+
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u' && s[0] != 'I' && s[0] != 'U') {
+		return bitfieldType{}, errInvalid
+	}
+
+	typ := bitfieldType{signed: s[0] == 'i' || s[0] == 'I'}
+
+	var err error
+	if typ.width, err = strconv.Atoi(s[1:]); err != nil || typ.width < 1 ||
+		(typ.signed && typ.width > 64) || (!typ.signed && typ.width > 63) {
+		return bitfieldType{}, errInvalid
+	}
+
+	return typ, nil
+}
+
+// parseBitfieldOffset parses a BITFIELD offset, which is multiplied by
+// the width of the type if prefixed by #.
+func parseBitfieldOffset(s string, typ bitfieldType) (int, error) {
+	multiplier := 1
+	if strings.HasPrefix(s, "#") {
+		s, multiplier = s[1:], typ.width
+	}
+
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 || offset*multiplier+typ.width-1 > maxBitOffset {
+		return 0, errBitOffset
+	}
+
+	return offset * multiplier, nil
+}
+
+// bounds returns the smallest and largest integers of the type.
+func (typ bitfieldType) bounds() (lo, hi *big.Int) {
+	if typ.signed {
+		hi = new(big.Int).Lsh(big.NewInt(1), uint(typ.width-1))
+		lo = new(big.Int).Neg(hi)
+		hi.Sub(hi, big.NewInt(1))
+		return lo, hi
+	}
+
+	hi = new(big.Int).Lsh(big.NewInt(1), uint(typ.width))
+	return big.NewInt(0), hi.Sub(hi, big.NewInt(1))
+}
+
+// get returns the integer of the type stored at the provided bit offset.
+func (typ bitfieldType) get(value []byte, offset int) int64 {
+	var bits uint64
+	for i := range typ.width {
+		bits = bits<<1 | uint64(getBit(value, offset+i)) //nolint:gosec
+	}
+
+	if typ.signed && typ.width < 64 && bits>>(typ.width-1) == 1 {
+		// Sign extension.
+		bits |= ^uint64(0) << typ.width
+	}
+
+	return int64(bits) //nolint:gosec
+}
+
+// set stores the integer of the type at the provided bit offset, growing
+// the string as needed, and returns the resulting string.
+func (typ bitfieldType) set(value []byte, offset int, n int64) []byte {
+	bits := uint64(n) //nolint:gosec
+	for i := range typ.width {
+		value = setBit(value, offset+i, int(bits>>(typ.width-1-i))&1)
+	}
+
+	return value
+}
+
+// fit applies the provided overflow behavior to the integer, which returns
+// whether it fits the type, wrapping or saturating it if needed.
+func (typ bitfieldType) fit(n *big.Int, overflow string) (int64, bool) {
+	lo, hi := typ.bounds()
+	if n.Cmp(lo) >= 0 && n.Cmp(hi) <= 0 {
+		return n.Int64(), true
+	}
+
+	switch overflow {
+	case "SAT":
+		if n.Cmp(lo) < 0 {
+			return lo.Int64(), true
+		}
+		return hi.Int64(), true
+	case "WRAP":
+		size := new(big.Int).Lsh(big.NewInt(1), uint(typ.width))
+		wrapped := new(big.Int).Sub(n, lo)
+		wrapped.Mod(wrapped, size)
+		return wrapped.Add(wrapped, lo).Int64(), true
+	default:
+		return 0, false
+	}
+}
+
+// bitfield handles the GET, SET and INCRBY subcommands of the BITFIELD
+// command, and its WRAP, SAT and FAIL overflow behaviors.
+//
+//nolint:cyclop,funlen
+func (ks *Keyspace) bitfield(c *Connection, args []string) {
+	value, _, ok := lookupValue[string](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	buf, modified := []byte(value), false
+	overflow := "WRAP"
+
+	var reply []any
+	for i := 1; i < len(args); i++ {
+		subcommand := strings.ToUpper(args[i])
+
+		if subcommand == "OVERFLOW" {
+			if i+1 >= len(args) {
+				c.WriteError(errSyntax)
+				return
+			}
+			i++
+
+			overflow = strings.ToUpper(args[i])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				c.WriteError(errors.New("ERR Invalid OVERFLOW type specified"))
+				return
+			}
+
+			continue
+		}
+
+		width := 3
+		if subcommand == "GET" {
+			width = 2
+		} else if subcommand != "SET" && subcommand != "INCRBY" {
+			c.WriteError(errSyntax)
+			return
+		}
+		if i+width >= len(args) {
+			c.WriteError(errSyntax)
+			return
+		}
+
+		typ, err := parseBitfieldType(args[i+1])
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+
+		offset, err := parseBitfieldOffset(args[i+2], typ)
+		if err != nil {
+			c.WriteError(err)
+			return
+		}
+
+		var operand int64
+		if subcommand != "GET" {
+			if operand, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+				c.WriteError(errNotInteger)
+				return
+			}
+		}
+		i += width
+
+		current := typ.get(buf, offset)
+		switch subcommand {
+		case "GET":
+			reply = append(reply, current)
+		case "SET", "INCRBY":
+			next := big.NewInt(operand)
+			if subcommand == "INCRBY" {
+				next.Add(next, big.NewInt(current))
+			}
+
+			n, fits := typ.fit(next, overflow)
+			if !fits {
+				reply = append(reply, nil)
+				continue
+			}
+
+			buf, modified = typ.set(buf, offset, n), true
+			if subcommand == "SET" {
+				reply = append(reply, current)
+			} else {
+				reply = append(reply, n)
+			}
+		}
+	}
+
+	if modified {
+		ks.store(args[0], string(buf))
+	}
+
+	if reply == nil {
+		reply = []any{}
+	}
+
+	c.WriteValue(reply)
+}
+
+// hllPrefix prefixes the strings holding the HyperLogLogs emulated by the
+// Keyspace. Unlike Redis, which estimates their cardinality, they hold the
+// exact set of their elements, as a JSON array following the prefix.
+const hllPrefix = "HYLL"
+
+// errNotHLL is returned by the HyperLogLog commands for keys holding a
+// string which is not a HyperLogLog.
+var errNotHLL = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+
+// lookupHLL returns the elements of the HyperLogLog stored at the provided
+// key, and whether it exists. It writes an error to the connection if the
+// key holds another kind of value.
+func (ks *Keyspace) lookupHLL(c *Connection, key string) (setValue, bool, bool) {
+	value, exists, ok := lookupValue[string](ks, c, key)
+	if !ok || !exists {
+		return setValue{}, exists, ok
+	}
+
+	var elements []string
+	if !strings.HasPrefix(value, hllPrefix) || json.Unmarshal([]byte(value[len(hllPrefix):]), &elements) != nil {
+		c.WriteError(errNotHLL)
+		return nil, true, false
+	}
+
+	set := make(setValue, len(elements))
+	for _, element := range elements {
+		set[element] = struct{}{}
+	}
+
+	return set, true, true
+}
+
+// storeHLL stores the HyperLogLog holding the provided elements at the
+// provided key.
+func (ks *Keyspace) storeHLL(key string, set setValue) {
+	serialized, _ := json.Marshal(sortedKeys(set)) //nolint:errchkjson
+	ks.store(key, hllPrefix+string(serialized))
+}
+
+func (ks *Keyspace) pfadd(c *Connection, args []string) {
+	set, exists, ok := ks.lookupHLL(c, args[0])
+	if !ok {
+		return
+	}
+
+	changed := !exists
+	for _, element := range args[1:] {
+		if _, found := set[element]; !found {
+			set[element] = struct{}{}
+			changed = true
+		}
+	}
+
+	if !changed {
+		c.WriteInteger(0)
+		return
+	}
+
+	ks.storeHLL(args[0], set)
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) pfcount(c *Connection, args []string) {
+	union := setValue{}
+	for _, key := range args {
+		set, _, ok := ks.lookupHLL(c, key)
+		if !ok {
+			return
+		}
+
+		unionSets(union, set)
+	}
+
+	c.WriteInteger(len(union))
+}
+
+func (ks *Keyspace) pfmerge(c *Connection, args []string) {
+	union := setValue{}
+	for _, key := range args {
+		set, _, ok := ks.lookupHLL(c, key)
+		if !ok {
+			return
+		}
+
+		unionSets(union, set)
+	}
+
+	ks.storeHLL(args[0], union)
+	c.WriteOK()
+}
+
+// Geospatial indexes are sorted sets, scored by the 52 bits geohash of the
+// position of their members, as in Redis.
+const (
+	geoStep        = 26
+	geoLatLimit    = 85.05112878
+	geoEarthRadius = 6372797.560856
+	geoAlphabet    = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoUnits holds the distance units of the geospatial commands, in meters.
+//
+//nolint:gochecknoglobals
+var geoUnits = map[string]float64{"m": 1, "km": 1000, "ft": 0.3048, "mi": 1609.34}
+
+var errGeoUnit = errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
+
+// geoEncode returns the geohash of the provided position, the latitude
+// ranging between -latLimit and latLimit.
+func geoEncode(lon, lat, latLimit float64) uint64 {
+	offset := func(v, lo, hi float64) uint64 {
+		return min(uint64((v-lo)/(hi-lo)*(1<<geoStep)), 1<<geoStep-1)
+	}
+	lonBits, latBits := offset(lon, -180, 180), offset(lat, -latLimit, latLimit)
+
+	var hash uint64
+	for i := range geoStep {
+		hash |= (latBits >> i & 1) << (2 * i)
+		hash |= (lonBits >> i & 1) << (2*i + 1)
+	}
+
+	return hash
+}
+
+// geoDecode returns the position at the center of the area described by
+// the provided geohash.
+func geoDecode(hash uint64) (lon, lat float64) {
+	var lonBits, latBits uint64
+	for i := range geoStep {
+		latBits |= (hash >> (2 * i) & 1) << i
+		lonBits |= (hash >> (2*i + 1) & 1) << i
+	}
+
+	center := func(bits uint64, lo, hi float64) float64 {
+		return lo + (float64(bits)+0.5)*(hi-lo)/(1<<geoStep)
+	}
+
+	return center(lonBits, -180, 180), center(latBits, -geoLatLimit, geoLatLimit)
+}
+
+// geohashString returns the standard 11 characters geohash of the provided
+// position, as replied by the GEOHASH command.
+func geohashString(lon, lat float64) string {
+	hash := geoEncode(lon, lat, 90)
+
+	// The 52 bits geohash only fills 10 characters, the 11th being 0.
+	buf := make([]byte, 11)
+	for i := range 10 {
+		buf[i] = geoAlphabet[hash>>(52-(i+1)*5)&0x1f]
+	}
+	buf[10] = geoAlphabet[0]
+
+	return string(buf)
+}
+
+// geoDistance returns the distance in meters between the provided
+// positions, using the haversine formula.
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := lat1*math.Pi/180, lat2*math.Pi/180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2 - lon1) * math.Pi / 180 / 2)
+
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// formatGeoDistance formats a distance as Redis does in its replies.
+func formatGeoDistance(d float64) string {
+	return strconv.FormatFloat(d, 'f', 4, 64)
+}
+
+// geoPosition returns the position of the provided member of a geospatial
+// index, and whether it exists.
+func geoPosition(zset zsetValue, member string) (lon, lat float64, exists bool) {
+	score, exists := zset[member]
+	if !exists {
+		return 0, 0, false
+	}
+
+	lon, lat = geoDecode(uint64(score))
+
+	return lon, lat, true
+}
+
+// geoadd handles the GEOADD command, and its NX, XX and CH options, by
+// adding the geohashes of the positions to the sorted set.
+func (ks *Keyspace) geoadd(c *Connection, args []string) {
+	zaddArgs := []string{args[0]}
+
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX", "XX", "CH":
+			zaddArgs = append(zaddArgs, args[i])
+		default:
+			break options
+		}
+	}
+
+	locations := args[i:]
+	if len(locations) == 0 || len(locations)%3 != 0 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	for j := 0; j < len(locations); j += 3 {
+		lon, err1 := parseFloat(locations[j])
+		lat, err2 := parseFloat(locations[j+1])
+		if err1 != nil || err2 != nil {
+			c.WriteError(errNotFloat)
+			return
+		}
+
+		if lon < -180 || lon > 180 || lat < -geoLatLimit || lat > geoLatLimit {
+			c.WriteError(fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+			return
+		}
+
+		zaddArgs = append(zaddArgs, strconv.FormatUint(geoEncode(lon, lat, geoLatLimit), 10), locations[j+2])
+	}
+
+	ks.zadd(c, zaddArgs)
+}
+
+func (ks *Keyspace) geopos(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	positions := make([]any, 0, len(args)-1)
+	for _, member := range args[1:] {
+		lon, lat, exists := geoPosition(zset, member)
+		if !exists {
+			positions = append(positions, nil)
+			continue
+		}
+
+		positions = append(positions, []string{formatFloat(lon), formatFloat(lat)})
+	}
+
+	c.WriteValue(positions)
+}
+
+func (ks *Keyspace) geodist(c *Connection, args []string) {
+	unit := 1.0
+	switch len(args) {
+	case 3:
+	case 4:
+		var known bool
+		if unit, known = geoUnits[strings.ToLower(args[3])]; !known {
+			c.WriteError(errGeoUnit)
+			return
+		}
+	default:
+		c.WriteError(errSyntax)
+		return
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	lon1, lat1, exists1 := geoPosition(zset, args[1])
+	lon2, lat2, exists2 := geoPosition(zset, args[2])
+	if !exists1 || !exists2 {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(formatGeoDistance(geoDistance(lon1, lat1, lon2, lat2) / unit))
+}
+
+func (ks *Keyspace) geohash(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	hashes := make([]*string, 0, len(args)-1)
+	for _, member := range args[1:] {
+		lon, lat, exists := geoPosition(zset, member)
+		if !exists {
+			hashes = append(hashes, nil)
+			continue
+		}
+
+		hash := geohashString(lon, lat)
+		hashes = append(hashes, &hash)
+	}
+
+	writeNullableStrings(c, hashes)
+}
+
+// geoSearch is a GEOSEARCH or GEOSEARCHSTORE query. Its dimensions are in
+// meters.
+type geoSearch struct {
+	member   *string
+	lon, lat float64
+
+	byRadius, byBox       bool
+	radius, width, height float64
+	unit                  float64
+
+	sort  string
+	count int
+	any   bool
+
+	withDist, withHash, withCoord, storeDist bool
+}
+
+// parseGeoSearch parses the arguments of the named GEOSEARCH or
+// GEOSEARCHSTORE command following its keys.
+//
+//nolint:cyclop,funlen,gocognit
+func parseGeoSearch(c *Connection, name string, args []string) (q geoSearch, ok bool) {
+	store := name == "geosearchstore"
+
+	// parseFloats parses the n arguments of the option at index i.
+	parseFloats := func(i, n int) ([]float64, bool) {
+		if i+n >= len(args) {
+			c.WriteError(errSyntax)
+			return nil, false
+		}
+
+		floats := make([]float64, n)
+		for j := range floats {
+			f, err := parseFloat(args[i+1+j])
+			if err != nil {
+				c.WriteError(errNotFloat)
+				return nil, false
+			}
+			floats[j] = f
+		}
+
+		return floats, true
+	}
+
+	// parseUnit parses the unit argument of the option at index i.
+	parseUnit := func(i int) bool {
+		if i >= len(args) {
+			c.WriteError(errSyntax)
+			return false
+		}
+
+		unit, known := geoUnits[strings.ToLower(args[i])]
+		if !known {
+			c.WriteError(errGeoUnit)
+			return false
+		}
+		q.unit = unit
+
+		return true
+	}
+
+	// Options can be repeated, the last occurrence prevailing, as they are
+	// by the go-redis GeoSearchLocation helper.
+	fromMember, fromLonLat, withCount := false, false, false
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "FROMMEMBER" && i+1 < len(args):
+			q.member = &args[i+1]
+			fromMember = true
+			i++
+		case option == "FROMLONLAT":
+			floats, ok := parseFloats(i, 2)
+			if !ok {
+				return q, false
+			}
+			q.lon, q.lat = floats[0], floats[1]
+			fromLonLat = true
+			i += 2
+		case option == "BYRADIUS":
+			floats, ok := parseFloats(i, 1)
+			if !ok || !parseUnit(i+2) {
+				return q, false
+			}
+			if floats[0] < 0 {
+				c.WriteError(errors.New("ERR radius cannot be negative"))
+				return q, false
+			}
+			q.byRadius, q.radius = true, floats[0]*q.unit
+			i += 2
+		case option == "BYBOX":
+			floats, ok := parseFloats(i, 2)
+			if !ok || !parseUnit(i+3) {
+				return q, false
+			}
+			if floats[0] < 0 || floats[1] < 0 {
+				c.WriteError(errors.New("ERR height or width cannot be negative"))
+				return q, false
+			}
+			q.byBox, q.width, q.height = true, floats[0]*q.unit, floats[1]*q.unit
+			i += 3
+		case option == "ASC" || option == "DESC":
+			q.sort = option
+		case option == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				c.WriteError(errNotInteger)
+				return q, false
+			}
+			if count <= 0 {
+				c.WriteError(errors.New("ERR COUNT must be > 0"))
+				return q, false
+			}
+			q.count, withCount = count, true
+			i++
+			if i+1 < len(args) && strings.EqualFold(args[i+1], "ANY") {
+				q.any = true
+				i++
+			}
+		case option == "ANY":
+			q.any = true
+		case option == "WITHDIST" && !store:
+			q.withDist = true
+		case option == "WITHHASH" && !store:
+			q.withHash = true
+		case option == "WITHCOORD" && !store:
+			q.withCoord = true
+		case option == "STOREDIST" && store:
+			q.storeDist = true
+		default:
+			c.WriteError(errSyntax)
+			return q, false
+		}
+	}
+
+	switch {
+	case fromMember && fromLonLat:
+		c.WriteError(errors.New("ERR FROMMEMBER and FROMLONLAT options at the same time are not compatible"))
+		return q, false
+	case q.byRadius && q.byBox:
+		c.WriteError(errors.New("ERR BYRADIUS and BYBOX options at the same time are not compatible"))
+		return q, false
+	case !fromMember && !fromLonLat:
+		c.WriteError(fmt.Errorf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", name))
+		return q, false
+	case !q.byRadius && !q.byBox:
+		c.WriteError(fmt.Errorf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", name))
+		return q, false
+	case q.any && !withCount:
+		c.WriteError(errors.New("ERR the ANY argument requires COUNT argument"))
+		return q, false
+	}
+
+	// The closest members are returned when a count is provided.
+	if q.count > 0 && q.sort == "" && !q.any {
+		q.sort = "ASC"
+	}
+
+	return q, true
+}
+
+// geoMatch is a member of a geospatial index matching a geoSearch, along
+// with its distance, in meters, to the center of the searched area.
+type geoMatch struct {
+	member   string
+	distance float64
+}
+
+// run returns the members of the geospatial index within the searched
+// area, in the order of their geohash unless sorted. It writes an error to
+// the connection if the member to search from does not exist.
+func (q geoSearch) run(c *Connection, zset zsetValue) ([]geoMatch, bool) {
+	lon, lat := q.lon, q.lat
+	if q.member != nil {
+		var exists bool
+		if lon, lat, exists = geoPosition(zset, *q.member); !exists {
+			c.WriteError(errors.New("ERR could not decode requested zset member"))
+			return nil, false
+		}
+	}
+
+	var matches []geoMatch
+	for _, member := range zset.sorted() {
+		mlon, mlat, _ := geoPosition(zset, member)
+
+		distance := geoDistance(lon, lat, mlon, mlat)
+		switch {
+		case q.byRadius && distance > q.radius:
+			continue
+		case q.byBox && geoEarthRadius*math.Abs(mlat-lat)*math.Pi/180 > q.height/2:
+			continue
+		case q.byBox && geoDistance(mlon, mlat, lon, mlat) > q.width/2:
+			continue
+		}
+
+		matches = append(matches, geoMatch{member: member, distance: distance})
+		if q.any && len(matches) == q.count {
+			break
+		}
+	}
+
+	if q.sort != "" {
+		slices.SortStableFunc(matches, func(a, b geoMatch) int {
+			if q.sort == "DESC" {
+				a, b = b, a
+			}
+			return cmp.Compare(a.distance, b.distance)
+		})
+	}
+
+	if q.count > 0 && len(matches) > q.count {
+		matches = matches[:q.count]
+	}
+
+	return matches, true
+}
+
+// geosearch handles the GEOSEARCH command, and its WITHDIST, WITHHASH and
+// WITHCOORD options.
+func (ks *Keyspace) geosearch(c *Connection, args []string) {
+	q, ok := parseGeoSearch(c, "geosearch", args[1:])
+	if !ok {
+		return
+	}
+
+	zset, exists, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		writeStrings(c, nil)
+		return
+	}
+
+	matches, ok := q.run(c, zset)
+	if !ok {
+		return
+	}
+
+	if !q.withDist && !q.withHash && !q.withCoord {
+		members := make([]string, 0, len(matches))
+		for _, match := range matches {
+			members = append(members, match.member)
+		}
+
+		writeStrings(c, members)
+		return
+	}
+
+	reply := make([]any, 0, len(matches))
+	for _, match := range matches {
+		item := []any{match.member}
+		if q.withDist {
+			item = append(item, formatGeoDistance(match.distance/q.unit))
+		}
+		if q.withHash {
+			item = append(item, int64(zset[match.member]))
+		}
+		if q.withCoord {
+			lon, lat, _ := geoPosition(zset, match.member)
+			item = append(item, []string{formatFloat(lon), formatFloat(lat)})
+		}
+
+		reply = append(reply, item)
+	}
+
+	c.WriteValue(reply)
+}
+
+// geosearchStore handles the GEOSEARCHSTORE command, replacing the
+// destination key with the members found, scored by their geohash, or by
+// their distance with the STOREDIST option.
+func (ks *Keyspace) geosearchStore(c *Connection, args []string) {
+	q, ok := parseGeoSearch(c, "geosearchstore", args[2:])
+	if !ok {
+		return
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[1])
+	if !ok {
+		return
+	}
+
+	var matches []geoMatch
+	if zset != nil {
+		if matches, ok = q.run(c, zset); !ok {
+			return
+		}
+	}
+
+	result := make(zsetValue, len(matches))
+	for _, match := range matches {
+		result[match.member] = zset[match.member]
+		if q.storeDist {
+			result[match.member] = match.distance / q.unit
+		}
+	}
+
+	delete(ks.entries, args[0])
+	ks.store(args[0], result)
+	c.WriteInteger(len(result))
+}
+
+func (ks *Keyspace) lpush(c *Connection, args []string) {
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	pushed := make(listValue, 0, len(args)-1+len(list))
+	for i := len(args) - 1; i >= 1; i-- {
+		pushed = append(pushed, args[i])
+	}
+	list = append(pushed, list...)
+
+	ks.store(args[0], list)
+	c.WriteInteger(len(list))
+}
+
+func (ks *Keyspace) rpush(c *Connection, args []string) {
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	list = append(slices.Clone(list), args[1:]...)
+
+	ks.store(args[0], list)
+	c.WriteInteger(len(list))
+}
+
+func (ks *Keyspace) lpop(c *Connection, args []string) {
+	ks.pop(c, args, func(list listValue, n int) (listValue, listValue) {
+		return list[:n], list[n:]
+	})
+}
+
+func (ks *Keyspace) rpop(c *Connection, args []string) {
+	ks.pop(c, args, func(list listValue, n int) (listValue, listValue) {
+		popped := slices.Clone(list[len(list)-n:])
+		slices.Reverse(popped)
+		return popped, list[:len(list)-n]
+	})
+}
+
+// pop handles the LPOP and RPOP commands, using the provided function to
+// split the list between its `n` popped elements and the remaining ones.
+func (ks *Keyspace) pop(c *Connection, args []string, split func(listValue, int) (listValue, listValue)) {
+	if len(args) > 2 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			c.WriteError(errors.New("ERR value is out of range, must be positive"))
+			return
+		}
+		count = n
+	}
+
+	list, exists, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	popped, remaining := split(list, min(count, len(list)))
+	ks.store(args[0], remaining)
+
+	if len(args) == 2 {
+		writeStrings(c, popped)
+		return
+	}
+
+	c.WriteBulkString(popped[0])
+}
+
+// blockPollInterval is the interval at which blocking commands check
+// whether they can be served.
+const blockPollInterval = 5 * time.Millisecond
+
+// block calls serve until it returns true, meaning that it replied to the
+// command, or until the provided timeout, in seconds, expires, in which case
+// a null reply is written. A timeout of zero blocks indefinitely.
+//
+// It is called with the Keyspace locked, and unlocks it while waiting, for
+// other connections to be served in the meantime.
+func (ks *Keyspace) block(c *Connection, timeout string, serve func() bool) {
+	seconds, err := strconv.ParseFloat(timeout, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		c.WriteError(errors.New("ERR timeout is not a float or out of range"))
+		return
+	}
+	if seconds < 0 {
+		c.WriteError(errors.New("ERR timeout is negative"))
+		return
+	}
+
+	var expired <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(blockPollInterval)
+	defer ticker.Stop()
+
+	for !serve() {
+		ks.mu.Unlock()
+		select {
+		case <-ticker.C:
+			ks.mu.Lock()
+		case <-expired:
+			ks.mu.Lock()
+			c.WriteNull()
+			return
+		case <-c.closed:
+			ks.mu.Lock()
+			return
+		}
+	}
+}
+
+func (ks *Keyspace) blpop(c *Connection, args []string) {
+	ks.blockingPop(c, args, func(list listValue) (string, listValue) {
+		return list[0], list[1:]
+	})
+}
+
+func (ks *Keyspace) brpop(c *Connection, args []string) {
+	ks.blockingPop(c, args, func(list listValue) (string, listValue) {
+		return list[len(list)-1], list[:len(list)-1]
+	})
+}
+
+// blockingPop handles the BLPOP and BRPOP commands, using the provided
+// function to split a list between its popped element and the remaining
+// ones. The element is popped from the first non-empty list.
+func (ks *Keyspace) blockingPop(c *Connection, args []string, split func(listValue) (string, listValue)) {
+	keys, timeout := args[:len(args)-1], args[len(args)-1]
+
+	ks.block(c, timeout, func() bool {
+		for _, key := range keys {
+			list, exists, ok := lookupValue[listValue](ks, c, key)
+			if !ok {
+				return true
+			}
+			if !exists {
+				continue
+			}
+
+			element, remaining := split(list)
+			ks.store(key, remaining)
+			writeStrings(c, []string{key, element})
+
+			return true
+		}
+
+		return false
+	})
+}
+
+func (ks *Keyspace) blmove(c *Connection, args []string) {
+	from, to := strings.ToUpper(args[2]), strings.ToUpper(args[3])
+	if (from != "LEFT" && from != "RIGHT") || (to != "LEFT" && to != "RIGHT") {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	ks.block(c, args[4], func() bool {
+		return ks.move(c, args[0], args[1], from, to)
+	})
+}
+
+func (ks *Keyspace) brpoplpush(c *Connection, args []string) {
+	ks.block(c, args[2], func() bool {
+		return ks.move(c, args[0], args[1], "RIGHT", "LEFT")
+	})
+}
+
+// move moves an element of the source list, from its LEFT or RIGHT side,
+// to the provided side of the destination list, and replies with it. It
+// returns false, without replying, if the source list does not exist.
+func (ks *Keyspace) move(c *Connection, source, destination, from, to string) bool {
+	list, exists, ok := lookupValue[listValue](ks, c, source)
+	if !ok {
+		return true
+	}
+	if !exists {
+		return false
+	}
+
+	target, _, ok := lookupValue[listValue](ks, c, destination)
+	if !ok {
+		return true
+	}
+
+	var element string
+	if from == "LEFT" {
+		element, list = list[0], list[1:]
+	} else {
+		element, list = list[len(list)-1], list[:len(list)-1]
+	}
+	ks.store(source, list)
+
+	// The source and destination can be the same list.
+	if source == destination {
+		target = list
+	}
+	if to == "LEFT" {
+		target = append(listValue{element}, target...)
+	} else {
+		target = append(slices.Clone(target), element)
+	}
+	ks.store(destination, target)
+
+	c.WriteBulkString(element)
+
+	return true
+}
+
+func (ks *Keyspace) lrange(c *Connection, args []string) {
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	from, to, nonEmpty := rangeBounds(start, stop, len(list))
+	if !nonEmpty {
+		writeStrings(c, nil)
+		return
+	}
+
+	writeStrings(c, list[from:to+1])
+}
+
+func (ks *Keyspace) lindex(c *Connection, args []string) {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if index < 0 {
+		index += len(list)
+	}
+
+	if index < 0 || index >= len(list) {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(list[index])
+}
+
+func (ks *Keyspace) lset(c *Connection, args []string) {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	list, exists, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteError(errNoSuchKey)
+		return
+	}
+
+	if index < 0 {
+		index += len(list)
+	}
+
+	if index < 0 || index >= len(list) {
+		c.WriteError(errOutOfRange)
+		return
+	}
+
+	list[index] = args[2]
+	c.WriteOK()
+}
+
+func (ks *Keyspace) lrem(c *Connection, args []string) {
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	// Removing from the tail is removing from the head of the reversed list.
+	fromTail := count < 0
+	elements := slices.Clone(list)
+	if fromTail {
+		slices.Reverse(elements)
+		count = -count
+	}
+
+	removed := 0
+	remaining := make(listValue, 0, len(elements))
+	for _, element := range elements {
+		if element == args[2] && (count == 0 || removed < count) {
+			removed++
+			continue
+		}
+		remaining = append(remaining, element)
+	}
+
+	if fromTail {
+		slices.Reverse(remaining)
+	}
+
+	ks.store(args[0], remaining)
+	c.WriteInteger(removed)
+}
+
+func (ks *Keyspace) llen(c *Connection, args []string) {
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(list))
+}
+
+func (ks *Keyspace) lpushX(c *Connection, args []string) {
+	ks.pushExisting(c, args, (*Keyspace).lpush)
+}
+
+func (ks *Keyspace) rpushX(c *Connection, args []string) {
+	ks.pushExisting(c, args, (*Keyspace).rpush)
+}
+
+// pushExisting handles the LPUSHX and RPUSHX commands, pushing the elements
+// with the provided handler only if the list exists.
+func (ks *Keyspace) pushExisting(c *Connection, args []string, push func(*Keyspace, *Connection, []string)) {
+	_, exists, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteInteger(0)
+		return
+	}
+
+	push(ks, c, args)
+}
+
+func (ks *Keyspace) linsert(c *Connection, args []string) {
+	where := strings.ToUpper(args[1])
+	if where != "BEFORE" && where != "AFTER" {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	list, exists, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists {
+		c.WriteInteger(0)
+		return
+	}
+
+	index := slices.Index(list, args[2])
+	if index < 0 {
+		c.WriteInteger(-1)
+		return
+	}
+
+	if where == "AFTER" {
+		index++
+	}
+
+	list = slices.Insert(slices.Clone(list), index, args[3])
+	ks.store(args[0], list)
+	c.WriteInteger(len(list))
+}
+
+func (ks *Keyspace) ltrim(c *Connection, args []string) {
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	from, to, nonEmpty := rangeBounds(start, stop, len(list))
+	if !nonEmpty {
+		ks.store(args[0], listValue{})
+		c.WriteOK()
+		return
+	}
+
+	ks.store(args[0], slices.Clone(list[from:to+1]))
+	c.WriteOK()
+}
+
+//nolint:cyclop
+func (ks *Keyspace) lpos(c *Connection, args []string) {
+	rank, count, maxLen, withCount := 1, 1, 0, false
+
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.WriteError(errSyntax)
+			return
+		}
+
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				c.WriteError(errors.New("ERR RANK can't be zero: use 1 to start from the first match, " +
+					"2 from the second ... or use negative to start from the end of the list"))
+				return
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				c.WriteError(errors.New("ERR COUNT can't be negative"))
+				return
+			}
+			count, withCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				c.WriteError(errors.New("ERR MAXLEN can't be negative"))
+				return
+			}
+			maxLen = n
+		default:
+			c.WriteError(errSyntax)
+			return
+		}
+	}
+
+	list, _, ok := lookupValue[listValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	// A negative rank looks for matches from the tail of the list.
+	indexes := make([]any, 0)
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	for compared := 0; compared < len(list) && (maxLen == 0 || compared < maxLen); compared++ {
+		index := compared
+		if rank < 0 {
+			index = len(list) - 1 - compared
+		}
+
+		if list[index] != args[1] {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		indexes = append(indexes, index)
+		if count > 0 && len(indexes) == count {
+			break
+		}
+	}
+
+	switch {
+	case withCount:
+		c.WriteValue(indexes)
+	case len(indexes) == 0:
+		c.WriteNull()
+	default:
+		c.WriteInteger(indexes[0].(int)) //nolint:forcetypeassert
+	}
+}
+
+func (ks *Keyspace) lmove(c *Connection, args []string) {
+	from, to := strings.ToUpper(args[2]), strings.ToUpper(args[3])
+	if (from != "LEFT" && from != "RIGHT") || (to != "LEFT" && to != "RIGHT") {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	if !ks.move(c, args[0], args[1], from, to) {
+		c.WriteNull()
+	}
+}
+
+//nolint:cyclop
+func (ks *Keyspace) lmpop(c *Connection, args []string) {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		c.WriteError(errors.New("ERR numkeys should be greater than 0"))
+		return
+	}
+	if len(args) < numKeys+2 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	keys, where, rest := args[1:numKeys+1], strings.ToUpper(args[numKeys+1]), args[numKeys+2:]
+	if where != "LEFT" && where != "RIGHT" {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	count := 1
+	switch {
+	case len(rest) == 2 && strings.EqualFold(rest[0], "COUNT"):
+		n, err := strconv.Atoi(rest[1])
+		if err != nil || n <= 0 {
+			c.WriteError(errors.New("ERR count should be greater than 0"))
+			return
+		}
+		count = n
+	case len(rest) != 0:
+		c.WriteError(errSyntax)
+		return
+	}
+
+	for _, key := range keys {
+		list, exists, ok := lookupValue[listValue](ks, c, key)
+		if !ok {
+			return
+		}
+		if !exists {
+			continue
+		}
+
+		n := min(count, len(list))
+		var popped listValue
+		if where == "LEFT" {
+			popped, list = slices.Clone(list[:n]), list[n:]
+		} else {
+			popped = slices.Clone(list[len(list)-n:])
+			slices.Reverse(popped)
+			list = list[:len(list)-n]
+		}
+		ks.store(key, list)
+
+		c.WriteValue([]any{key, []string(popped)})
+		return
+	}
+
+	c.WriteNull()
+}
+
+func (ks *Keyspace) hset(c *Connection, args []string) {
+	if len(args)%2 != 1 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'hset' command"))
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if hash == nil {
+		hash = hashValue{}
+	}
+
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if _, exists := hash[args[i]]; !exists {
+			added++
+		}
+		hash[args[i]] = args[i+1]
+	}
+
+	ks.store(args[0], hash)
+	ks.persistFields(args[0], args[1:], 2)
+	c.WriteInteger(added)
+}
+
+func (ks *Keyspace) hsetNX(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if _, exists := hash[args[1]]; exists {
+		c.WriteInteger(0)
+		return
+	}
+
+	if hash == nil {
+		hash = hashValue{}
+	}
+	hash[args[1]] = args[2]
+
+	ks.store(args[0], hash)
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) hget(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	value, exists := hash[args[1]]
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(value)
+}
+
+func (ks *Keyspace) hmget(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	values := make([]*string, 0, len(args)-1)
+	for _, field := range args[1:] {
+		if value, exists := hash[field]; exists {
+			values = append(values, &value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	writeNullableStrings(c, values)
+}
+
+func (ks *Keyspace) hdel(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	removed := 0
+	for _, field := range args[1:] {
+		if _, exists := hash[field]; exists {
+			delete(hash, field)
+			removed++
+		}
+	}
+
+	if hash != nil {
+		ks.persistFields(args[0], args[1:], 1)
+		ks.store(args[0], hash)
+	}
+	c.WriteInteger(removed)
+}
+
+func (ks *Keyspace) hexists(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if _, exists := hash[args[1]]; exists {
+		c.WriteInteger(1)
+		return
+	}
+
+	c.WriteInteger(0)
+}
+
+func (ks *Keyspace) hgetall(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	fields := sortedKeys(hash)
+	values := make([]string, 0, 2*len(fields))
+	for _, field := range fields {
+		values = append(values, field, hash[field])
+	}
+
+	writeStrings(c, values)
+}
+
+func (ks *Keyspace) hkeys(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	writeStrings(c, sortedKeys(hash))
+}
+
+func (ks *Keyspace) hvals(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	fields := sortedKeys(hash)
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		values = append(values, hash[field])
+	}
+
+	writeStrings(c, values)
+}
+
+func (ks *Keyspace) hlen(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(hash))
+}
+
+func (ks *Keyspace) hincrBy(c *Connection, args []string) {
+	increment, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	var current int64
+	if value, exists := hash[args[1]]; exists {
+		if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.WriteError(errors.New("ERR hash value is not an integer"))
+			return
+		}
+	}
+
+	if (increment > 0 && current > math.MaxInt64-increment) || (increment < 0 && current < math.MinInt64-increment) {
+		c.WriteError(errOverflow)
+		return
+	}
+
+	if hash == nil {
+		hash = hashValue{}
+	}
+	current += increment
+	hash[args[1]] = strconv.FormatInt(current, 10)
+
+	ks.store(args[0], hash)
+	c.WriteInteger(int(current))
+}
+
+func (ks *Keyspace) hscan(c *Connection, args []string) {
+	opts, ok := parseScanOptions(c, args[1:], "NOVALUES")
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	fields, next := opts.page(sortedKeys(hash))
+	if opts.noValues {
+		writeScanReply(c, next, fields)
+		return
+	}
+
+	reply := make([]string, 0, 2*len(fields))
+	for _, field := range fields {
+		reply = append(reply, field, hash[field])
+	}
+
+	writeScanReply(c, next, reply)
+}
+
+func (ks *Keyspace) hincrByFloat(c *Connection, args []string) {
+	increment, err := parseFloat(args[2])
+	if err != nil || math.IsInf(increment, 0) {
+		c.WriteError(errNotFloat)
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	var current float64
+	if value, exists := hash[args[1]]; exists {
+		if current, err = parseFloat(value); err != nil || math.IsInf(current, 0) {
+			c.WriteError(errors.New("ERR hash value is not a float"))
+			return
+		}
+	}
+
+	current += increment
+	if math.IsInf(current, 0) || math.IsNaN(current) {
+		c.WriteError(errors.New("ERR increment would produce NaN or Infinity"))
+		return
+	}
+
+	if hash == nil {
+		hash = hashValue{}
+	}
+	hash[args[1]] = formatFloat(current)
+
+	ks.store(args[0], hash)
+	c.WriteBulkString(hash[args[1]])
+}
+
+func (ks *Keyspace) hstrlen(c *Connection, args []string) {
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(hash[args[1]]))
+}
+
+//nolint:cyclop
+func (ks *Keyspace) hrandField(c *Connection, args []string) {
+	if len(args) > 3 || (len(args) == 3 && !strings.EqualFold(args[2], "WITHVALUES")) {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	count := 1
+	if len(args) >= 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			c.WriteError(errNotInteger)
+			return
+		}
+		count = n
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if len(hash) == 0 {
+		if len(args) == 1 {
+			c.WriteNull()
+		} else {
+			writeStrings(c, nil)
+		}
+		return
+	}
+
+	fields := sortedKeys(hash)
+	rand.Shuffle(len(fields), func(i, j int) { fields[i], fields[j] = fields[j], fields[i] })
+
+	var picked []string
+	if count < 0 {
+		// A negative count allows the same field to be returned multiple times.
+		for range -count {
+			picked = append(picked, fields[rand.IntN(len(fields))]) //nolint:gosec
+		}
+	} else {
+		picked = fields[:min(count, len(fields))]
+	}
+
+	switch len(args) {
+	case 1:
+		c.WriteBulkString(picked[0])
+	case 2:
+		writeStrings(c, picked)
+	default:
+		reply := make([]string, 0, 2*len(picked))
+		for _, field := range picked {
+			reply = append(reply, field, hash[field])
+		}
+		writeStrings(c, reply)
+	}
+}
+
+// parseHashFields parses the `FIELDS numfields field...` arguments of the
+// hash fields expiration commands, each field being followed by `width-1`
+// values. It writes an error to the connection if they are invalid.
+func parseHashFields(c *Connection, args []string, width int) ([]string, bool) {
+	if len(args) < 2 || !strings.EqualFold(args[0], "FIELDS") {
+		c.WriteError(errors.New("ERR Mandatory argument FIELDS is missing or not at the right position"))
+		return nil, false
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		c.WriteError(errors.New("ERR Parameter `numFields` should be greater than 0"))
+		return nil, false
+	}
+
+	if len(args)-2 != n*width {
+		c.WriteError(errors.New("ERR The `numfields` parameter must match the number of arguments"))
+		return nil, false
+	}
+
+	return args[2:], true
+}
+
+// fieldExpiration holds the expiration options of the HGETEX, HSETEX and
+// GETEX commands.
+type fieldExpiration struct {
+	// expiresAt is the expiration time to set, if any.
+	expiresAt time.Time
+
+	// persist and keepTTL are set by the PERSIST and KEEPTTL options.
+	persist, keepTTL bool
+
+	// condition is the FNX or FXX condition of the HSETEX command.
+	condition string
+}
+
+// parseFieldExpiration parses the options of the HGETEX and HSETEX
+// commands preceding their FIELDS argument, or those of the GETEX command,
+// among the provided flags and the EX, PX, EXAT and PXAT options. It
+// returns the arguments following the options, and writes an error to the
+// connection if they are invalid.
+//
+//nolint:cyclop
+func (ks *Keyspace) parseFieldExpiration(
+	c *Connection, args []string, flags ...string,
+) (opts fieldExpiration, rest []string, ok bool) {
+	set := false
+	for len(args) > 0 && !strings.EqualFold(args[0], "FIELDS") {
+		option := strings.ToUpper(args[0])
+
+		switch {
+		case option == "EX" || option == "PX" || option == "EXAT" || option == "PXAT":
+			if len(args) < 2 || set {
+				c.WriteError(errSyntax)
+				return opts, nil, false
+			}
+
+			amount, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || amount <= 0 {
+				c.WriteError(errors.New("ERR invalid expire time, must be >= 0 and <= 2^48"))
+				return opts, nil, false
+			}
+
+			switch option {
+			case "EX":
+				opts.expiresAt = ks.Now().Add(time.Duration(amount) * time.Second)
+			case "PX":
+				opts.expiresAt = ks.Now().Add(time.Duration(amount) * time.Millisecond)
+			case "EXAT":
+				opts.expiresAt = time.Unix(amount, 0)
+			case "PXAT":
+				opts.expiresAt = time.UnixMilli(amount)
+			}
+			set = true
+			args = args[2:]
+
+			continue
+		case !slices.Contains(flags, option):
+			c.WriteError(errSyntax)
+			return opts, nil, false
+		case option == "FNX" || option == "FXX":
+			if opts.condition != "" {
+				c.WriteError(errSyntax)
+				return opts, nil, false
+			}
+			opts.condition = option
+		default:
+			if set {
+				c.WriteError(errSyntax)
+				return opts, nil, false
+			}
+			opts.persist, opts.keepTTL = option == "PERSIST", option == "KEEPTTL"
+			set = true
+		}
+
+		args = args[1:]
+	}
+
+	return opts, args, true
+}
+
+// expireFields sets the expiration time of the provided fields of the hash
+// stored at key, deleting them if it is in the past.
+func (ks *Keyspace) expireFields(key string, fields []string, expiresAt time.Time) {
+	entry := ks.lookup(key)
+	if entry == nil {
+		return
+	}
+
+	hash, _ := entry.value.(hashValue)
+	for _, field := range fields {
+		if _, exists := hash[field]; !exists {
+			continue
+		}
+
+		if !ks.Now().Before(expiresAt) {
+			delete(hash, field)
+			delete(entry.fieldsExpireAt, field)
+			continue
+		}
+
+		if entry.fieldsExpireAt == nil {
+			entry.fieldsExpireAt = make(map[string]time.Time)
+		}
+		entry.fieldsExpireAt[field] = expiresAt
+	}
+
+	ks.store(key, hash)
+}
+
+// persistFields removes the expiration time of every `step` field of the
+// hash stored at key, among the provided ones.
+func (ks *Keyspace) persistFields(key string, fields []string, step int) {
+	entry := ks.lookup(key)
+	if entry == nil {
+		return
+	}
+
+	for i := 0; i < len(fields); i += step {
+		delete(entry.fieldsExpireAt, fields[i])
+	}
+}
+
+func (ks *Keyspace) hexpire(c *Connection, args []string) {
+	ks.hexpireIn(c, args, time.Second)
+}
+
+func (ks *Keyspace) hpexpire(c *Connection, args []string) {
+	ks.hexpireIn(c, args, time.Millisecond)
+}
+
+// hexpireIn handles the HEXPIRE and HPEXPIRE commands, whose expiration
+// time is in the provided unit, and replies for each field with -2 if it
+// does not exist, 0 if the NX, XX, GT or LT condition is not met, 1 if its
+// expiration time was set, or 2 if it was deleted.
+//
+//nolint:cyclop
+func (ks *Keyspace) hexpireIn(c *Connection, args []string, unit time.Duration) {
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+	expiresAt := ks.Now().Add(time.Duration(amount) * unit)
+
+	rest := args[2:]
+	condition := ""
+	if option := strings.ToUpper(rest[0]); option == "NX" || option == "XX" || option == "GT" || option == "LT" {
+		condition, rest = option, rest[1:]
+	}
+
+	fields, ok := parseHashFields(c, rest, 1)
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	var entry *keyspaceEntry
+	if hash != nil {
+		entry = ks.lookup(args[0])
+	}
+
+	reply := make([]any, 0, len(fields))
+	for _, field := range fields {
+		if _, exists := hash[field]; !exists {
+			reply = append(reply, -2)
+			continue
+		}
+
+		// Fields without an expiration time are considered to live forever.
+		current, hasTTL := entry.fieldsExpireAt[field]
+		met := true
+		switch condition {
+		case "NX":
+			met = !hasTTL
+		case "XX":
+			met = hasTTL
+		case "GT":
+			met = hasTTL && expiresAt.After(current)
+		case "LT":
+			met = !hasTTL || expiresAt.Before(current)
+		}
+
+		switch {
+		case !met:
+			reply = append(reply, 0)
+		case !ks.Now().Before(expiresAt):
+			reply = append(reply, 2)
+		default:
+			reply = append(reply, 1)
+		}
+
+		if met {
+			ks.expireFields(args[0], []string{field}, expiresAt)
+		}
+	}
+
+	c.WriteValue(reply)
+}
+
+func (ks *Keyspace) httl(c *Connection, args []string) {
+	ks.fieldsTimeToLive(c, args, time.Second)
+}
+
+func (ks *Keyspace) hpttl(c *Connection, args []string) {
+	ks.fieldsTimeToLive(c, args, time.Millisecond)
+}
+
+// fieldsTimeToLive handles the HTTL and HPTTL commands, replying for each
+// field with its remaining time to live in the provided unit, -2 if it
+// does not exist, or -1 if it has no expiration.
+func (ks *Keyspace) fieldsTimeToLive(c *Connection, args []string, unit time.Duration) {
+	fields, ok := parseHashFields(c, args[1:], 1)
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	reply := make([]any, 0, len(fields))
+	for _, field := range fields {
+		if _, exists := hash[field]; !exists {
+			reply = append(reply, -2)
+			continue
+		}
+
+		expiresAt, hasTTL := ks.lookup(args[0]).fieldsExpireAt[field]
+		if !hasTTL {
+			reply = append(reply, -1)
+			continue
+		}
+
+		remaining := expiresAt.Sub(ks.Now())
+		reply = append(reply, int((remaining+unit/2)/unit))
+	}
+
+	c.WriteValue(reply)
+}
+
+func (ks *Keyspace) hpersist(c *Connection, args []string) {
+	fields, ok := parseHashFields(c, args[1:], 1)
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	reply := make([]any, 0, len(fields))
+	for _, field := range fields {
+		if _, exists := hash[field]; !exists {
+			reply = append(reply, -2)
+			continue
+		}
+
+		entry := ks.lookup(args[0])
+		if _, hasTTL := entry.fieldsExpireAt[field]; !hasTTL {
+			reply = append(reply, -1)
+			continue
+		}
+
+		delete(entry.fieldsExpireAt, field)
+		reply = append(reply, 1)
+	}
+
+	c.WriteValue(reply)
+}
+
+func (ks *Keyspace) hgetEx(c *Connection, args []string) {
+	opts, rest, ok := ks.parseFieldExpiration(c, args[1:], "PERSIST")
+	if !ok {
+		return
+	}
+
+	fields, ok := parseHashFields(c, rest, 1)
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	values := make([]*string, 0, len(fields))
+	for _, field := range fields {
+		if value, exists := hash[field]; exists {
+			values = append(values, &value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+
+	switch {
+	case hash == nil:
+	case opts.persist:
+		ks.persistFields(args[0], fields, 1)
+	case !opts.expiresAt.IsZero():
+		ks.expireFields(args[0], fields, opts.expiresAt)
+	}
+
+	writeNullableStrings(c, values)
+}
+
+func (ks *Keyspace) hsetEx(c *Connection, args []string) {
+	opts, rest, ok := ks.parseFieldExpiration(c, args[1:], "FNX", "FXX", "KEEPTTL")
+	if !ok {
+		return
+	}
+
+	fieldsAndValues, ok := parseHashFields(c, rest, 2)
+	if !ok {
+		return
+	}
+
+	hash, _, ok := lookupValue[hashValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	for i := 0; i < len(fieldsAndValues); i += 2 {
+		_, exists := hash[fieldsAndValues[i]]
+		if (opts.condition == "FNX" && exists) || (opts.condition == "FXX" && !exists) {
+			c.WriteInteger(0)
+			return
+		}
+	}
+
+	if hash == nil {
+		hash = hashValue{}
+	}
+
+	fields := make([]string, 0, len(fieldsAndValues)/2)
+	for i := 0; i < len(fieldsAndValues); i += 2 {
+		hash[fieldsAndValues[i]] = fieldsAndValues[i+1]
+		fields = append(fields, fieldsAndValues[i])
+	}
+	ks.store(args[0], hash)
+
+	switch {
+	case opts.keepTTL:
+	case !opts.expiresAt.IsZero():
+		ks.expireFields(args[0], fields, opts.expiresAt)
+	default:
+		ks.persistFields(args[0], fields, 1)
+	}
+
+	c.WriteInteger(1)
+}
+
+func (ks *Keyspace) sadd(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if set == nil {
+		set = setValue{}
+	}
+
+	added := 0
+	for _, member := range args[1:] {
+		if _, exists := set[member]; !exists {
+			set[member] = struct{}{}
+			added++
+		}
+	}
+
+	ks.store(args[0], set)
+	c.WriteInteger(added)
+}
+
+func (ks *Keyspace) srem(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if _, exists := set[member]; exists {
+			delete(set, member)
+			removed++
+		}
+	}
+
+	if set != nil {
+		ks.store(args[0], set)
+	}
+	c.WriteInteger(removed)
+}
+
+func (ks *Keyspace) sismember(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if _, exists := set[args[1]]; exists {
+		c.WriteInteger(1)
+		return
+	}
+
+	c.WriteInteger(0)
+}
+
+func (ks *Keyspace) smembers(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	writeStrings(c, sortedKeys(set))
+}
+
+func (ks *Keyspace) scard(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(set))
+}
+
+func (ks *Keyspace) smismember(c *Connection, args []string) {
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	reply := make([]any, 0, len(args)-1)
+	for _, member := range args[1:] {
+		if _, exists := set[member]; exists {
+			reply = append(reply, 1)
+		} else {
+			reply = append(reply, 0)
+		}
+	}
+
+	c.WriteValue(reply)
+}
+
+func (ks *Keyspace) smove(c *Connection, args []string) {
+	source, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	destination, _, ok := lookupValue[setValue](ks, c, args[1])
+	if !ok {
+		return
+	}
+
+	if _, exists := source[args[2]]; !exists {
+		c.WriteInteger(0)
+		return
+	}
+
+	delete(source, args[2])
+	ks.store(args[0], source)
+
+	if destination == nil {
+		destination = setValue{}
+	}
+	destination[args[2]] = struct{}{}
+	ks.store(args[1], destination)
+
+	c.WriteInteger(1)
+}
+
+// setOperation combines a set with another one, returning the result.
+type setOperation func(result, set setValue) setValue
+
+func intersectSets(result, set setValue) setValue {
+	for member := range result {
+		if _, exists := set[member]; !exists {
+			delete(result, member)
+		}
+	}
+
+	return result
+}
+
+func unionSets(result, set setValue) setValue {
+	for member := range set {
+		result[member] = struct{}{}
+	}
+
+	return result
+}
+
+func diffSets(result, set setValue) setValue {
+	for member := range set {
+		delete(result, member)
+	}
+
+	return result
+}
+
+// combineSets returns the result of applying the operation to the sets
+// stored at the provided keys, in order, keys which do not exist being
+// considered empty sets. It writes an error to the connection if one of
+// them does not hold a set.
+func (ks *Keyspace) combineSets(c *Connection, keys []string, operation setOperation) (setValue, bool) {
+	var result setValue
+	for i, key := range keys {
+		set, _, ok := lookupValue[setValue](ks, c, key)
+		if !ok {
+			return nil, false
+		}
+
+		if i == 0 {
+			result = make(setValue, len(set))
+			unionSets(result, set)
+			continue
+		}
+
+		result = operation(result, set)
+	}
+
+	return result, true
+}
+
+func (ks *Keyspace) sinter(c *Connection, args []string) {
+	ks.combine(c, args, intersectSets)
+}
+
+func (ks *Keyspace) sunion(c *Connection, args []string) {
+	ks.combine(c, args, unionSets)
+}
+
+func (ks *Keyspace) sdiff(c *Connection, args []string) {
+	ks.combine(c, args, diffSets)
+}
+
+// combine handles the SINTER, SUNION and SDIFF commands.
+func (ks *Keyspace) combine(c *Connection, keys []string, operation setOperation) {
+	result, ok := ks.combineSets(c, keys, operation)
+	if !ok {
+		return
+	}
+
+	writeStrings(c, sortedKeys(result))
+}
+
+func (ks *Keyspace) sinterStore(c *Connection, args []string) {
+	ks.combineStore(c, args, intersectSets)
+}
+
+func (ks *Keyspace) sunionStore(c *Connection, args []string) {
+	ks.combineStore(c, args, unionSets)
+}
+
+func (ks *Keyspace) sdiffStore(c *Connection, args []string) {
+	ks.combineStore(c, args, diffSets)
+}
+
+// combineStore handles the SINTERSTORE, SUNIONSTORE and SDIFFSTORE
+// commands, replacing the destination key with the resulting set.
+func (ks *Keyspace) combineStore(c *Connection, args []string, operation setOperation) {
+	result, ok := ks.combineSets(c, args[1:], operation)
+	if !ok {
+		return
+	}
+
+	delete(ks.entries, args[0])
+	ks.store(args[0], result)
+	c.WriteInteger(len(result))
+}
+
+func (ks *Keyspace) sinterCard(c *Connection, args []string) {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		c.WriteError(errors.New("ERR numkeys should be greater than 0"))
+		return
+	}
+	if len(args) < numKeys+1 {
+		c.WriteError(errors.New("ERR Number of keys can't be greater than number of args"))
+		return
+	}
+
+	keys, rest := args[1:numKeys+1], args[numKeys+1:]
+
+	limit := 0
+	switch {
+	case len(rest) == 2 && strings.EqualFold(rest[0], "LIMIT"):
+		n, err := strconv.Atoi(rest[1])
+		if err != nil || n < 0 {
+			c.WriteError(errors.New("ERR LIMIT can't be negative"))
+			return
+		}
+		limit = n
+	case len(rest) != 0:
+		c.WriteError(errSyntax)
+		return
+	}
+
+	result, ok := ks.combineSets(c, keys, intersectSets)
+	if !ok {
+		return
+	}
+
+	if limit > 0 {
+		c.WriteInteger(min(limit, len(result)))
+		return
+	}
+
+	c.WriteInteger(len(result))
+}
+
+func (ks *Keyspace) srandmember(c *Connection, args []string) {
+	ks.randomMembers(c, args, false)
+}
+
+func (ks *Keyspace) spop(c *Connection, args []string) {
+	ks.randomMembers(c, args, true)
+}
+
+func (ks *Keyspace) sscan(c *Connection, args []string) {
+	opts, ok := parseScanOptions(c, args[1:])
+	if !ok {
+		return
+	}
+
+	set, _, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	members, next := opts.page(sortedKeys(set))
+	writeScanReply(c, next, members)
+}
+
+// randomMembers handles the SRANDMEMBER and SPOP commands. When `remove` is
+// true, the returned members are removed from the set.
+func (ks *Keyspace) randomMembers(c *Connection, args []string, remove bool) {
+	if len(args) > 2 {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || (remove && n < 0) {
+			c.WriteError(errors.New("ERR value is out of range, must be positive"))
+			return
+		}
+		count = n
+	}
+
+	set, exists, ok := lookupValue[setValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if !exists && len(args) == 1 {
+		c.WriteNull()
+		return
+	}
+
+	members := sortedKeys(set)
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+
+	var picked []string
+	if count < 0 && len(members) > 0 {
+		// A negative count allows the same member to be returned multiple times.
+		for range -count {
+			picked = append(picked, members[rand.IntN(len(members))]) //nolint:gosec
+		}
+	} else if count >= 0 {
+		picked = members[:min(count, len(members))]
+	}
+
+	if remove {
+		for _, member := range picked {
+			delete(set, member)
+		}
+		if set != nil {
+			ks.store(args[0], set)
+		}
+	}
+
+	if len(args) == 2 {
+		writeStrings(c, picked)
+		return
+	}
+
+	c.WriteBulkString(picked[0])
+}
+
+// zadd handles the ZADD command, and its NX, XX, GT, LT and CH options.
+//
+//nolint:cyclop
+func (ks *Keyspace) zadd(c *Connection, args []string) {
+	var nx, xx, gt, lt, ch bool
+
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (gt && lt) || (nx && (gt || lt)) {
+		c.WriteError(errSyntax)
+		return
+	}
+
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseFloat(pairs[j])
+		if err != nil {
+			c.WriteError(errNotFloat)
+			return
+		}
+		scores = append(scores, score)
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if zset == nil {
+		zset = zsetValue{}
+	}
+
+	added, changed := 0, 0
+	for j, score := range scores {
+		member := pairs[2*j+1]
+
+		current, exists := zset[member]
+		switch {
+		case exists && nx, !exists && xx:
+			continue
+		case exists && ((gt && score <= current) || (lt && score >= current)):
+			continue
+		case !exists:
+			added++
+		case current != score:
+			changed++
+		}
+
+		zset[member] = score
+	}
+
+	ks.store(args[0], zset)
+
+	if ch {
+		c.WriteInteger(added + changed)
+		return
+	}
+
+	c.WriteInteger(added)
+}
+
+func (ks *Keyspace) zscore(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	score, exists := zset[args[1]]
+	if !exists {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(formatFloat(score))
+}
+
+func (ks *Keyspace) zincrBy(c *Connection, args []string) {
+	increment, err := parseFloat(args[1])
+	if err != nil {
+		c.WriteError(errNotFloat)
+		return
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if zset == nil {
+		zset = zsetValue{}
+	}
+	zset[args[2]] += increment
+
+	ks.store(args[0], zset)
+	c.WriteBulkString(formatFloat(zset[args[2]]))
+}
+
+func (ks *Keyspace) zrem(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if _, exists := zset[member]; exists {
+			delete(zset, member)
+			removed++
+		}
+	}
+
+	if zset != nil {
+		ks.store(args[0], zset)
+	}
+	c.WriteInteger(removed)
+}
+
+func (ks *Keyspace) zcard(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	c.WriteInteger(len(zset))
+}
+
+// zrange handles the index based form of the ZRANGE command, and its
+// WITHSCORES option.
+func (ks *Keyspace) zrange(c *Connection, args []string) {
+	withScores := false
+	switch {
+	case len(args) == 4 && strings.EqualFold(args[3], "WITHSCORES"):
+		withScores = true
+	case len(args) != 3:
+		c.WriteError(errSyntax)
+		return
+	}
+
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		c.WriteError(errNotInteger)
+		return
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	members := zset.sorted()
+	from, to, nonEmpty := rangeBounds(start, stop, len(members))
+	if !nonEmpty {
+		writeStrings(c, nil)
+		return
+	}
+
+	var values []string
+	for _, member := range members[from : to+1] {
+		values = append(values, member)
+		if withScores {
+			values = append(values, formatFloat(zset[member]))
+		}
+	}
+
+	writeStrings(c, values)
+}
+
+func (ks *Keyspace) zrank(c *Connection, args []string) {
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	if _, exists := zset[args[1]]; !exists {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteInteger(slices.Index(zset.sorted(), args[1]))
+}
+
+func (ks *Keyspace) zscan(c *Connection, args []string) {
+	opts, ok := parseScanOptions(c, args[1:])
+	if !ok {
+		return
+	}
+
+	zset, _, ok := lookupValue[zsetValue](ks, c, args[0])
+	if !ok {
+		return
+	}
+
+	members, next := opts.page(zset.sorted())
+
+	reply := make([]string, 0, 2*len(members))
+	for _, member := range members {
+		reply = append(reply, member, formatFloat(zset[member]))
+	}
+
+	writeScanReply(c, next, reply)
+}
+
+// sorted returns the members of the sorted set, ordered by score, then
+// lexicographically.
+func (z zsetValue) sorted() []string {
+	members := make([]string, 0, len(z))
+	for member := range z {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if z[members[i]] != z[members[j]] {
+			return z[members[i]] < z[members[j]]
+		}
+		return members[i] < members[j]
+	})
+
+	return members
+}
+
+// rangeBounds converts the possibly negative `start` and `stop` indexes of
+// a range over a collection of the provided length to positive inclusive
+// bounds. It returns false if the range is empty.
+func rangeBounds(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	start = max(start, 0)
+	stop = min(stop, length-1)
+
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+
+	return start, stop, true
+}
+
+// compilePattern compiles a Redis glob-style pattern, as used by the KEYS
+// command, to a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + strings.ReplaceAll(class[1:], `\`, `\\`)
+			} else {
+				class = strings.ReplaceAll(class, `\`, `\\`)
+			}
+			expr.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// parseFloat parses a float as Redis does, accepting the inf and -inf
+// special values.
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+
+	return f, nil
+}
+
+// formatFloat formats a float as Redis does in its replies.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// sortedKeys returns the keys of the provided map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// writeStrings writes the provided strings as an array of bulk strings.
+//
+// Unlike Connection.WriteArray, empty strings are written as such, rather
+// than as null elements.
+func writeStrings(c *Connection, strs []string) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.writeLen(len(strs))
+		for _, s := range strs {
+			w.WriteBulkString(s)
+		}
+	})
+}
+
+// writeNullableStrings writes the provided strings as an array of bulk
+// strings, nil elements being written as null elements.
+func writeNullableStrings(c *Connection, strs []*string) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.writeLen(len(strs))
+		for _, s := range strs {
+			if s == nil {
+				w.WriteNull()
+				continue
+			}
+			w.WriteBulkString(*s)
+		}
+	})
+}
+
+// writeNullable writes the provided string as a bulk string, or as null
+// if it is nil.
+func writeNullable(c *Connection, s *string) {
+	if s == nil {
+		c.WriteNull()
+		return
+	}
+
+	c.WriteBulkString(*s)
+}
@@ -0,0 +1,554 @@
+package redistest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyspace(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T) (*redis.Client, *Keyspace) {
+		t.Helper()
+
+		ks := NewKeyspace()
+		rs := RunT(t)
+		rs.UseKeyspace(ks)
+
+		client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client, ks
+	}
+
+	t.Run("strings", func(t *testing.T) {
+		t.Parallel()
+
+		client, ks := newClient(t)
+		ctx := context.Background()
+
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+		assert.Equal(t, "bar", client.Get(ctx, "foo").Val())
+		assert.False(t, client.SetNX(ctx, "foo", "baz", 0).Val())
+		assert.Equal(t, "bar", client.GetSet(ctx, "foo", "").Val())
+		assert.Equal(t, "", client.Get(ctx, "foo").Val())
+
+		assert.Equal(t, int64(3), client.Append(ctx, "foo", "baz").Val())
+		assert.Equal(t, int64(3), client.StrLen(ctx, "foo").Val())
+
+		assert.Equal(t, int64(1), client.Incr(ctx, "counter").Val())
+		assert.Equal(t, int64(11), client.IncrBy(ctx, "counter", 10).Val())
+		assert.Equal(t, int64(9), client.DecrBy(ctx, "counter", 2).Val())
+		assert.ErrorContains(t, client.Incr(ctx, "foo").Err(), "not an integer")
+
+		require.NoError(t, client.MSet(ctx, "a", "1", "b", "2").Err())
+		assert.Equal(t, []any{"1", "2", nil}, client.MGet(ctx, "a", "b", "c").Val())
+
+		assert.Equal(t, "1", client.GetDel(ctx, "a").Val())
+		assert.ErrorIs(t, client.Get(ctx, "a").Err(), redis.Nil)
+
+		assert.Equal(t, 3, ks.Len())
+
+		require.NoError(t, client.Set(ctx, "greeting", "Hello World", 0).Err())
+		assert.Equal(t, "World", client.GetRange(ctx, "greeting", -5, -1).Val())
+		assert.Equal(t, "", client.GetRange(ctx, "greeting", 5, 2).Val())
+		assert.Equal(t, int64(11), client.SetRange(ctx, "greeting", 6, "Redis").Val())
+		assert.Equal(t, int64(7), client.SetRange(ctx, "padded", 2, "Hello").Val())
+		assert.Equal(t, "\x00\x00Hello", client.Get(ctx, "padded").Val())
+
+		assert.False(t, client.MSetNX(ctx, "new", "1", "b", "3").Val())
+		assert.True(t, client.MSetNX(ctx, "new", "1", "other", "2").Val())
+		assert.Equal(t, 10.5, client.IncrByFloat(ctx, "new", 9.5).Val())
+		assert.ErrorContains(t, client.IncrByFloat(ctx, "greeting", 1).Err(), "not a valid float")
+	})
+
+	t.Run("keys and expiration", func(t *testing.T) {
+		t.Parallel()
+
+		client, ks := newClient(t)
+		ctx := context.Background()
+
+		now := time.Now().Truncate(time.Second)
+		ks.Now = func() time.Time { return now }
+
+		require.NoError(t, client.Set(ctx, "foo", "bar", 10*time.Second).Err())
+		require.NoError(t, client.Set(ctx, "baz", "qux", 0).Err())
+
+		assert.Equal(t, 10*time.Second, client.TTL(ctx, "foo").Val())
+		assert.Equal(t, time.Duration(-1), client.TTL(ctx, "baz").Val())
+		assert.Equal(t, time.Duration(-2), client.TTL(ctx, "missing").Val())
+		assert.Equal(t, []string{"baz", "foo"}, client.Keys(ctx, "*").Val())
+		assert.Equal(t, []string{"foo"}, client.Keys(ctx, "f?o").Val())
+		assert.Equal(t, "string", client.Type(ctx, "foo").Val())
+
+		now = now.Add(10 * time.Second)
+		assert.ErrorIs(t, client.Get(ctx, "foo").Err(), redis.Nil)
+		assert.Equal(t, int64(1), client.Exists(ctx, "foo", "baz").Val())
+
+		assert.True(t, client.Expire(ctx, "baz", time.Minute).Val())
+		assert.True(t, client.Persist(ctx, "baz").Val())
+		assert.Equal(t, time.Duration(-1), client.TTL(ctx, "baz").Val())
+
+		assert.Equal(t, int64(1), client.Del(ctx, "baz", "missing").Val())
+		assert.Equal(t, int64(0), client.DBSize(ctx).Val())
+
+		require.NoError(t, client.SetEx(ctx, "session", "abc", time.Minute).Err())
+		assert.Equal(t, now.Add(time.Minute).Unix(), client.Do(ctx, "expiretime", "session").Val())
+		assert.Equal(t, "abc", client.GetEx(ctx, "session", 0).Val())
+		assert.Equal(t, time.Duration(-1), client.TTL(ctx, "session").Val())
+		require.NoError(t, client.Do(ctx, "psetex", "session", 1500, "def").Err())
+		assert.Equal(t, 1500*time.Millisecond, client.PTTL(ctx, "session").Val())
+		assert.True(t, client.ExpireAt(ctx, "session", now.Add(time.Hour)).Val())
+		assert.Equal(t, time.Hour, client.TTL(ctx, "session").Val())
+		assert.True(t, client.PExpireAt(ctx, "session", now).Val())
+		assert.Equal(t, int64(0), client.Exists(ctx, "session").Val())
+	})
+
+	t.Run("renaming and copying keys", func(t *testing.T) {
+		t.Parallel()
+
+		client, ks := newClient(t)
+		ctx := context.Background()
+
+		now := time.Now()
+		ks.Now = func() time.Time { return now }
+
+		require.NoError(t, client.Set(ctx, "foo", "bar", time.Minute).Err())
+		require.NoError(t, client.SAdd(ctx, "set", "1", "2").Err())
+
+		require.NoError(t, client.Rename(ctx, "foo", "renamed").Err())
+		assert.Equal(t, time.Minute, client.TTL(ctx, "renamed").Val())
+		assert.ErrorContains(t, client.Rename(ctx, "foo", "other").Err(), "no such key")
+		assert.False(t, client.RenameNX(ctx, "renamed", "set").Val())
+
+		assert.Equal(t, int64(1), client.Copy(ctx, "set", "copy", 0, false).Val())
+		assert.Equal(t, int64(0), client.Copy(ctx, "renamed", "copy", 0, false).Val())
+		require.NoError(t, client.SAdd(ctx, "copy", "3").Err())
+		assert.Equal(t, []string{"1", "2"}, client.SMembers(ctx, "set").Val())
+		assert.Equal(t, int64(1), client.Copy(ctx, "renamed", "copy", 0, true).Val())
+		assert.Equal(t, "string", client.Type(ctx, "copy").Val())
+
+		assert.Equal(t, "intset", client.ObjectEncoding(ctx, "set").Val())
+		assert.Equal(t, "embstr", client.ObjectEncoding(ctx, "renamed").Val())
+		now = now.Add(3 * time.Second)
+		assert.Equal(t, 3*time.Second, client.ObjectIdleTime(ctx, "set").Val())
+		assert.Equal(t, int64(2), client.Touch(ctx, "set", "copy", "missing").Val())
+		assert.Equal(t, time.Duration(0), client.ObjectIdleTime(ctx, "set").Val())
+		assert.ErrorContains(t, client.ObjectFreq(ctx, "set").Err(), "LFU maxmemory policy")
+
+		dump := client.Dump(ctx, "set").Val()
+		assert.ErrorContains(t, client.Restore(ctx, "set", 0, dump).Err(), "BUSYKEY")
+		require.NoError(t, client.Restore(ctx, "restored", time.Minute, dump).Err())
+		assert.Equal(t, []string{"1", "2"}, client.SMembers(ctx, "restored").Val())
+		assert.Equal(t, time.Minute, client.TTL(ctx, "restored").Val())
+		require.NoError(t, client.RestoreReplace(ctx, "renamed", 0, dump).Err())
+		assert.Equal(t, "set", client.Type(ctx, "renamed").Val())
+		assert.ErrorContains(t, client.Restore(ctx, "bad", 0, "garbage").Err(), "payload")
+		assert.ErrorIs(t, client.Dump(ctx, "missing").Err(), redis.Nil)
+	})
+
+	t.Run("bitmaps", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(0), client.SetBit(ctx, "bits", 7, 1).Val())
+		assert.Equal(t, int64(1), client.SetBit(ctx, "bits", 7, 0).Val())
+		require.NoError(t, client.Set(ctx, "bits", "foobar", 0).Err())
+		assert.Equal(t, int64(1), client.GetBit(ctx, "bits", 2).Val())
+		assert.Equal(t, int64(0), client.GetBit(ctx, "bits", 1000).Val())
+
+		assert.Equal(t, int64(26), client.BitCount(ctx, "bits", nil).Val())
+		assert.Equal(t, int64(4), client.BitCount(ctx, "bits", &redis.BitCount{Start: 0, End: 0}).Val())
+		assert.Equal(t, int64(6), client.BitCount(ctx, "bits", &redis.BitCount{Start: 1, End: 1}).Val())
+		assert.Equal(t, int64(17), client.BitCount(ctx, "bits", &redis.BitCount{Start: 5, End: 30, Unit: "BIT"}).Val())
+		assert.Equal(t, int64(0), client.BitCount(ctx, "missing", nil).Val())
+
+		require.NoError(t, client.Set(ctx, "pos", "\xff\xf0\x00", 0).Err())
+		assert.Equal(t, int64(12), client.BitPos(ctx, "pos", 0).Val())
+		assert.Equal(t, int64(0), client.BitPos(ctx, "pos", 1, 0, -1).Val())
+		assert.Equal(t, int64(-1), client.BitPos(ctx, "pos", 1, 2, -1).Val())
+		assert.Equal(t, int64(7), client.BitPosSpan(ctx, "pos", 1, 7, 15, "bit").Val())
+		require.NoError(t, client.Set(ctx, "full", "\xff", 0).Err())
+		assert.Equal(t, int64(8), client.BitPos(ctx, "full", 0).Val())
+		assert.Equal(t, int64(-1), client.BitPos(ctx, "full", 0, 0, -1).Val())
+		assert.Equal(t, int64(-1), client.BitPos(ctx, "missing", 1).Val())
+
+		require.NoError(t, client.Set(ctx, "a", "\x0f\xff", 0).Err())
+		require.NoError(t, client.Set(ctx, "b", "\x3c", 0).Err())
+		assert.Equal(t, int64(2), client.BitOpAnd(ctx, "dest", "a", "b").Val())
+		assert.Equal(t, "\x0c\x00", client.Get(ctx, "dest").Val())
+		assert.Equal(t, int64(2), client.BitOpOr(ctx, "dest", "a", "b").Val())
+		assert.Equal(t, "\x3f\xff", client.Get(ctx, "dest").Val())
+		assert.Equal(t, int64(2), client.BitOpXor(ctx, "dest", "a", "b").Val())
+		assert.Equal(t, "\x33\xff", client.Get(ctx, "dest").Val())
+		assert.Equal(t, int64(1), client.BitOpNot(ctx, "dest", "b").Val())
+		assert.Equal(t, "\xc3", client.Get(ctx, "dest").Val())
+		assert.Equal(t, int64(2), client.BitOpDiff(ctx, "dest", "a", "b").Val())
+		assert.Equal(t, "\x03\xff", client.Get(ctx, "dest").Val())
+		assert.Equal(t, int64(0), client.BitOpOr(ctx, "dest", "missing").Val())
+		assert.Equal(t, int64(0), client.Exists(ctx, "dest").Val())
+
+		assert.Equal(t, []int64{0, 0}, client.BitField(ctx, "field", "SET", "u8", "#1", 200, "GET", "u4", 0).Val())
+		assert.Equal(t, []int64{-56, 200}, client.BitField(ctx, "field", "GET", "i8", 8, "GET", "u8", 8).Val())
+		assert.Equal(t, []int64{255}, client.BitField(ctx, "field", "OVERFLOW", "SAT", "INCRBY", "u8", 8, 100).Val())
+		assert.Equal(t, []int64{99}, client.BitField(ctx, "field", "INCRBY", "u8", 8, 100).Val())
+		assert.Equal(t, []any{nil, int64(99)},
+			client.Do(ctx, "bitfield", "field", "OVERFLOW", "FAIL", "INCRBY", "u8", 8, 200, "GET", "u8", 8).Val())
+		assert.Equal(t, []int64{-128}, client.BitField(ctx, "field", "OVERFLOW", "WRAP", "INCRBY", "i8", 8, 29).Val())
+		assert.ErrorContains(t, client.BitField(ctx, "field", "GET", "u64", 0).Err(), "Invalid bitfield type")
+	})
+
+	t.Run("hyperloglogs", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(1), client.PFAdd(ctx, "visitors", "a", "b", "c").Val())
+		assert.Equal(t, int64(0), client.PFAdd(ctx, "visitors", "a").Val())
+		assert.Equal(t, int64(1), client.PFAdd(ctx, "empty").Val())
+		assert.Equal(t, int64(1), client.PFAdd(ctx, "other", "c", "d").Val())
+		assert.Equal(t, int64(3), client.PFCount(ctx, "visitors").Val())
+		assert.Equal(t, int64(4), client.PFCount(ctx, "visitors", "other", "missing").Val())
+		assert.Equal(t, "string", client.Type(ctx, "visitors").Val())
+
+		require.NoError(t, client.PFMerge(ctx, "merged", "visitors", "other").Err())
+		assert.Equal(t, int64(4), client.PFCount(ctx, "merged").Val())
+
+		require.NoError(t, client.Set(ctx, "plain", "value", 0).Err())
+		assert.ErrorContains(t, client.PFAdd(ctx, "plain", "a").Err(), "not a valid HyperLogLog")
+		assert.ErrorContains(t, client.PFCount(ctx, "visitors", "plain").Err(), "not a valid HyperLogLog")
+	})
+
+	t.Run("geospatial indexes", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(2), client.GeoAdd(ctx, "Sicily",
+			&redis.GeoLocation{Name: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+			&redis.GeoLocation{Name: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+		).Val())
+		assert.Equal(t, "zset", client.Type(ctx, "Sicily").Val())
+		assert.ErrorContains(t, client.GeoAdd(ctx, "Sicily",
+			&redis.GeoLocation{Name: "Pole", Longitude: 0, Latitude: 90}).Err(), "invalid longitude,latitude pair")
+
+		positions := client.GeoPos(ctx, "Sicily", "Palermo", "Atlantis").Val()
+		require.Len(t, positions, 2)
+		assert.InDelta(t, 13.361389, positions[0].Longitude, 1e-5)
+		assert.InDelta(t, 38.115556, positions[0].Latitude, 1e-5)
+		assert.Nil(t, positions[1])
+
+		assert.Equal(t, 166274.1516, client.GeoDist(ctx, "Sicily", "Palermo", "Catania", "m").Val())
+		assert.Equal(t, 166.2742, client.GeoDist(ctx, "Sicily", "Palermo", "Catania", "km").Val())
+		assert.ErrorIs(t, client.GeoDist(ctx, "Sicily", "Palermo", "Atlantis", "m").Err(), redis.Nil)
+		assert.ErrorContains(t, client.Do(ctx, "GEODIST", "Sicily", "Palermo", "Catania", "yd").Err(),
+			"unsupported unit")
+
+		assert.Equal(t, []any{"sqc8b49rny0", "sqdtr74hyu0", nil},
+			client.Do(ctx, "GEOHASH", "Sicily", "Palermo", "Catania", "Atlantis").Val())
+
+		locations := client.GeoSearchLocation(ctx, "Sicily", &redis.GeoSearchLocationQuery{
+			GeoSearchQuery: redis.GeoSearchQuery{
+				Longitude: 15, Latitude: 37, Radius: 200, RadiusUnit: "km", Sort: "ASC",
+			},
+			WithDist: true,
+		}).Val()
+		require.Len(t, locations, 2)
+		assert.Equal(t, []string{"Catania", "Palermo"}, []string{locations[0].Name, locations[1].Name})
+		assert.Equal(t, []float64{56.4413, 190.4424}, []float64{locations[0].Dist, locations[1].Dist})
+
+		assert.Equal(t, []string{"Catania"}, client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+			Member: "Catania", BoxWidth: 100, BoxHeight: 100, BoxUnit: "km",
+		}).Val())
+		assert.Equal(t, []string{"Palermo"}, client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+			Longitude: 15, Latitude: 37, Radius: 200, RadiusUnit: "km", Sort: "DESC", Count: 1,
+		}).Val())
+		assert.ErrorContains(t, client.GeoSearch(ctx, "Sicily", &redis.GeoSearchQuery{
+			Member: "Atlantis", Radius: 1,
+		}).Err(), "could not decode requested zset member")
+		assert.ErrorContains(t, client.Do(ctx, "GEOSEARCH", "Sicily", "BYRADIUS", "1", "km", "COUNT", "1").Err(),
+			"exactly one of FROMMEMBER or FROMLONLAT")
+		assert.ErrorContains(t, client.Do(ctx, "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "FROMLONLAT", "15", "37",
+			"BYRADIUS", "1", "km").Err(), "not compatible")
+
+		assert.Equal(t, int64(1), client.GeoSearchStore(ctx, "Sicily", "near", &redis.GeoSearchStoreQuery{
+			GeoSearchQuery: redis.GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 100, RadiusUnit: "km"},
+			StoreDist:      true,
+		}).Val())
+		assert.InDelta(t, 56.4413, client.ZScore(ctx, "near", "Catania").Val(), 1e-4)
+		assert.Equal(t, int64(0), client.GeoSearchStore(ctx, "Sicily", "near", &redis.GeoSearchStoreQuery{
+			GeoSearchQuery: redis.GeoSearchQuery{Longitude: 0, Latitude: 0, Radius: 1, RadiusUnit: "km"},
+		}).Val())
+		assert.Equal(t, int64(0), client.Exists(ctx, "near").Val())
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(2), client.LPush(ctx, "list", "b", "a").Val())
+		assert.Equal(t, int64(4), client.RPush(ctx, "list", "c", "a").Val())
+		assert.Equal(t, []string{"a", "b", "c", "a"}, client.LRange(ctx, "list", 0, -1).Val())
+		assert.Equal(t, "c", client.LIndex(ctx, "list", -2).Val())
+
+		assert.Equal(t, int64(1), client.LRem(ctx, "list", -1, "a").Val())
+		assert.Equal(t, []string{"a", "b", "c"}, client.LRange(ctx, "list", 0, -1).Val())
+
+		require.NoError(t, client.LSet(ctx, "list", 1, "B").Err())
+		assert.Equal(t, "a", client.LPop(ctx, "list").Val())
+		assert.Equal(t, []string{"c", "B"}, client.RPopCount(ctx, "list", 5).Val())
+		assert.Equal(t, int64(0), client.LLen(ctx, "list").Val())
+		assert.Equal(t, int64(0), client.Exists(ctx, "list").Val())
+
+		assert.Equal(t, int64(0), client.LPushX(ctx, "list", "a").Val())
+		require.NoError(t, client.RPush(ctx, "list", "a", "b", "a", "c", "a").Err())
+		assert.Equal(t, int64(6), client.RPushX(ctx, "list", "d").Val())
+		assert.Equal(t, int64(7), client.LInsertAfter(ctx, "list", "c", "C").Val())
+		assert.Equal(t, int64(-1), client.LInsertBefore(ctx, "list", "missing", "x").Val())
+		assert.Equal(t, []string{"a", "b", "a", "c", "C", "a", "d"}, client.LRange(ctx, "list", 0, -1).Val())
+
+		assert.Equal(t, int64(2), client.LPos(ctx, "list", "a", redis.LPosArgs{Rank: 2}).Val())
+		assert.Equal(t, []int64{5, 2}, client.LPosCount(ctx, "list", "a", 2, redis.LPosArgs{Rank: -1}).Val())
+		assert.Equal(t, []int64{0}, client.LPosCount(ctx, "list", "a", 0, redis.LPosArgs{MaxLen: 2}).Val())
+		assert.ErrorIs(t, client.LPos(ctx, "list", "x", redis.LPosArgs{}).Err(), redis.Nil)
+
+		require.NoError(t, client.LTrim(ctx, "list", 1, -2).Err())
+		assert.Equal(t, []string{"b", "a", "c", "C", "a"}, client.LRange(ctx, "list", 0, -1).Val())
+
+		assert.Equal(t, "a", client.LMove(ctx, "list", "other", "RIGHT", "LEFT").Val())
+		assert.ErrorIs(t, client.LMove(ctx, "missing", "other", "LEFT", "LEFT").Err(), redis.Nil)
+
+		key, popped, err := client.LMPop(ctx, "right", 3, "missing", "list").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "list", key)
+		assert.Equal(t, []string{"C", "c", "a"}, popped)
+		assert.ErrorIs(t, client.LMPop(ctx, "left", 1, "missing").Err(), redis.Nil)
+	})
+
+	t.Run("blocking lists", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		require.NoError(t, client.RPush(ctx, "list", "a", "b", "c").Err())
+		assert.Equal(t, []string{"list", "a"}, client.BLPop(ctx, time.Second, "missing", "list").Val())
+		assert.Equal(t, []string{"list", "c"}, client.BRPop(ctx, time.Second, "list").Val())
+		assert.Equal(t, "b", client.BLMove(ctx, "list", "other", "LEFT", "RIGHT", time.Second).Val())
+		assert.Equal(t, "b", client.BRPopLPush(ctx, "other", "other", time.Second).Val())
+
+		// go-redis rounds timeouts up to a second, which Do does not.
+		start := time.Now()
+		assert.ErrorIs(t, client.Do(ctx, "BLPOP", "list", "0.05").Err(), redis.Nil)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.ErrorContains(t, client.Do(ctx, "BLPOP", "list", "-1").Err(), "timeout is negative")
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_ = client.LPush(ctx, "list", "d").Err()
+		}()
+		assert.Equal(t, []string{"list", "d"}, client.BRPop(ctx, 0, "list").Val())
+
+		// Blocked connections do not prevent the server from being closed.
+		go func() { _ = client.BLPop(ctx, 0, "list").Err() }()
+	})
+
+	t.Run("hashes", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(2), client.HSet(ctx, "hash", "a", "1", "b", "2").Val())
+		assert.Equal(t, int64(0), client.HSet(ctx, "hash", "a", "3").Val())
+		assert.Equal(t, "3", client.HGet(ctx, "hash", "a").Val())
+		assert.Equal(t, map[string]string{"a": "3", "b": "2"}, client.HGetAll(ctx, "hash").Val())
+		assert.Equal(t, []any{"3", nil}, client.HMGet(ctx, "hash", "a", "c").Val())
+		assert.Equal(t, []string{"a", "b"}, client.HKeys(ctx, "hash").Val())
+		assert.Equal(t, int64(5), client.HIncrBy(ctx, "hash", "b", 3).Val())
+		assert.True(t, client.HExists(ctx, "hash", "b").Val())
+		assert.Equal(t, int64(2), client.HDel(ctx, "hash", "a", "b", "c").Val())
+		assert.Equal(t, int64(0), client.HLen(ctx, "hash").Val())
+
+		require.NoError(t, client.HSet(ctx, "hash", "a", "1.5", "b", "abc").Err())
+		assert.Equal(t, 4.0, client.HIncrByFloat(ctx, "hash", "a", 2.5).Val())
+		assert.Equal(t, int64(3), client.HStrLen(ctx, "hash", "b").Val())
+		assert.Len(t, client.HRandField(ctx, "hash", 5).Val(), 2)
+		assert.Len(t, client.HRandField(ctx, "hash", -5).Val(), 5)
+
+		fields := client.HRandFieldWithValues(ctx, "hash", 1).Val()
+		require.Len(t, fields, 1)
+		assert.Equal(t, client.HGet(ctx, "hash", fields[0].Key).Val(), fields[0].Value)
+	})
+
+	t.Run("hash fields expiration", func(t *testing.T) {
+		t.Parallel()
+
+		client, ks := newClient(t)
+		ctx := context.Background()
+
+		now := time.Now()
+		ks.Now = func() time.Time { return now }
+
+		require.NoError(t, client.HSet(ctx, "hash", "a", "1", "b", "2", "c", "3").Err())
+		assert.Equal(t, []int64{1, -2}, client.HExpire(ctx, "hash", 10*time.Second, "a", "missing").Val())
+		assert.Equal(t, []int64{0, 1}, client.HExpireWithArgs(ctx, "hash", time.Minute,
+			redis.HExpireArgs{NX: true}, "a", "b").Val())
+		assert.Equal(t, []int64{10, 60, -1}, client.HTTL(ctx, "hash", "a", "b", "c").Val())
+		assert.Equal(t, []int64{10000}, client.HPTTL(ctx, "hash", "a").Val())
+		assert.Equal(t, []int64{1, -1}, client.HPersist(ctx, "hash", "b", "c").Val())
+
+		// Expired fields are deleted, along with their hash once empty.
+		now = now.Add(10 * time.Second)
+		assert.Equal(t, map[string]string{"b": "2", "c": "3"}, client.HGetAll(ctx, "hash").Val())
+
+		assert.Equal(t, []any{"2", nil}, client.Do(ctx, "HGETEX", "hash", "PX", "100", "FIELDS", "2", "b", "a").Val())
+		assert.Equal(t, []int64{100}, client.HPTTL(ctx, "hash", "b").Val())
+		assert.Equal(t, []any{"2"}, client.Do(ctx, "HGETEX", "hash", "PERSIST", "FIELDS", "1", "b").Val())
+		assert.Equal(t, []int64{-1}, client.HTTL(ctx, "hash", "b").Val())
+
+		assert.Equal(t, int64(0), client.HSetEXWithArgs(ctx, "hash",
+			&redis.HSetEXOptions{Condition: redis.HSetEXFNX}, "c", "x").Val())
+		assert.Equal(t, int64(1), client.HSetEXWithArgs(ctx, "hash", &redis.HSetEXOptions{
+			Condition: redis.HSetEXFXX, ExpirationType: redis.HSetEXExpirationEX, ExpirationVal: 5,
+		}, "b", "x", "c", "y").Val())
+		assert.Equal(t, []int64{5, 5}, client.HTTL(ctx, "hash", "b", "c").Val())
+
+		// Setting fields removes their expiration time.
+		require.NoError(t, client.HSet(ctx, "hash", "b", "z").Err())
+		assert.Equal(t, []int64{-1, 5}, client.HTTL(ctx, "hash", "b", "c").Val())
+
+		assert.Equal(t, []int64{2}, client.HPExpire(ctx, "hash", 0, "b").Val())
+		now = now.Add(5 * time.Second)
+		assert.Equal(t, int64(0), client.Exists(ctx, "hash").Val())
+
+		assert.ErrorContains(t, client.Do(ctx, "HTTL", "hash", "FIELDS", "2", "a").Err(), "must match")
+	})
+
+	t.Run("sets", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(3), client.SAdd(ctx, "set", "a", "b", "c", "a").Val())
+		assert.True(t, client.SIsMember(ctx, "set", "a").Val())
+		assert.Equal(t, []string{"a", "b", "c"}, client.SMembers(ctx, "set").Val())
+		assert.Len(t, client.SRandMemberN(ctx, "set", 2).Val(), 2)
+		assert.Len(t, client.SRandMemberN(ctx, "set", -5).Val(), 5)
+		assert.Equal(t, int64(1), client.SRem(ctx, "set", "a", "d").Val())
+
+		popped := client.SPopN(ctx, "set", 2).Val()
+		assert.ElementsMatch(t, []string{"b", "c"}, popped)
+		assert.Equal(t, int64(0), client.SCard(ctx, "set").Val())
+		assert.Empty(t, client.SRandMemberN(ctx, "set", -2).Val())
+
+		require.NoError(t, client.SAdd(ctx, "a", "1", "2", "3").Err())
+		require.NoError(t, client.SAdd(ctx, "b", "2", "3", "4").Err())
+		assert.Equal(t, []bool{true, false}, client.SMIsMember(ctx, "a", "1", "4").Val())
+		assert.Equal(t, []string{"2", "3"}, client.SInter(ctx, "a", "b").Val())
+		assert.Equal(t, []string{"1", "2", "3", "4"}, client.SUnion(ctx, "a", "b").Val())
+		assert.Equal(t, []string{"1"}, client.SDiff(ctx, "a", "b").Val())
+		assert.Empty(t, client.SInter(ctx, "a", "missing").Val())
+		assert.Equal(t, int64(2), client.SInterCard(ctx, 0, "a", "b").Val())
+		assert.Equal(t, int64(1), client.SInterCard(ctx, 1, "a", "b").Val())
+
+		require.NoError(t, client.Set(ctx, "dest", "string", time.Minute).Err())
+		assert.Equal(t, int64(4), client.SUnionStore(ctx, "dest", "a", "b").Val())
+		assert.Equal(t, time.Duration(-1), client.TTL(ctx, "dest").Val())
+		assert.Equal(t, int64(1), client.SDiffStore(ctx, "dest", "b", "a").Val())
+		assert.Equal(t, int64(0), client.SInterStore(ctx, "dest", "a", "missing").Val())
+		assert.Equal(t, int64(0), client.Exists(ctx, "dest").Val())
+
+		assert.True(t, client.SMove(ctx, "a", "c", "1").Val())
+		assert.False(t, client.SMove(ctx, "a", "c", "1").Val())
+		assert.Equal(t, []string{"1"}, client.SMembers(ctx, "c").Val())
+	})
+
+	t.Run("sorted sets", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		assert.Equal(t, int64(3), client.ZAdd(ctx, "zset",
+			redis.Z{Score: 2, Member: "b"},
+			redis.Z{Score: 1, Member: "a"},
+			redis.Z{Score: 2, Member: "c"},
+		).Val())
+		assert.Equal(t, int64(0), client.ZAddGT(ctx, "zset", redis.Z{Score: 0, Member: "a"}).Val())
+		assert.Equal(t, 1.0, client.ZScore(ctx, "zset", "a").Val())
+		assert.Equal(t, 3.5, client.ZIncrBy(ctx, "zset", 2.5, "a").Val())
+		assert.Equal(t, []string{"b", "c", "a"}, client.ZRange(ctx, "zset", 0, -1).Val())
+		assert.Equal(t, []redis.Z{{Score: 3.5, Member: "a"}}, client.ZRangeWithScores(ctx, "zset", -1, -1).Val())
+		assert.Equal(t, int64(1), client.ZRank(ctx, "zset", "c").Val())
+		assert.Equal(t, int64(1), client.ZRem(ctx, "zset", "a").Val())
+		assert.Equal(t, int64(2), client.ZCard(ctx, "zset").Val())
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		require.NoError(t, client.MSet(ctx, "a", "1", "b", "2", "c", "3").Err())
+		require.NoError(t, client.RPush(ctx, "list", "x").Err())
+
+		keys, cursor := client.Scan(ctx, 0, "", 2).Val()
+		assert.Equal(t, []string{"a", "b"}, keys)
+		keys, cursor = client.Scan(ctx, cursor, "", 2).Val()
+		assert.Equal(t, []string{"c", "list"}, keys)
+		assert.Zero(t, cursor)
+
+		keys, cursor = client.Scan(ctx, 0, "[ac]", 100).Val()
+		assert.Equal(t, []string{"a", "c"}, keys)
+		assert.Zero(t, cursor)
+		keys, _ = client.ScanType(ctx, 0, "", 100, "list").Val()
+		assert.Equal(t, []string{"list"}, keys)
+
+		require.NoError(t, client.HSet(ctx, "hash", "f1", "v1", "f2", "v2").Err())
+		fields, _ := client.HScan(ctx, "hash", 0, "*2", 10).Val()
+		assert.Equal(t, []string{"f2", "v2"}, fields)
+		fields, _ = client.HScanNoValues(ctx, "hash", 0, "", 10).Val()
+		assert.Equal(t, []string{"f1", "f2"}, fields)
+
+		require.NoError(t, client.SAdd(ctx, "set", "m1", "m2").Err())
+		members, _ := client.SScan(ctx, "set", 0, "", 10).Val()
+		assert.Equal(t, []string{"m1", "m2"}, members)
+
+		require.NoError(t, client.ZAdd(ctx, "zset", redis.Z{Score: 2, Member: "z1"}, redis.Z{Score: 1.5, Member: "z2"}).Err())
+		members, _ = client.ZScan(ctx, "zset", 0, "", 10).Val()
+		assert.Equal(t, []string{"z2", "1.5", "z1", "2"}, members)
+
+		members, cursor = client.SScan(ctx, "missing", 0, "", 10).Val()
+		assert.Empty(t, members)
+		assert.Zero(t, cursor)
+		assert.ErrorContains(t, client.SScan(ctx, "hash", 0, "", 10).Err(), "WRONGTYPE")
+		assert.ErrorContains(t, client.Do(ctx, "SCAN", "nope").Err(), "invalid cursor")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		client, _ := newClient(t)
+		ctx := context.Background()
+
+		require.NoError(t, client.Set(ctx, "foo", "bar", 0).Err())
+		assert.ErrorContains(t, client.LPush(ctx, "foo", "a").Err(), "WRONGTYPE")
+		assert.ErrorContains(t, client.HGet(ctx, "foo", "a").Err(), "WRONGTYPE")
+		assert.ErrorContains(t, client.Do(ctx, "GET").Err(), "wrong number of arguments for 'get' command")
+		assert.ErrorContains(t, client.Do(ctx, "SET", "foo", "bar", "NX", "XX").Err(), "syntax error")
+	})
+}
@@ -0,0 +1,94 @@
+package redistest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// unmonitoredCommands holds the commands which are not fed to the monitors,
+// as they could carry credentials.
+//
+//nolint:gochecknoglobals
+var unmonitoredCommands = []string{"AUTH", "HELLO", "MONITOR"}
+
+// handleMonitor handles the MONITOR command, after which the commands
+// received by the server are streamed to the connection.
+func (rs *StubServer) handleMonitor(c *Connection, _ []string) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	// The reply is written while holding the lock, for it to be written
+	// before any of the monitored commands.
+	c.WriteOK()
+	rs.monitors = append(rs.monitors, c)
+}
+
+// removeMonitor stops streaming commands to the provided connection.
+func (rs *StubServer) removeMonitor(c *Connection) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.monitors = slices.DeleteFunc(rs.monitors, func(m *Connection) bool { return m == c })
+}
+
+// feedMonitors streams the provided command, received on the provided
+// connection, to the monitors, in the format of the MONITOR command.
+func (rs *StubServer) feedMonitors(c *Connection, command string, args []string) {
+	if slices.Contains(unmonitoredCommands, command) {
+		return
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	if len(rs.monitors) == 0 || slices.Contains(rs.monitors, c) {
+		return
+	}
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%.6f [0 %s]", float64(time.Now().UnixMicro())/1e6, c.addr)
+	for _, arg := range append([]string{strings.ToLower(command)}, args...) {
+		line.WriteByte(' ')
+		line.WriteString(quoteMonitorArg(arg))
+	}
+
+	for _, monitor := range rs.monitors {
+		monitor.WriteSimpleString(line.String())
+		_ = monitor.flush()
+	}
+}
+
+// quoteMonitorArg quotes the provided argument the way Redis does in the
+// output of the MONITOR command.
+func quoteMonitorArg(arg string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch ch := arg[i]; ch {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if ch < 0x20 || ch > 0x7e {
+				fmt.Fprintf(&b, `\x%02x`, ch)
+			} else {
+				b.WriteByte(ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package redistest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubServerMonitor(t *testing.T) {
+	t.Parallel()
+
+	rs := RunT(t)
+	rs.RequirePassword("secret")
+	rs.UseKeyspace(NewKeyspace())
+
+	conn, err := net.Dial("tcp", rs.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n*1\r\n$7\r\nMONITOR\r\n"))
+	require.NoError(t, err)
+	for range 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", line)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Password: "secret", Protocol: 2})
+	t.Cleanup(func() { _ = client.Close() })
+	require.NoError(t, client.Set(context.Background(), "foo", "a \"quoted\"\n\x01value", 0).Err())
+
+	var lines []string
+	for len(lines) == 0 || !regexp.MustCompile(`"set"`).MatchString(lines[len(lines)-1]) {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+
+	assert.Regexp(t,
+		`^\+\d+\.\d{6} \[0 127\.0\.0\.1:\d+\] "set" "foo" "a \\"quoted\\"\\n\\x01value"\r\n$`,
+		lines[len(lines)-1],
+	)
+	for _, line := range lines {
+		assert.NotContains(t, line, "secret")
+	}
+}
@@ -0,0 +1,201 @@
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// subscription holds the channels and patterns a connection is subscribed to.
+type subscription struct {
+	channels []string
+	patterns []string
+}
+
+// count returns the number of channels and patterns of the subscription, as
+// replied to the SUBSCRIBE and PSUBSCRIBE commands.
+func (s *subscription) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// Publish sends the provided message on the provided channel to the
+// connections subscribed to it, either directly or through a pattern, and
+// returns the number of connections it was sent to.
+func (rs *StubServer) Publish(channel, message string) int {
+	rs.Lock()
+	defer rs.Unlock()
+
+	receivers := 0
+	for c, sub := range rs.subscriptions {
+		if slices.Contains(sub.channels, channel) {
+			c.WritePush("message", channel, message)
+			receivers++
+		}
+
+		for _, pattern := range sub.patterns {
+			if re, err := compilePattern(pattern); err == nil && re.MatchString(channel) {
+				c.WritePush("pmessage", pattern, channel, message)
+				receivers++
+			}
+		}
+
+		_ = c.flush()
+	}
+
+	return receivers
+}
+
+// NotifyKeyspaceEvent publishes a keyspace notification for the provided
+// event on the provided key, as a Redis server would.
+//
+// According to the notify-keyspace-events parameter, as set using the
+// CONFIG SET command, the notification is published on the keyspace channel
+// of the key (K flag), and on the keyevent channel of the event (E flag).
+// The classes of events enabled by the parameter are not taken into account.
+func (rs *StubServer) NotifyKeyspaceEvent(db int, event, key string) {
+	rs.Lock()
+	flags := rs.config["notify-keyspace-events"]
+	rs.Unlock()
+
+	if strings.Contains(flags, "K") {
+		rs.Publish(fmt.Sprintf("__keyspace@%d__:%s", db, key), event)
+	}
+
+	if strings.Contains(flags, "E") {
+		rs.Publish(fmt.Sprintf("__keyevent@%d__:%s", db, event), key)
+	}
+}
+
+// handleSubscribe subscribes the connection to the provided channels.
+func (rs *StubServer) handleSubscribe(c *Connection, channels []string) {
+	rs.subscribe(c, "subscribe", channels, func(sub *subscription) *[]string { return &sub.channels })
+}
+
+// handlePSubscribe subscribes the connection to the provided patterns.
+func (rs *StubServer) handlePSubscribe(c *Connection, patterns []string) {
+	rs.subscribe(c, "psubscribe", patterns, func(sub *subscription) *[]string { return &sub.patterns })
+}
+
+// handleUnsubscribe unsubscribes the connection from the provided channels,
+// or from all of them if none is provided.
+func (rs *StubServer) handleUnsubscribe(c *Connection, channels []string) {
+	rs.unsubscribe(c, "unsubscribe", channels, func(sub *subscription) *[]string { return &sub.channels })
+}
+
+// handlePUnsubscribe unsubscribes the connection from the provided patterns,
+// or from all of them if none is provided.
+func (rs *StubServer) handlePUnsubscribe(c *Connection, patterns []string) {
+	rs.unsubscribe(c, "punsubscribe", patterns, func(sub *subscription) *[]string { return &sub.patterns })
+}
+
+// subscribe adds the provided channels or patterns, as selected by the
+// provided function, to the connection's subscription.
+func (rs *StubServer) subscribe(c *Connection, kind string, names []string, selectNames func(*subscription) *[]string) {
+	if len(names) == 0 {
+		c.WriteError(fmt.Errorf("ERR wrong number of arguments for '%s' command", kind))
+		return
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	sub, ok := rs.subscriptions[c]
+	if !ok {
+		sub = &subscription{}
+		rs.subscriptions[c] = sub
+	}
+
+	subscribed := selectNames(sub)
+	for _, name := range names {
+		if !slices.Contains(*subscribed, name) {
+			*subscribed = append(*subscribed, name)
+		}
+		c.WritePush(kind, name, sub.count())
+	}
+}
+
+// unsubscribe removes the provided channels or patterns, as selected by the
+// provided function, from the connection's subscription, or all of them if
+// none is provided.
+func (rs *StubServer) unsubscribe(c *Connection, kind string, names []string, selectNames func(*subscription) *[]string) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	sub, ok := rs.subscriptions[c]
+	if !ok {
+		sub = &subscription{}
+	}
+
+	subscribed := selectNames(sub)
+	if len(names) == 0 {
+		names = append([]string(nil), *subscribed...)
+	}
+
+	if len(names) == 0 {
+		c.WritePush(kind, nil, sub.count())
+	}
+
+	for _, name := range names {
+		*subscribed = slices.DeleteFunc(*subscribed, func(n string) bool { return n == name })
+		c.WritePush(kind, name, sub.count())
+	}
+
+	if sub.count() == 0 {
+		delete(rs.subscriptions, c)
+	}
+}
+
+// removeSubscriber forgets the subscription of the provided connection.
+func (rs *StubServer) removeSubscriber(c *Connection) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	delete(rs.subscriptions, c)
+}
+
+// handlePublish handles the PUBLISH command.
+func (rs *StubServer) handlePublish(c *Connection, args []string) {
+	if len(args) != 2 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'publish' command"))
+		return
+	}
+
+	c.WriteInteger(rs.Publish(args[0], args[1]))
+}
+
+// handleConfig handles the GET and SET subcommands of the CONFIG command,
+// which operate on any parameter, without validating it.
+func (rs *StubServer) handleConfig(c *Connection, args []string) {
+	if len(args) == 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'config' command"))
+		return
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	switch subcommand, params := strings.ToUpper(args[0]), args[1:]; {
+	case subcommand == "GET" && len(params) > 0:
+		reply := map[string]any{}
+		for _, pattern := range params {
+			re, err := compilePattern(strings.ToLower(pattern))
+			if err != nil {
+				continue
+			}
+			for name, value := range rs.config {
+				if re.MatchString(name) {
+					reply[name] = value
+				}
+			}
+		}
+		c.WriteMap(reply)
+	case subcommand == "SET" && len(params) > 0 && len(params)%2 == 0:
+		for i := 0; i < len(params); i += 2 {
+			rs.config[strings.ToLower(params[i])] = params[i+1]
+		}
+		c.WriteOK()
+	default:
+		c.WriteError(fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'", args[0]))
+	}
+}
@@ -0,0 +1,91 @@
+package redistest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubServerPubSub(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, rs *StubServer) *redis.Client {
+		t.Helper()
+
+		client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client
+	}
+
+	t.Run("messages are published to subscribers", func(t *testing.T) {
+		t.Parallel()
+
+		rs := RunT(t)
+		ctx := context.Background()
+
+		sub := newClient(t, rs).Subscribe(ctx, "news")
+		t.Cleanup(func() { _ = sub.Close() })
+		_, err := sub.Receive(ctx)
+		require.NoError(t, err)
+
+		psub := newClient(t, rs).PSubscribe(ctx, "n*")
+		t.Cleanup(func() { _ = psub.Close() })
+		_, err = psub.Receive(ctx)
+		require.NoError(t, err)
+
+		client := newClient(t, rs)
+		assert.Equal(t, int64(2), client.Publish(ctx, "news", "hello").Val())
+		assert.Equal(t, int64(0), client.Publish(ctx, "sports", "hello").Val())
+
+		msg, err := sub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &redis.Message{Channel: "news", Payload: "hello"}, msg)
+
+		msg, err = psub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &redis.Message{Channel: "news", Pattern: "n*", Payload: "hello"}, msg)
+
+		require.NoError(t, psub.PUnsubscribe(ctx))
+		_, err = psub.Receive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, rs.Publish("news", "bye"))
+	})
+
+	t.Run("keyspace notifications follow the configuration", func(t *testing.T) {
+		t.Parallel()
+
+		rs := RunT(t)
+		ctx := context.Background()
+		client := newClient(t, rs)
+
+		psub := newClient(t, rs).PSubscribe(ctx, "__key*@0__:*")
+		t.Cleanup(func() { _ = psub.Close() })
+		_, err := psub.Receive(ctx)
+		require.NoError(t, err)
+
+		// Notifications are disabled by default.
+		rs.NotifyKeyspaceEvent(0, "expired", "foo")
+
+		require.NoError(t, client.ConfigSet(ctx, "notify-keyspace-events", "KEx").Err())
+		assert.Equal(t,
+			map[string]string{"notify-keyspace-events": "KEx"},
+			client.ConfigGet(ctx, "notify-*").Val(),
+		)
+
+		rs.NotifyKeyspaceEvent(0, "expired", "bar")
+
+		msg, err := psub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "__keyspace@0__:bar", msg.Channel)
+		assert.Equal(t, "expired", msg.Payload)
+
+		msg, err = psub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "__keyevent@0__:expired", msg.Channel)
+		assert.Equal(t, "bar", msg.Payload)
+	})
+}
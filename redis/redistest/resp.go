@@ -0,0 +1,422 @@
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RESPRequestReader is a RESP protocol request reader.
+type RESPRequestReader struct {
+	reader *bufio.Reader
+}
+
+// NewRESPRequestReader returns a new RESPRequestReader.
+func NewRESPRequestReader(reader *bufio.Reader) *RESPRequestReader {
+	return &RESPRequestReader{reader: reader}
+}
+
+// ReadCommand reads a RESP command from the reader, parses it, and
+// returns the parsed command, args, and any potential error encountered.
+func (rrr *RESPRequestReader) ReadCommand() (string, []string, error) {
+	elements, err := scanArray(rrr.reader)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(elements) < 1 {
+		return "", nil, ErrInvalidSyntax
+	}
+
+	return strings.ToUpper(elements[0]), elements[1:], nil
+}
+
+// ErrInvalidSyntax is returned when a RESP protocol message
+// is malformed and cannot be parsed.
+var ErrInvalidSyntax = errors.New("invalid RESP protocol syntax")
+
+// Prefix is a placeholder type for the prefix symbol of
+// RESP response message.
+type Prefix byte
+
+// RESP protocol response type prefixes definitions
+const (
+	SimpleStringPrefix Prefix = '+'
+	ErrorPrefix               = '-'
+	IntegerPrefix             = ':'
+	BulkStringPrefix          = '$'
+	ArrayPrefix               = '*'
+	UnknownPrefix
+)
+
+// RESP3 protocol response type prefixes definitions
+const (
+	NullPrefix           Prefix = '_'
+	DoublePrefix         Prefix = ','
+	BooleanPrefix        Prefix = '#'
+	BigNumberPrefix      Prefix = '('
+	VerbatimStringPrefix Prefix = '='
+	MapPrefix            Prefix = '%'
+	SetPrefix            Prefix = '~'
+	PushPrefix           Prefix = '>'
+)
+
+// Set is a collection of values, written as a RESP3 set by WriteValue.
+type Set []any
+
+// Push is a collection of values, written as a RESP3 push message by
+// WriteValue. Push messages are sent out of band, such as the messages
+// published to the channels a client subscribed to.
+type Push []any
+
+// VerbatimString is a string along with its format, written as a RESP3
+// verbatim string by WriteValue.
+type VerbatimString struct {
+	// Format is the three characters long format of the string,
+	// such as "txt" or "mkd".
+	Format string
+	Text   string
+}
+
+// RESPResponseWriter is a RESP protocol response writer.
+//
+// It writes the RESP3 types as such when the RESP3 protocol was negotiated
+// with the client, and falls back to their closest RESP2 equivalent
+// otherwise: maps, sets and push messages are written as arrays, doubles,
+// big numbers and verbatim strings as bulk strings, and booleans as
+// integers.
+type RESPResponseWriter struct {
+	writer   *bufio.Writer
+	protocol int
+}
+
+// WriteSimpleString writes a redis inline string
+func (rw *RESPResponseWriter) WriteSimpleString(s string) {
+	_, _ = fmt.Fprintf(rw.writer, "+%s\r\n", inline(s))
+}
+
+// WriteError writes a redis 'Error'
+func (rw *RESPResponseWriter) WriteError(err error) {
+	_, _ = fmt.Fprintf(rw.writer, "-%s\r\n", inline(err.Error()))
+}
+
+// WriteInteger writes an integer
+func (rw *RESPResponseWriter) WriteInteger(n int) {
+	_, _ = fmt.Fprintf(rw.writer, ":%d\r\n", n)
+}
+
+// WriteBulkString writes a bulk string
+func (rw *RESPResponseWriter) WriteBulkString(s string) {
+	_, _ = fmt.Fprintf(rw.writer, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// WriteArray writes a list of strings (bulk)
+func (rw *RESPResponseWriter) WriteArray(strs ...string) {
+	rw.writeLen(len(strs))
+	for _, s := range strs {
+		if s == "" || s == "nil" {
+			rw.WriteNull()
+			continue
+		}
+
+		rw.WriteBulkString(s)
+	}
+}
+
+// WriteNull writes a redis Null element
+func (rw *RESPResponseWriter) WriteNull() {
+	if rw.resp3() {
+		_, _ = fmt.Fprintf(rw.writer, "_\r\n")
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, "$-1\r\n")
+}
+
+// WriteDouble writes a double
+func (rw *RESPResponseWriter) WriteDouble(f float64) {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	case math.IsNaN(f):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	if !rw.resp3() {
+		rw.WriteBulkString(s)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, ",%s\r\n", s)
+}
+
+// WriteBoolean writes a boolean
+func (rw *RESPResponseWriter) WriteBoolean(b bool) {
+	if !rw.resp3() {
+		if b {
+			rw.WriteInteger(1)
+		} else {
+			rw.WriteInteger(0)
+		}
+		return
+	}
+
+	if b {
+		_, _ = fmt.Fprintf(rw.writer, "#t\r\n")
+	} else {
+		_, _ = fmt.Fprintf(rw.writer, "#f\r\n")
+	}
+}
+
+// WriteBigNumber writes a big number
+func (rw *RESPResponseWriter) WriteBigNumber(n *big.Int) {
+	if !rw.resp3() {
+		rw.WriteBulkString(n.String())
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, "(%s\r\n", n.String())
+}
+
+// WriteVerbatimString writes a verbatim string of the provided format
+func (rw *RESPResponseWriter) WriteVerbatimString(format, s string) {
+	if !rw.resp3() {
+		rw.WriteBulkString(s)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, "=%d\r\n%s:%s\r\n", len(format)+1+len(s), format, s)
+}
+
+// WriteArrayHeader writes the header of an array of n elements, which
+// must be written next
+func (rw *RESPResponseWriter) WriteArrayHeader(n int) {
+	rw.writeLen(n)
+}
+
+// WriteMapHeader writes the header of a map of n entries, whose keys and
+// values must be written next, alternately
+func (rw *RESPResponseWriter) WriteMapHeader(n int) {
+	if !rw.resp3() {
+		rw.writeLen(2 * n)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, "%%%d\r\n", n)
+}
+
+// WriteSetHeader writes the header of a set of n elements, which must be
+// written next
+func (rw *RESPResponseWriter) WriteSetHeader(n int) {
+	if !rw.resp3() {
+		rw.writeLen(n)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, "~%d\r\n", n)
+}
+
+// WritePushHeader writes the header of a push message of n elements,
+// which must be written next
+func (rw *RESPResponseWriter) WritePushHeader(n int) {
+	if !rw.resp3() {
+		rw.writeLen(n)
+		return
+	}
+
+	_, _ = fmt.Fprintf(rw.writer, ">%d\r\n", n)
+}
+
+// WriteValue writes the provided value, using the RESP type matching its
+// Go type:
+//   - nil is written as a null
+//   - string as a bulk string, and []byte as well
+//   - int and int64 as integers
+//   - float64 as a double
+//   - bool as a boolean
+//   - *big.Int as a big number
+//   - error as an error
+//   - VerbatimString as a verbatim string
+//   - []any and []string as arrays
+//   - map[string]any as a map, sorted by key
+//   - Set as a set
+//   - Push as a push message
+//
+// Collections can be nested. Values of any other type are written as
+// bulk strings, using their default format.
+//
+//nolint:cyclop
+func (rw *RESPResponseWriter) WriteValue(value any) {
+	switch v := value.(type) {
+	case nil:
+		rw.WriteNull()
+	case string:
+		rw.WriteBulkString(v)
+	case []byte:
+		rw.WriteBulkString(string(v))
+	case int:
+		rw.WriteInteger(v)
+	case int64:
+		rw.WriteInteger(int(v))
+	case float64:
+		rw.WriteDouble(v)
+	case bool:
+		rw.WriteBoolean(v)
+	case *big.Int:
+		rw.WriteBigNumber(v)
+	case error:
+		rw.WriteError(v)
+	case VerbatimString:
+		rw.WriteVerbatimString(v.Format, v.Text)
+	case []string:
+		rw.writeLen(len(v))
+		for _, s := range v {
+			rw.WriteBulkString(s)
+		}
+	case []any:
+		rw.writeLen(len(v))
+		rw.writeValues(v)
+	case Set:
+		rw.WriteSetHeader(len(v))
+		rw.writeValues(v)
+	case Push:
+		rw.WritePushHeader(len(v))
+		rw.writeValues(v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		rw.WriteMapHeader(len(v))
+		for _, key := range keys {
+			rw.WriteBulkString(key)
+			rw.WriteValue(v[key])
+		}
+	default:
+		rw.WriteBulkString(fmt.Sprint(v))
+	}
+}
+
+func (rw *RESPResponseWriter) writeValues(values []any) {
+	for _, value := range values {
+		rw.WriteValue(value)
+	}
+}
+
+func (rw *RESPResponseWriter) writeLen(n int) {
+	_, _ = fmt.Fprintf(rw.writer, "*%d\r\n", n)
+}
+
+// resp3 returns true if the RESP3 protocol was negotiated with the client.
+func (rw *RESPResponseWriter) resp3() bool {
+	return rw.protocol == 3
+}
+
+func inline(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// scanBulkString reads a RESP bulk string message from a bufio.reader
+//
+// It also strips it from its prefix and trailing CRLF character, returning
+// only the interpretable content of the message.
+func scanBulkString(r *bufio.Reader) (string, error) {
+	line, err := scanLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	switch Prefix(line[0]) {
+	case BulkStringPrefix:
+		length, err := strconv.Atoi(line[1 : len(line)-2])
+		if err != nil {
+			return "", err
+		}
+
+		if length < 0 {
+			return line, nil
+		}
+
+		buf := make([]byte, length+2)
+		for pos := 0; pos < length+2; {
+			n, err := r.Read(buf[pos:])
+			if err != nil {
+				return "", err
+			}
+
+			pos += n
+		}
+
+		return string(buf[:len(buf)-2]), nil
+	default:
+		return "", ErrInvalidSyntax
+	}
+}
+
+// scanArray reads a RESP array message from a bufio.Reader.
+//
+// It strips it from its prefix and trailing CRLF character,
+// returning only the interpretable content of the message.
+func scanArray(r *bufio.Reader) ([]string, error) {
+	line, err := scanLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 {
+		return nil, ErrInvalidSyntax
+	}
+
+	if Prefix(line[0]) != ArrayPrefix {
+		return nil, ErrInvalidSyntax
+	}
+
+	length, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []string
+	for ; length > 0; length-- {
+		next, err := scanBulkString(r)
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, next)
+	}
+
+	return elements, nil
+}
+
+// scanLine reads a RESP protocol line from a bufio.Reader.
+func scanLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if len(line) < 3 {
+		return "", ErrInvalidSyntax
+	}
+
+	return line, nil
+}
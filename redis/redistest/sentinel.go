@@ -0,0 +1,323 @@
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Channels on which sentinels publish the failover events clients
+// subscribe to.
+const (
+	switchMasterChannel      = "+switch-master"
+	replicaReconfDoneChannel = "+replica-reconf-done"
+)
+
+// StubSentinel is a set of StubServers emulating Redis sentinels, all
+// monitoring the same master and its replicas.
+//
+// The sentinel nodes answer the SENTINEL GET-MASTER-ADDR-BY-NAME, MASTER,
+// MASTERS, REPLICAS, SLAVES, SENTINELS and FAILOVER commands, as well as the
+// SUBSCRIBE command, through which clients are notified of failovers.
+//
+// A failover is simulated using Failover, or by sending the SENTINEL
+// FAILOVER command to one of the sentinels: a replica is promoted as the
+// new master, and a +switch-master message is published to the clients
+// subscribed to the sentinels.
+//
+// The monitored servers are not altered by a failover: tests wanting the
+// former master to reject writes, as a demoted master would, can inject
+// a Fault replying with ErrReadOnly in it.
+type StubSentinel struct {
+	// Sentinels holds the sentinel nodes.
+	Sentinels []*StubServer
+
+	// MasterName is the name of the monitored master.
+	MasterName string
+
+	mu          sync.Mutex
+	master      *StubServer
+	replicas    []*StubServer
+	subscribers map[*Connection][]string
+}
+
+// RunSentinelT starts the provided number of sentinel nodes, monitoring the
+// provided master, known under the provided name, and its replicas, for a
+// given test context. It registers the test cleanup after your test is done.
+func RunSentinelT(
+	t testing.TB, sentinels int, masterName string, master *StubServer, replicas ...*StubServer,
+) *StubSentinel {
+	t.Helper()
+
+	if sentinels < 1 {
+		t.Fatalf("could not start StubSentinel; reason: at least one sentinel is required")
+	}
+
+	ss := &StubSentinel{
+		Sentinels:   make([]*StubServer, sentinels),
+		MasterName:  masterName,
+		master:      master,
+		replicas:    append([]*StubServer(nil), replicas...),
+		subscribers: make(map[*Connection][]string),
+	}
+
+	for i := range sentinels {
+		ss.Sentinels[i] = RunT(t)
+	}
+
+	for i, sentinel := range ss.Sentinels {
+		ss.setupNode(sentinel, i)
+	}
+
+	return ss
+}
+
+// Addrs returns the addresses of the sentinel nodes.
+func (ss *StubSentinel) Addrs() []string {
+	addrs := make([]string, 0, len(ss.Sentinels))
+	for _, sentinel := range ss.Sentinels {
+		addrs = append(addrs, sentinel.Addr().String())
+	}
+
+	return addrs
+}
+
+// Master returns the master currently advertised by the sentinels.
+func (ss *StubSentinel) Master() *StubServer {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return ss.master
+}
+
+// Replicas returns the replicas currently advertised by the sentinels.
+func (ss *StubSentinel) Replicas() []*StubServer {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return append([]*StubServer(nil), ss.replicas...)
+}
+
+// Failover promotes the provided server as the new master, and publishes
+// a +switch-master message to the clients subscribed to the sentinels.
+//
+// If the provided server is one of the replicas, the former master takes
+// its place among them. Failing over to the current master does nothing.
+func (ss *StubSentinel) Failover(newMaster *StubServer) {
+	ss.mu.Lock()
+	oldMaster := ss.master
+	if newMaster == oldMaster {
+		ss.mu.Unlock()
+		return
+	}
+
+	if i := slices.Index(ss.replicas, newMaster); i >= 0 {
+		ss.replicas[i] = oldMaster
+	} else {
+		ss.replicas = append(ss.replicas, oldMaster)
+	}
+	ss.master = newMaster
+	ss.mu.Unlock()
+
+	oldIP, oldPort := hostPort(oldMaster)
+	newIP, newPort := hostPort(newMaster)
+
+	ss.publish(switchMasterChannel, strings.Join([]string{ss.MasterName, oldIP, oldPort, newIP, newPort}, " "))
+	ss.publish(replicaReconfDoneChannel, fmt.Sprintf("replica %s:%s %s:%s @ %s %s %s",
+		oldIP, oldPort, oldIP, oldPort, ss.MasterName, newIP, newPort))
+}
+
+// publish sends a message on the provided channel to the connections
+// subscribed to it, and forgets the connections which were closed.
+func (ss *StubSentinel) publish(channel, message string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for c, channels := range ss.subscribers {
+		if !slices.Contains(channels, channel) {
+			continue
+		}
+
+		c.WritePush("message", channel, message)
+		if err := c.flush(); err != nil {
+			delete(ss.subscribers, c)
+		}
+	}
+}
+
+// setupNode registers the sentinel commands handlers on the sentinel node
+// at the provided index.
+func (ss *StubSentinel) setupNode(node *StubServer, index int) {
+	node.RegisterCommandHandler("SENTINEL", func(c *Connection, args []string) {
+		ss.handleSentinel(c, index, args)
+	})
+	node.RegisterCommandHandler("SUBSCRIBE", ss.handleSubscribe)
+	node.RegisterCommandHandler("UNSUBSCRIBE", ss.handleUnsubscribe)
+}
+
+// handleSentinel handles the SENTINEL command's subcommands.
+func (ss *StubSentinel) handleSentinel(c *Connection, index int, args []string) {
+	if len(args) == 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'sentinel' command"))
+		return
+	}
+
+	subcommand := strings.ToUpper(args[0])
+	if subcommand == "MASTERS" {
+		c.WriteValue([]any{ss.masterState()})
+		return
+	}
+
+	if len(args) != 2 {
+		c.WriteError(fmt.Errorf("ERR wrong number of arguments for 'sentinel|%s' command", strings.ToLower(args[0])))
+		return
+	}
+
+	if args[1] != ss.MasterName {
+		if subcommand == "GET-MASTER-ADDR-BY-NAME" {
+			c.WriteNull()
+		} else {
+			c.WriteError(errors.New("ERR No such master with that name"))
+		}
+		return
+	}
+
+	switch subcommand {
+	case "GET-MASTER-ADDR-BY-NAME":
+		ip, port := hostPort(ss.Master())
+		c.WriteArray(ip, port)
+	case "MASTER":
+		c.WriteValue(ss.masterState())
+	case "REPLICAS", "SLAVES":
+		master := ss.Master()
+		replicas := ss.Replicas()
+		reply := make([]any, 0, len(replicas))
+		for _, replica := range replicas {
+			reply = append(reply, replicaState(replica, master))
+		}
+		c.WriteValue(reply)
+	case "SENTINELS":
+		reply := make([]any, 0, len(ss.Sentinels)-1)
+		for i, sentinel := range ss.Sentinels {
+			if i != index {
+				reply = append(reply, sentinelState(sentinel))
+			}
+		}
+		c.WriteValue(reply)
+	case "FAILOVER":
+		replicas := ss.Replicas()
+		if len(replicas) == 0 {
+			c.WriteError(errors.New("NOGOODSLAVE No suitable replica to promote"))
+			return
+		}
+		ss.Failover(replicas[0])
+		c.WriteOK()
+	default:
+		c.WriteError(fmt.Errorf("ERR unknown subcommand '%s'", args[0]))
+	}
+}
+
+// handleSubscribe subscribes the connection to the provided channels.
+func (ss *StubSentinel) handleSubscribe(c *Connection, channels []string) {
+	if len(channels) == 0 {
+		c.WriteError(errors.New("ERR wrong number of arguments for 'subscribe' command"))
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, channel := range channels {
+		if !slices.Contains(ss.subscribers[c], channel) {
+			ss.subscribers[c] = append(ss.subscribers[c], channel)
+		}
+		c.WritePush("subscribe", channel, len(ss.subscribers[c]))
+	}
+}
+
+// handleUnsubscribe unsubscribes the connection from the provided channels,
+// or from all of them if none is provided.
+func (ss *StubSentinel) handleUnsubscribe(c *Connection, channels []string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if len(channels) == 0 {
+		channels = append([]string(nil), ss.subscribers[c]...)
+	}
+
+	for _, channel := range channels {
+		ss.subscribers[c] = slices.DeleteFunc(ss.subscribers[c], func(ch string) bool { return ch == channel })
+		c.WritePush("unsubscribe", channel, len(ss.subscribers[c]))
+	}
+
+	if len(ss.subscribers[c]) == 0 {
+		delete(ss.subscribers, c)
+	}
+}
+
+// masterState returns the description of the master in the SENTINEL MASTER
+// reply.
+func (ss *StubSentinel) masterState() map[string]any {
+	ss.mu.Lock()
+	master, replicas := ss.master, len(ss.replicas)
+	ss.mu.Unlock()
+
+	ip, port := hostPort(master)
+
+	return map[string]any{
+		"name":                    ss.MasterName,
+		"ip":                      ip,
+		"port":                    port,
+		"runid":                   strings.Repeat("0", 40),
+		"flags":                   "master",
+		"num-slaves":              strconv.Itoa(replicas),
+		"num-other-sentinels":     strconv.Itoa(len(ss.Sentinels) - 1),
+		"quorum":                  strconv.Itoa(len(ss.Sentinels)/2 + 1),
+		"role-reported":           "master",
+		"config-epoch":            "0",
+		"failover-timeout":        "180000",
+		"parallel-syncs":          "1",
+		"down-after-milliseconds": "30000",
+	}
+}
+
+// replicaState returns the description of a replica in the SENTINEL
+// REPLICAS reply.
+func replicaState(replica, master *StubServer) map[string]any {
+	ip, port := hostPort(replica)
+	masterIP, masterPort := hostPort(master)
+
+	return map[string]any{
+		"name":               ip + ":" + port,
+		"ip":                 ip,
+		"port":               port,
+		"flags":              "slave",
+		"role-reported":      "slave",
+		"master-host":        masterIP,
+		"master-port":        masterPort,
+		"master-link-status": "ok",
+	}
+}
+
+// sentinelState returns the description of a sentinel in the SENTINEL
+// SENTINELS reply.
+func sentinelState(sentinel *StubServer) map[string]any {
+	ip, port := hostPort(sentinel)
+
+	return map[string]any{
+		"name":  ip + ":" + port,
+		"ip":    ip,
+		"port":  port,
+		"flags": "sentinel",
+	}
+}
+
+// hostPort returns the IP and port of the provided server, as advertised
+// by sentinels.
+func hostPort(rs *StubServer) (string, string) {
+	return rs.Addr().IP.String(), strconv.Itoa(rs.Addr().Port)
+}
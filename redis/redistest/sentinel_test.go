@@ -0,0 +1,139 @@
+package redistest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubSentinel(t *testing.T) {
+	t.Parallel()
+
+	// newServer returns a server replying to GET commands with its name.
+	newServer := func(t *testing.T, name string) *StubServer {
+		t.Helper()
+
+		rs := RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *Connection, _ []string) {
+			c.WriteBulkString(name)
+		})
+
+		return rs
+	}
+
+	newClient := func(t *testing.T, ss *StubSentinel) *redis.Client {
+		t.Helper()
+
+		client := redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    ss.MasterName,
+			SentinelAddrs: ss.Addrs(),
+			Protocol:      2,
+		})
+		t.Cleanup(func() { _ = client.Close() })
+
+		return client
+	}
+
+	t.Run("sentinels advertise the monitored servers", func(t *testing.T) {
+		t.Parallel()
+
+		master, replica := RunT(t), RunT(t)
+		ss := RunSentinelT(t, 2, "mymaster", master, replica)
+
+		client := redis.NewSentinelClient(&redis.Options{Addr: ss.Sentinels[0].Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+		ctx := context.Background()
+
+		addr, err := client.GetMasterAddrByName(ctx, "mymaster").Result()
+		require.NoError(t, err)
+		ip, port := hostPort(master)
+		assert.Equal(t, []string{ip, port}, addr)
+
+		assert.ErrorIs(t, client.GetMasterAddrByName(ctx, "unknown").Err(), redis.Nil)
+		assert.ErrorContains(t, client.Master(ctx, "unknown").Err(), "No such master")
+
+		state, err := client.Master(ctx, "mymaster").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "master", state["flags"])
+		assert.Equal(t, "1", state["num-slaves"])
+		assert.Equal(t, "1", state["num-other-sentinels"])
+
+		replicas, err := client.Replicas(ctx, "mymaster").Result()
+		require.NoError(t, err)
+		require.Len(t, replicas, 1)
+		assert.Equal(t, replica.Addr().String(), replicas[0]["name"])
+		assert.Equal(t, port, replicas[0]["master-port"])
+
+		sentinels, err := client.Sentinels(ctx, "mymaster").Result()
+		require.NoError(t, err)
+		require.Len(t, sentinels, 1)
+		assert.Equal(t, ss.Sentinels[1].Addr().String(), sentinels[0]["name"])
+	})
+
+	t.Run("clients connect to the master", func(t *testing.T) {
+		t.Parallel()
+
+		ss := RunSentinelT(t, 1, "mymaster", newServer(t, "master"), newServer(t, "replica"))
+		client := newClient(t, ss)
+
+		assert.Equal(t, "master", client.Get(context.Background(), "foo").Val())
+	})
+
+	t.Run("clients follow failovers", func(t *testing.T) {
+		t.Parallel()
+
+		master, replica := newServer(t, "master"), newServer(t, "replica")
+		ss := RunSentinelT(t, 2, "mymaster", master, replica)
+		client := newClient(t, ss)
+
+		ctx := context.Background()
+		require.Equal(t, "master", client.Get(ctx, "foo").Val())
+
+		ss.Failover(replica)
+		assert.Same(t, replica, ss.Master())
+		assert.Equal(t, []*StubServer{master}, ss.Replicas())
+
+		assert.Eventually(t, func() bool {
+			return client.Get(ctx, "foo").Val() == "replica"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("failovers are published", func(t *testing.T) {
+		t.Parallel()
+
+		master, replica := RunT(t), RunT(t)
+		ss := RunSentinelT(t, 1, "mymaster", master, replica)
+
+		client := redis.NewSentinelClient(&redis.Options{Addr: ss.Sentinels[0].Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+		ctx := context.Background()
+
+		pubsub := client.Subscribe(ctx, "+switch-master")
+		t.Cleanup(func() { _ = pubsub.Close() })
+		_, err := pubsub.Receive(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, client.Failover(ctx, "mymaster").Err())
+
+		msg, err := pubsub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		oldIP, oldPort := hostPort(master)
+		newIP, newPort := hostPort(replica)
+		assert.Equal(t, "mymaster "+oldIP+" "+oldPort+" "+newIP+" "+newPort, msg.Payload)
+	})
+
+	t.Run("failovers require a replica", func(t *testing.T) {
+		t.Parallel()
+
+		ss := RunSentinelT(t, 1, "mymaster", RunT(t))
+
+		client := redis.NewSentinelClient(&redis.Options{Addr: ss.Sentinels[0].Addr().String(), Protocol: 2})
+		t.Cleanup(func() { _ = client.Close() })
+
+		assert.ErrorContains(t, client.Failover(context.Background(), "mymaster").Err(), "NOGOODSLAVE")
+	})
+}
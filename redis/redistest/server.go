@@ -1,4 +1,9 @@
-package redis
+// Package redistest provides a stub Redis server, speaking the RESP
+// protocol, to test redis clients against.
+//
+// It is used to test the xk6-redis extension itself, and can be used by
+// extensions built on top of it as well.
+package redistest
 
 import (
 	"bufio"
@@ -7,13 +12,13 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"math/big"
 	"net"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
-	"unicode"
 )
 
 // RunT starts a new redis stub TCP server for a given test context.
@@ -63,13 +68,34 @@ type StubServer struct {
 	listener  net.Listener
 	boundAddr *net.TCPAddr
 
-	connections     map[net.Conn]struct{}
+	connections     map[net.Conn]*Connection
 	connectionCount int
 
 	handlers          map[string]func(*Connection, []string)
 	processedCommands int
 	commandsHistory   [][]string
 
+	faults         []*faultRule
+	injectedFaults int
+
+	// users holds the users clients must authenticate as, if any.
+	users map[string]User
+
+	// monitors holds the connections the received commands are streamed
+	// to, following a MONITOR command.
+	monitors []*Connection
+
+	// subscriptions holds the channels and patterns the connections are
+	// subscribed to, using the SUBSCRIBE and PSUBSCRIBE commands.
+	subscriptions map[*Connection]*subscription
+
+	// config holds the configuration parameters set using CONFIG SET.
+	config map[string]string
+
+	// redirect, if set, is called before handling each command, and returns
+	// true if it replied with a redirection to another server instead.
+	redirect func(c *Connection, command string, args []string) bool
+
 	tlsCert []byte
 	cert    *tls.Certificate
 
@@ -82,8 +108,10 @@ func NewStubServer() *StubServer {
 	return &StubServer{
 		listener:        nil,
 		boundAddr:       nil,
-		connections:     map[net.Conn]struct{}{},
+		connections:     map[net.Conn]*Connection{},
 		handlers:        make(map[string]func(*Connection, []string)),
+		subscriptions:   make(map[*Connection]*subscription),
+		config:          make(map[string]string),
 		ignoredCommands: []string{"CLIENT"}, // this is usually not interesting
 	}
 }
@@ -101,7 +129,7 @@ func (rs *StubServer) Start(secure bool, clientCert []byte) error {
 	if secure { //nolint: nestif
 		// TODO: Generate the cert only once per test run and reuse it, instead
 		// of once per StubServer start?
-		cert, pkey, err := generateTLSCert()
+		cert, pkey, err := GenerateTLSCert()
 		if err != nil {
 			return err
 		}
@@ -148,8 +176,9 @@ func (rs *StubServer) Start(secure bool, clientCert []byte) error {
 		rs.listenAndServe(listener)
 
 		rs.Lock()
-		for c := range rs.connections {
-			c.Close() //nolint:errcheck
+		for nc, c := range rs.connections {
+			nc.Close() //nolint:errcheck
+			close(c.closed)
 		}
 		rs.Unlock()
 	})
@@ -160,6 +189,15 @@ func (rs *StubServer) Start(secure bool, clientCert []byte) error {
 		c.WriteArray("OK")
 	})
 
+	rs.RegisterCommandHandler("MONITOR", rs.handleMonitor)
+
+	rs.RegisterCommandHandler("SUBSCRIBE", rs.handleSubscribe)
+	rs.RegisterCommandHandler("UNSUBSCRIBE", rs.handleUnsubscribe)
+	rs.RegisterCommandHandler("PSUBSCRIBE", rs.handlePSubscribe)
+	rs.RegisterCommandHandler("PUNSUBSCRIBE", rs.handlePUnsubscribe)
+	rs.RegisterCommandHandler("PUBLISH", rs.handlePublish)
+	rs.RegisterCommandHandler("CONFIG", rs.handleConfig)
+
 	// We register a default PING command handler
 	rs.RegisterCommandHandler("PING", func(c *Connection, args []string) {
 		if len(args) == 1 {
@@ -188,6 +226,71 @@ func (rs *StubServer) Close() {
 	rs.waitGroup.Wait()
 }
 
+// EnableRESP3 registers a handler for the HELLO command, allowing clients to
+// negotiate the protocol version used on their connection, either RESP2 or
+// RESP3, and optionally to authenticate and name it.
+//
+// Without it, the HELLO command is unknown to the server, which clients
+// interpret as the server only supporting the RESP2 protocol.
+func (rs *StubServer) EnableRESP3() {
+	rs.RegisterCommandHandler("HELLO", func(c *Connection, args []string) {
+		version := 0
+		if len(args) > 0 {
+			var err error
+			if version, err = strconv.Atoi(args[0]); err != nil {
+				c.WriteError(errors.New("ERR Protocol version is not an integer or out of range"))
+				return
+			}
+
+			if version != 2 && version != 3 {
+				c.WriteError(errors.New("NOPROTO unsupported protocol version"))
+				return
+			}
+		}
+
+		authenticated := false
+		for i := 1; i < len(args); i++ {
+			switch option := strings.ToUpper(args[i]); {
+			case option == "AUTH" && i+2 < len(args):
+				if err := rs.authenticate(c, args[i+1], args[i+2]); err != nil {
+					c.WriteError(err)
+					return
+				}
+				authenticated = true
+				i += 2
+			case option == "SETNAME" && i+1 < len(args):
+				i++
+			default:
+				c.WriteError(fmt.Errorf("ERR Syntax error in HELLO option '%s'", args[i]))
+				return
+			}
+		}
+
+		if _, ok := rs.connectionUser(c); !ok && !authenticated {
+			c.WriteError(errors.New("NOAUTH HELLO must be called with the client already authenticated, " +
+				"otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate " +
+				"the client and select the RESP protocol version at the same time"))
+			return
+		}
+
+		if version != 0 {
+			c.mutex.Lock()
+			c.protocol = version
+			c.mutex.Unlock()
+		}
+
+		c.WriteValue(map[string]any{
+			"server":  "redis",
+			"version": "7.4.0",
+			"proto":   c.Protocol(),
+			"id":      c.id,
+			"mode":    "standalone",
+			"role":    "master",
+			"modules": []any{},
+		})
+	})
+}
+
 // RegisterCommandHandler registers a handler for a redis command.
 //
 // The handler is called when the command is received. It gives access
@@ -242,17 +345,21 @@ func (rs *StubServer) listenAndServe(l net.Listener) {
 			return
 		}
 
+		connection := NewConnection(bufio.NewReader(nc), bufio.NewWriter(nc))
+		connection.addr = nc.RemoteAddr().String()
+
 		rs.waitGroup.Add(1)
 		rs.Lock()
-		rs.connections[nc] = struct{}{}
+		rs.connections[nc] = connection
 		rs.connectionCount++
+		connection.id = rs.connectionCount
 		rs.Unlock()
 
 		go func() {
 			defer rs.waitGroup.Done()
 			defer nc.Close() //nolint:errcheck
 
-			rs.handleConnection(nc)
+			rs.handleConnection(connection)
 
 			rs.Lock()
 			delete(rs.connections, nc)
@@ -262,8 +369,9 @@ func (rs *StubServer) listenAndServe(l net.Listener) {
 }
 
 // handleConnection handles a single redis client connection.
-func (rs *StubServer) handleConnection(nc net.Conn) {
-	connection := NewConnection(bufio.NewReader(nc), bufio.NewWriter(nc))
+func (rs *StubServer) handleConnection(connection *Connection) {
+	defer rs.removeMonitor(connection)
+	defer rs.removeSubscriber(connection)
 
 	for {
 		command, args, err := connection.ParseRequest()
@@ -279,8 +387,21 @@ func (rs *StubServer) handleConnection(nc net.Conn) {
 			rs.Unlock()
 		}
 
-		rs.handleCommand(connection, command, args)
-		connection.Flush()
+		rs.feedMonitors(connection, command, args)
+
+		if fault, ok := rs.matchFault(command); ok {
+			if !rs.handleFaultyCommand(connection, fault, command, args) {
+				return
+			}
+		} else {
+			rs.handleCommand(connection, command, args)
+		}
+
+		// The client may have closed the connection in the meantime,
+		// such as when giving up on a reply delayed by a fault.
+		if err := connection.flush(); err != nil {
+			return
+		}
 	}
 }
 
@@ -294,8 +415,18 @@ var ErrUnknownCommand = errors.New("unknown command")
 // error message to the Connection's writer.
 func (rs *StubServer) handleCommand(c *Connection, cmd string, args []string) {
 	rs.Lock()
+	redirect := rs.redirect
 	handlerFn, ok := rs.handlers[cmd]
 	rs.Unlock()
+
+	if !rs.authorize(c, cmd) {
+		return
+	}
+
+	if redirect != nil && redirect(c, cmd, args) {
+		return
+	}
+
 	if !ok {
 		c.WriteError(ErrUnknownCommand)
 		return
@@ -313,6 +444,25 @@ type Connection struct {
 	writer *bufio.Writer
 	reader *bufio.Reader
 	mutex  sync.Mutex
+
+	id       int
+	addr     string
+	protocol int
+
+	// closed is closed once the server closed the connection, for command
+	// handlers blocking the connection to stop waiting.
+	closed chan struct{}
+
+	// user is the name of the user the connection authenticated as, using
+	// the AUTH or HELLO commands. It is only accessed from the goroutine
+	// handling the connection.
+	user string
+
+	// readOnly and asking hold the state set by the READONLY and ASKING
+	// commands of a cluster node. They are only accessed from the
+	// goroutine handling the connection.
+	readOnly bool
+	asking   bool
 }
 
 // NewConnection creates a new Connection from the provided
@@ -321,6 +471,7 @@ func NewConnection(r *bufio.Reader, w *bufio.Writer) *Connection {
 	return &Connection{
 		reader: r,
 		writer: w,
+		closed: make(chan struct{}),
 	}
 }
 
@@ -330,17 +481,35 @@ func (c *Connection) ParseRequest() (string, []string, error) {
 	return NewRESPRequestReader(c.reader).ReadCommand()
 }
 
+// Protocol returns the version of the RESP protocol used on the
+// Connection, as negotiated by the client using the HELLO command.
+func (c *Connection) Protocol() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.protocol == 0 {
+		return 2
+	}
+
+	return c.protocol
+}
+
 // Flush flushes the Connection's writer, effectively sending
 // all buffered data to the client.
 func (c *Connection) Flush() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	if err := c.writer.Flush(); err != nil {
+	if err := c.flush(); err != nil {
 		// this is unrecoverable, so we panic.
 		panic(err)
 	}
 }
 
+// flush flushes the Connection's writer, and returns any error.
+func (c *Connection) flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.writer.Flush()
+}
+
 // WriteSimpleString writes the provided value as a redis simple string message
 // to the Connection's writer.
 func (c *Connection) WriteSimpleString(s string) {
@@ -388,206 +557,83 @@ func (c *Connection) WriteNull() {
 	})
 }
 
-// WriteOK is a helper method for writing the OK response to the
-// Connection's writer.
-func (c *Connection) WriteOK() {
+// WriteDouble writes the provided float as a redis double message
+// to the Connection's writer.
+func (c *Connection) WriteDouble(f float64) {
 	c.callFn(func(w *RESPResponseWriter) {
-		w.WriteSimpleString("OK")
+		w.WriteDouble(f)
 	})
 }
 
-// callFn calls the provided function in a locking manner.
-//
-// It is used to ensure that the Connection's writer is not
-// modified while it is being written to.
-func (c *Connection) callFn(fn func(*RESPResponseWriter)) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	fn(&RESPResponseWriter{c.writer})
-}
-
-// RESPRequestReader is a RESP protocol request reader.
-type RESPRequestReader struct {
-	reader *bufio.Reader
-}
-
-// NewRESPRequestReader returns a new RESPRequestReader.
-func NewRESPRequestReader(reader *bufio.Reader) *RESPRequestReader {
-	return &RESPRequestReader{reader: reader}
-}
-
-// ReadCommand reads a RESP command from the reader, parses it, and
-// returns the parsed command, args, and any potential error encountered.
-func (rrr *RESPRequestReader) ReadCommand() (string, []string, error) {
-	elements, err := scanArray(rrr.reader)
-	if err != nil {
-		return "", nil, err
-	}
-
-	if len(elements) < 1 {
-		return "", nil, ErrInvalidSyntax
-	}
-
-	return strings.ToUpper(elements[0]), elements[1:], nil
-}
-
-// ErrInvalidSyntax is returned when a RESP protocol message
-// is malformed and cannot be parsed.
-var ErrInvalidSyntax = errors.New("invalid RESP protocol syntax")
-
-// Prefix is a placeholder type for the prefix symbol of
-// RESP response message.
-type Prefix byte
-
-// RESP protocol response type prefixes definitions
-const (
-	SimpleStringPrefix Prefix = '+'
-	ErrorPrefix               = '-'
-	IntegerPrefix             = ':'
-	BulkStringPrefix          = '$'
-	ArrayPrefix               = '*'
-	UnknownPrefix
-)
-
-// RESPResponseWriter is a RESP protocol response writer.
-type RESPResponseWriter struct {
-	writer *bufio.Writer
-}
-
-// WriteSimpleString writes a redis inline string
-func (rw *RESPResponseWriter) WriteSimpleString(s string) {
-	_, _ = fmt.Fprintf(rw.writer, "+%s\r\n", inline(s))
-}
-
-// WriteError writes a redis 'Error'
-func (rw *RESPResponseWriter) WriteError(err error) {
-	_, _ = fmt.Fprintf(rw.writer, "-%s\r\n", inline(err.Error()))
-}
-
-// WriteInteger writes an integer
-func (rw *RESPResponseWriter) WriteInteger(n int) {
-	_, _ = fmt.Fprintf(rw.writer, ":%d\r\n", n)
-}
-
-// WriteBulkString writes a bulk string
-func (rw *RESPResponseWriter) WriteBulkString(s string) {
-	_, _ = fmt.Fprintf(rw.writer, "$%d\r\n%s\r\n", len(s), s)
+// WriteBoolean writes the provided boolean as a redis boolean message
+// to the Connection's writer.
+func (c *Connection) WriteBoolean(b bool) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteBoolean(b)
+	})
 }
 
-// WriteArray writes a list of strings (bulk)
-func (rw *RESPResponseWriter) WriteArray(strs ...string) {
-	rw.writeLen(len(strs))
-	for _, s := range strs {
-		if s == "" || s == "nil" {
-			rw.WriteNull()
-			continue
-		}
-
-		rw.WriteBulkString(s)
-	}
+// WriteBigNumber writes the provided integer as a redis big number message
+// to the Connection's writer.
+func (c *Connection) WriteBigNumber(n *big.Int) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteBigNumber(n)
+	})
 }
 
-// WriteNull writes a redis Null element
-func (rw *RESPResponseWriter) WriteNull() {
-	_, _ = fmt.Fprintf(rw.writer, "$-1\r\n")
+// WriteVerbatimString writes the provided string as a redis verbatim string
+// message of the provided format to the Connection's writer.
+func (c *Connection) WriteVerbatimString(format, s string) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteVerbatimString(format, s)
+	})
 }
 
-func (rw *RESPResponseWriter) writeLen(n int) {
-	_, _ = fmt.Fprintf(rw.writer, "*%d\r\n", n)
+// WriteMap writes the provided map as a redis map message
+// to the Connection's writer.
+func (c *Connection) WriteMap(m map[string]any) {
+	c.WriteValue(m)
 }
 
-func inline(s string) string {
-	return strings.Map(func(r rune) rune {
-		if unicode.IsSpace(r) {
-			return ' '
-		}
-		return r
-	}, s)
+// WriteSet writes the provided values as a redis set message
+// to the Connection's writer.
+func (c *Connection) WriteSet(values ...any) {
+	c.WriteValue(Set(values))
 }
 
-// scanBulkString reads a RESP bulk string message from a bufio.reader
+// WritePush writes the provided values as a redis push message
+// to the Connection's writer.
 //
-// It also strips it from its prefix and trailing CRLF character, returning
-// only the interpretable content of the message.
-func scanBulkString(r *bufio.Reader) (string, error) {
-	line, err := scanLine(r)
-	if err != nil {
-		return "", err
-	}
-
-	switch Prefix(line[0]) {
-	case BulkStringPrefix:
-		length, err := strconv.Atoi(line[1 : len(line)-2])
-		if err != nil {
-			return "", err
-		}
-
-		if length < 0 {
-			return line, nil
-		}
-
-		buf := make([]byte, length+2)
-		for pos := 0; pos < length+2; {
-			n, err := r.Read(buf[pos:])
-			if err != nil {
-				return "", err
-			}
-
-			pos += n
-		}
-
-		return string(buf[:len(buf)-2]), nil
-	default:
-		return "", ErrInvalidSyntax
-	}
+// Push messages are usually written outside of a command handler, in
+// which case the Connection must be flushed for the client to receive them.
+func (c *Connection) WritePush(values ...any) {
+	c.WriteValue(Push(values))
 }
 
-// scanArray reads a RESP array message from a bufio.Reader.
+// WriteValue writes the provided value as the redis message matching
+// its type to the Connection's writer.
 //
-// It strips it from its prefix and trailing CRLF character,
-// returning only the interpretable content of the message.
-func scanArray(r *bufio.Reader) ([]string, error) {
-	line, err := scanLine(r)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(line) < 3 {
-		return nil, ErrInvalidSyntax
-	}
-
-	if Prefix(line[0]) != ArrayPrefix {
-		return nil, ErrInvalidSyntax
-	}
-
-	length, err := strconv.Atoi(line[1 : len(line)-2])
-	if err != nil {
-		return nil, err
-	}
-
-	var elements []string
-	for ; length > 0; length-- {
-		next, err := scanBulkString(r)
-		if err != nil {
-			return nil, err
-		}
-
-		elements = append(elements, next)
-	}
-
-	return elements, nil
+// See RESPResponseWriter.WriteValue for the supported types.
+func (c *Connection) WriteValue(value any) {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteValue(value)
+	})
 }
 
-// scanLine reads a RESP protocol line from a bufio.Reader.
-func scanLine(r *bufio.Reader) (string, error) {
-	line, err := r.ReadString('\n')
-	if err != nil {
-		return "", err
-	}
-
-	if len(line) < 3 {
-		return "", ErrInvalidSyntax
-	}
+// WriteOK is a helper method for writing the OK response to the
+// Connection's writer.
+func (c *Connection) WriteOK() {
+	c.callFn(func(w *RESPResponseWriter) {
+		w.WriteSimpleString("OK")
+	})
+}
 
-	return line, nil
+// callFn calls the provided function in a locking manner.
+//
+// It is used to ensure that the Connection's writer is not
+// modified while it is being written to.
+func (c *Connection) callFn(fn func(*RESPResponseWriter)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fn(&RESPResponseWriter{writer: c.writer, protocol: c.protocol})
 }
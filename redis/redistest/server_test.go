@@ -0,0 +1,197 @@
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubServer(t *testing.T) {
+	t.Parallel()
+
+	rs := RunT(t)
+	rs.RegisterCommandHandler("GET", func(c *Connection, args []string) {
+		if args[0] == "missing" {
+			c.WriteNull()
+			return
+		}
+
+		c.WriteBulkString("bar")
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Protocol: 2})
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx := context.Background()
+
+	got, err := client.Get(ctx, "foo").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", got)
+
+	_, err = client.Get(ctx, "missing").Result()
+	assert.ErrorIs(t, err, redis.Nil)
+
+	err = client.Incr(ctx, "foo").Err()
+	assert.ErrorContains(t, err, ErrUnknownCommand.Error())
+
+	assert.Equal(t, 2, rs.HandledCommandsCount())
+	assert.Equal(t, 1, rs.HandledConnectionsCount())
+	assert.Equal(t, [][]string{
+		{"HELLO", "2"},
+		{"GET", "foo"},
+		{"GET", "missing"},
+		{"INCR", "foo"},
+	}, rs.GotCommands())
+}
+
+func TestStubServerSecure(t *testing.T) {
+	t.Parallel()
+
+	rs := RunTSecure(t, nil)
+
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(rs.TLSCertificate()))
+
+	client := redis.NewClient(&redis.Options{
+		Addr:      rs.Addr().String(),
+		Protocol:  2,
+		TLSConfig: &tls.Config{RootCAs: rootCAs, ServerName: "localhost", MinVersion: tls.VersionTLS13},
+	})
+	t.Cleanup(func() { _ = client.Close() })
+
+	got, err := client.Ping(context.Background()).Result()
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", got)
+}
+
+func TestStubServerRESP3(t *testing.T) {
+	t.Parallel()
+
+	rs := RunT(t)
+	rs.EnableRESP3()
+	rs.RegisterCommandHandler("HGETALL", func(c *Connection, _ []string) {
+		c.WriteMap(map[string]any{"a": "1", "b": "2"})
+	})
+	rs.RegisterCommandHandler("ZSCORE", func(c *Connection, _ []string) {
+		c.WriteDouble(1.5)
+	})
+	rs.RegisterCommandHandler("SMEMBERS", func(c *Connection, _ []string) {
+		c.WriteSet("a", "b")
+	})
+	rs.RegisterCommandHandler("NESTED", func(c *Connection, _ []string) {
+		c.WriteValue([]any{int64(1), []any{"a", nil}, true, big.NewInt(42), VerbatimString{Format: "txt", Text: "hi"}})
+	})
+
+	published := make(chan struct{})
+	rs.RegisterCommandHandler("SUBSCRIBE", func(c *Connection, args []string) {
+		c.WritePush("subscribe", args[0], 1)
+
+		go func() {
+			<-published
+			c.WritePush("message", args[0], "hello")
+			c.Flush()
+		}()
+	})
+
+	for _, protocol := range []int{2, 3} {
+		t.Run(fmt.Sprintf("RESP%d", protocol), func(t *testing.T) {
+			t.Parallel()
+
+			client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Protocol: protocol})
+			t.Cleanup(func() { _ = client.Close() })
+
+			ctx := context.Background()
+
+			assert.Equal(t, map[string]string{"a": "1", "b": "2"}, client.HGetAll(ctx, "hash").Val())
+			assert.Equal(t, 1.5, client.ZScore(ctx, "zset", "a").Val())
+			assert.Equal(t, []string{"a", "b"}, client.SMembers(ctx, "set").Val())
+
+			got, err := client.Do(ctx, "NESTED").Result()
+			require.NoError(t, err)
+
+			if protocol == 3 {
+				assert.Equal(t, []any{int64(1), []any{"a", nil}, true, big.NewInt(42), "hi"}, got)
+			} else {
+				assert.Equal(t, []any{int64(1), []any{"a", nil}, int64(1), "42", "hi"}, got)
+			}
+		})
+	}
+
+	t.Run("push messages", func(t *testing.T) {
+		t.Parallel()
+
+		client := redis.NewClient(&redis.Options{Addr: rs.Addr().String(), Protocol: 3})
+		t.Cleanup(func() { _ = client.Close() })
+
+		ctx := context.Background()
+
+		// go-redis blocks until it has read 36 bytes of a push message to
+		// identify it, hence a channel name long enough for the subscription
+		// confirmation to exceed that.
+		pubsub := client.Subscribe(ctx, "notifications")
+		t.Cleanup(func() { _ = pubsub.Close() })
+
+		_, err := pubsub.Receive(ctx)
+		require.NoError(t, err)
+
+		close(published)
+
+		msg, err := pubsub.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "notifications", msg.Channel)
+		assert.Equal(t, "hello", msg.Payload)
+	})
+}
+
+func TestRESPResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	value := []any{
+		map[string]any{"b": 2.5, "a": nil},
+		Set{true},
+		Push{big.NewInt(-7), VerbatimString{Format: "mkd", Text: "# hi"}},
+	}
+
+	testCases := []struct {
+		protocol int
+		want     string
+	}{
+		{
+			protocol: 2,
+			want: "*3\r\n" +
+				"*4\r\n$1\r\na\r\n$-1\r\n$1\r\nb\r\n$3\r\n2.5\r\n" +
+				"*1\r\n:1\r\n" +
+				"*2\r\n$2\r\n-7\r\n$4\r\n# hi\r\n",
+		},
+		{
+			protocol: 3,
+			want: "*3\r\n" +
+				"%2\r\n$1\r\na\r\n_\r\n$1\r\nb\r\n,2.5\r\n" +
+				"~1\r\n#t\r\n" +
+				">2\r\n(-7\r\n=8\r\nmkd:# hi\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("RESP%d", tc.protocol), func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+
+			(&RESPResponseWriter{writer: w, protocol: tc.protocol}).WriteValue(value)
+			require.NoError(t, w.Flush())
+
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package redis
+package redistest
 
 import (
 	"crypto/ecdsa"
@@ -13,10 +13,10 @@ import (
 	"time"
 )
 
-// Generate self-signed TLS certificate and private key for testing purposes,
-// and return them as PEM encoded data.
+// GenerateTLSCert generates a self-signed TLS certificate and private key for
+// testing purposes, and returns them as PEM encoded data.
 // Source: https://eli.thegreenplace.net/2021/go-https-servers-with-tls/
-func generateTLSCert() (certPEM, privateKeyPEM []byte, err error) {
+func GenerateTLSCert() (certPEM, privateKeyPEM []byte, err error) {
 	pkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
@@ -0,0 +1,418 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/js/common"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+// scanOptions holds the options of the `client.scan`, `client.hscan`,
+// `client.sscan` and `client.zscan` methods.
+type scanOptions struct {
+	// Match is the glob-style pattern the returned elements must match.
+	Match string `json:"match,omitempty"`
+
+	// Count is the number of elements the server is asked to return by
+	// each call of the underlying command.
+	Count int64 `json:"count,omitempty"`
+
+	// Type is the type of the keys to return. Only `client.scan` supports it.
+	Type string `json:"type,omitempty"`
+}
+
+// scanFunc calls a command of the SCAN family from the provided cursor, and
+// returns the elements it returned, and the cursor to continue from, which
+// is 0 once the iteration is complete.
+type scanFunc func(ctx context.Context, cursor uint64) ([]any, uint64, error)
+
+// Scan iterates over the keys of the database using the SCAN command.
+//
+// The options object accepts a `match` pattern, a `count` hint, and the
+// `type` of the keys to return. It returns an iterator, whose `next`
+// method returns a promise resolving to the next key, and which calls the
+// SCAN command under the hood whenever needed. Its `return` method ends
+// the iteration early.
+//
+// As sobek does not support `for await` loops, the iterator also has a
+// `forEach` method, which calls the provided callback with each key,
+// awaiting the promise it returns if any, and returns a promise resolving
+// once the iteration is complete. Returning false from the callback ends
+// the iteration early. Alternatively, callers can loop on `next`.
+//
+// In cluster mode, the keys of all the master nodes are iterated over, one
+// node after the other. The SCAN commands sent to the nodes are not passed
+// to the hooks registered using `client.addHook`.
+//
+// As with SCAN, a key is returned at least once if it was present during
+// the whole iteration, but it can be returned more than once.
+func (c *Client) Scan(options, params any) *sobek.Object {
+	opts := c.readScanOptions(options, true)
+
+	shards := func(ctx context.Context) ([]scanFunc, error) {
+		cluster, ok := c.redisClient.(*redis.ClusterClient)
+		if !ok {
+			return []scanFunc{c.keysScanner(c.redisClient, opts)}, nil
+		}
+
+		return c.clusterKeysScanners(ctx, cluster, opts)
+	}
+
+	return c.newScanIterator(shards, params)
+}
+
+// Hscan iterates over the fields of the hash stored at key using the HSCAN
+// command.
+//
+// It returns an iterator, as `client.scan` does, whose values are
+// objects holding a `field` and its `value`.
+func (c *Client) Hscan(key string, options, params any) *sobek.Object {
+	opts := c.readScanOptions(options, false)
+
+	return c.newKeyScanIterator(func(ctx context.Context, cursor uint64) ([]any, uint64, error) {
+		pairs, next, err := c.redisClient.HScan(ctx, key, cursor, opts.Match, opts.Count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		values := make([]any, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			values = append(values, map[string]any{"field": pairs[i], "value": pairs[i+1]})
+		}
+
+		return values, next, nil
+	}, params)
+}
+
+// Sscan iterates over the members of the set stored at key using the SSCAN
+// command.
+//
+// It returns an iterator, as `client.scan` does, whose values are
+// the members of the set.
+func (c *Client) Sscan(key string, options, params any) *sobek.Object {
+	opts := c.readScanOptions(options, false)
+
+	return c.newKeyScanIterator(func(ctx context.Context, cursor uint64) ([]any, uint64, error) {
+		members, next, err := c.redisClient.SScan(ctx, key, cursor, opts.Match, opts.Count).Result()
+
+		return toAnys(members), next, err
+	}, params)
+}
+
+// Zscan iterates over the members of the sorted set stored at key using the
+// ZSCAN command.
+//
+// It returns an iterator, as `client.scan` does, whose values are
+// objects holding a `member` and its `score`.
+func (c *Client) Zscan(key string, options, params any) *sobek.Object {
+	opts := c.readScanOptions(options, false)
+
+	return c.newKeyScanIterator(func(ctx context.Context, cursor uint64) ([]any, uint64, error) {
+		pairs, next, err := c.redisClient.ZScan(ctx, key, cursor, opts.Match, opts.Count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		values := make([]any, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			score, err := strconv.ParseFloat(pairs[i+1], 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid score %q of member %q", pairs[i+1], pairs[i])
+			}
+			values = append(values, map[string]any{"member": pairs[i], "score": score})
+		}
+
+		return values, next, nil
+	}, params)
+}
+
+// keysScanner returns the scanFunc iterating over the keys served by the
+// provided client.
+func (c *Client) keysScanner(client redis.Cmdable, opts *scanOptions) scanFunc {
+	return func(ctx context.Context, cursor uint64) ([]any, uint64, error) {
+		var (
+			keys []string
+			next uint64
+			err  error
+		)
+		if opts.Type != "" {
+			keys, next, err = client.ScanType(ctx, cursor, opts.Match, opts.Count, opts.Type).Result()
+		} else {
+			keys, next, err = client.Scan(ctx, cursor, opts.Match, opts.Count).Result()
+		}
+
+		return toAnys(keys), next, err
+	}
+}
+
+// clusterKeysScanners returns the scanFuncs iterating over the keys served
+// by each of the cluster's master nodes, ordered by address.
+//
+// The clients of the nodes do not carry the hooks of the cluster client,
+// so the metrics of the commands sent through them are emitted explicitly.
+func (c *Client) clusterKeysScanners(
+	ctx context.Context, cluster *redis.ClusterClient, opts *scanOptions,
+) ([]scanFunc, error) {
+	var (
+		mu      sync.Mutex
+		masters []*redis.Client
+	)
+	err := cluster.ForEachMaster(ctx, func(_ context.Context, master *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		masters = append(masters, master)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(masters, func(a, b *redis.Client) int {
+		return cmp.Compare(a.Options().Addr, b.Options().Addr)
+	})
+
+	scanners := make([]scanFunc, 0, len(masters))
+	for _, master := range masters {
+		scan := c.keysScanner(master, opts)
+		scanners = append(scanners, func(ctx context.Context, cursor uint64) ([]any, uint64, error) {
+			start := time.Now()
+			keys, next, err := scan(ctx, cursor)
+			c.emitCommandMetrics(ctx, "scan", start, err)
+
+			return keys, next, err
+		})
+	}
+
+	return scanners, nil
+}
+
+// newKeyScanIterator returns an iterator calling the provided scanFunc,
+// which iterates over the elements of a single key.
+func (c *Client) newKeyScanIterator(scan scanFunc, params any) *sobek.Object {
+	return c.newScanIterator(func(context.Context) ([]scanFunc, error) {
+		return []scanFunc{scan}, nil
+	}, params)
+}
+
+// scanForEachProgram evaluates to a function returning the `forEach` method
+// of the provided iterator, which is written in JS in order to await the
+// promises returned by its `next` method and by the callback.
+var scanForEachProgram = sobek.MustCompile("scanForEach", `(iterator) => async (callback) => {
+	for (let res = await iterator.next(); !res.done; res = await iterator.next()) {
+		if (await callback(res.value) === false) {
+			await iterator.return();
+			return;
+		}
+	}
+}`, false)
+
+// scanIterator drives the cursors of a command of the SCAN family, possibly
+// over several shards, and is exposed to JS as an iterator.
+type scanIterator struct {
+	client *Client
+	params any
+
+	// shards returns the scanFuncs of the shards to iterate over, one
+	// after the other. It is called once the client is connected.
+	shards func(ctx context.Context) ([]scanFunc, error)
+
+	// turn is closed once the previous call of next or return completed,
+	// so that the calls are processed in order.
+	turn chan struct{}
+
+	// The following fields are only accessed by the call holding the turn.
+	pending []scanFunc
+	loaded  bool
+	cursor  uint64
+	buffer  []any
+	done    bool
+}
+
+// newScanIterator returns the JS object of an iterator over the shards
+// returned by the provided function.
+func (c *Client) newScanIterator(shards func(ctx context.Context) ([]scanFunc, error), params any) *sobek.Object {
+	it := &scanIterator{
+		client: c,
+		params: params,
+		shards: shards,
+		turn:   make(chan struct{}),
+	}
+	close(it.turn)
+
+	rt := c.vu.Runtime()
+	obj := rt.NewObject()
+	must(rt, obj.Set("next", it.next))
+	must(rt, obj.Set("return", it.stop))
+
+	newForEach, err := rt.RunProgram(scanForEachProgram)
+	must(rt, err)
+	newForEachFn, _ := sobek.AssertFunction(newForEach)
+	forEach, err := newForEachFn(sobek.Undefined(), obj)
+	must(rt, err)
+	must(rt, obj.Set("forEach", forEach))
+
+	return obj
+}
+
+// next returns a promise resolving to the next iterator result.
+func (it *scanIterator) next() *sobek.Promise {
+	return it.call(func(ctx context.Context) (map[string]any, error) {
+		value, ok, err := it.advance(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return map[string]any{"done": true}, nil
+		}
+
+		return map[string]any{"value": value, "done": false}, nil
+	})
+}
+
+// stop ends the iteration, and returns a promise resolving to the final
+// iterator result.
+func (it *scanIterator) stop() *sobek.Promise {
+	return it.call(func(context.Context) (map[string]any, error) {
+		it.done = true
+		it.buffer = nil
+
+		return map[string]any{"done": true}, nil
+	})
+}
+
+// call calls the provided function once the previous calls of the
+// iterator completed, and returns a promise resolving to its result.
+func (it *scanIterator) call(fn func(ctx context.Context) (map[string]any, error)) *sobek.Promise {
+	c := it.client
+	promise, resolve, reject := promises.New(c.vu)
+
+	if err := c.connect(); err != nil {
+		reject(err)
+		return promise
+	}
+
+	ctx, done, err := c.commandContext(it.params)
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	previous, turn := it.turn, make(chan struct{})
+	it.turn = turn
+
+	go func() {
+		defer done()
+
+		<-previous
+		defer close(turn)
+
+		result, err := fn(ctx)
+		if err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// advance returns the next element of the iteration, calling the command
+// as many times as needed, and false once the iteration is complete.
+//
+// If the command fails, the error is returned, and the next call retries
+// from the same cursor.
+func (it *scanIterator) advance(ctx context.Context) (any, bool, error) {
+	for len(it.buffer) == 0 {
+		if it.done {
+			return nil, false, nil
+		}
+
+		if !it.loaded {
+			shards, err := it.shards(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			it.pending, it.loaded = shards, true
+			it.done = len(shards) == 0
+			continue
+		}
+
+		values, next, err := it.pending[0](ctx, it.cursor)
+		if err != nil {
+			return nil, false, err
+		}
+
+		it.buffer, it.cursor = values, next
+		if next == 0 {
+			it.pending = it.pending[1:]
+			it.done = len(it.pending) == 0
+		}
+	}
+
+	value := it.buffer[0]
+	it.buffer = it.buffer[1:]
+
+	return value, true, nil
+}
+
+// readScanOptions validates and instantiates the scanOptions from their
+// map representation as exported from sobek.Runtime, and throws if they
+// are invalid.
+func (c *Client) readScanOptions(options any, allowType bool) *scanOptions {
+	opts, err := parseScanOptions(options, allowType)
+	if err != nil {
+		common.Throw(c.vu.Runtime(), err)
+	}
+
+	return opts
+}
+
+// parseScanOptions validates and instantiates the scanOptions from their
+// map representation as exported from sobek.Runtime.
+func parseScanOptions(options any, allowType bool) (*scanOptions, error) {
+	opts := &scanOptions{}
+	if err := decodeCommandOptions("scan", options, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Count < 0 {
+		return nil, fmt.Errorf("invalid scan options; reason: count must not be negative, got %d", opts.Count)
+	}
+
+	if opts.Type != "" && !allowType {
+		return nil, errors.New("invalid scan options; reason: type is only supported by scan")
+	}
+
+	return opts, nil
+}
+
+// toAnys converts a slice of strings to a slice of any.
+func toAnys(strs []string) []any {
+	values := make([]any, 0, len(strs))
+	for _, s := range strs {
+		values = append(values, s)
+	}
+
+	return values
+}
+
+// must throws the provided error, if any.
+func must(rt *sobek.Runtime, err error) {
+	if err != nil {
+		common.Throw(rt, err)
+	}
+}
@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientScan(t *testing.T) {
+	t.Parallel()
+
+	// runScript runs the provided script, in which the `redis` variable is
+	// a client created with the provided options, and the `collect`
+	// function resolves to all the values of an iterator.
+	runScript := func(t *testing.T, options, script string) error {
+		t.Helper()
+
+		ts := newTestSetup(t)
+
+		return ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client(%s);
+
+				const collect = async (iterator) => {
+					const values = [];
+					for (let res = await iterator.next(); !res.done; res = await iterator.next()) {
+						values.push(res.value);
+					}
+					return values;
+				};
+
+				%s
+			`, options, script))
+
+			return err
+		})
+	}
+
+	t.Run("keys are iterated over", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			(async () => {
+				for (let i = 0; i < 25; i++) {
+					await redis.set("key:" + String(i).padStart(2, "0"), "v", 0);
+				}
+				await redis.lpush("list", "a");
+
+				const keys = await collect(redis.scan({ count: 10 }));
+				if (keys.length !== 26 || keys[0] !== "key:00" || keys[25] !== "list") {
+					throw 'unexpected keys: ' + JSON.stringify(keys)
+				}
+
+				expect(await collect(redis.scan({ match: "key:1?", count: 100 })).then(keys => keys.length), 10);
+				expect(await collect(redis.scan({ type: "list" })), ["list"]);
+			})()
+		`)
+		require.NoError(t, err)
+
+		// The iterator drives the cursor: 26 keys with a count of 10 take 3 calls.
+		scans := 0
+		for _, cmd := range rs.GotCommands() {
+			if cmd[0] == "SCAN" && cmd[len(cmd)-1] == "10" {
+				scans++
+			}
+		}
+		assert.Equal(t, 3, scans)
+	})
+
+	t.Run("iterations can be stopped", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			(async () => {
+				await redis.set("a", "1", 0);
+				await redis.set("b", "2", 0);
+
+				const iterator = redis.scan();
+				expect(await iterator.next(), { value: "a", done: false });
+				expect(await iterator.return(), { done: true });
+				expect(await iterator.next(), { done: true });
+			})()
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("iterations can be consumed with forEach", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			(async () => {
+				await redis.sadd("set", "a", "b", "c");
+
+				const members = [];
+				expect(await redis.sscan("set").forEach(async (member) => { members.push(member) }), undefined);
+				expect(members, ["a", "b", "c"]);
+
+				const iterator = redis.sscan("set");
+				const first = [];
+				await iterator.forEach((member) => { first.push(member); return false; });
+				expect(first, ["a"]);
+				expect(await iterator.next(), { done: true });
+
+				await redis.sscan("set").forEach(() => { throw "stop" }).then(
+					() => { throw 'unexpected success' },
+					err => { if (err !== "stop") { throw 'unexpected error: ' + err } },
+				);
+			})()
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("hashes, sets and sorted sets are iterated over", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+		rs.UseKeyspace(redistest.NewKeyspace())
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			(async () => {
+				await redis.sendCommand("HSET", "hash", "f1", "v1", "f2", "v2");
+				await redis.sadd("set", "m1", "m2", "x");
+				await redis.sendCommand("ZADD", "zset", "2", "z1", "1.5", "z2");
+
+				expect(await collect(redis.hscan("hash")), [{ field: "f1", value: "v1" }, { field: "f2", value: "v2" }]);
+				expect(await collect(redis.sscan("set", { match: "m*" })), ["m1", "m2"]);
+				expect(await collect(redis.zscan("zset")), [{ member: "z2", score: 1.5 }, { member: "z1", score: 2 }]);
+				expect(await collect(redis.sscan("missing")), []);
+
+				await expectError(redis.sscan("hash").next(), "WRONGTYPE");
+			})()
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("cluster masters are all iterated over", func(t *testing.T) {
+		t.Parallel()
+
+		sc := redistest.RunClusterT(t, 3, 1)
+		sc.UseKeyspace()
+
+		var urls []string
+		for _, addr := range sc.Addrs() {
+			urls = append(urls, "redis://"+addr)
+		}
+		nodes, err := json.Marshal(urls)
+		require.NoError(t, err)
+
+		err = runScript(t, fmt.Sprintf("{ cluster: { nodes: %s } }", nodes), `
+			(async () => {
+				const want = [];
+				for (let i = 0; i < 30; i++) {
+					want.push("key:" + i);
+					await redis.set("key:" + i, "v", 0);
+				}
+
+				const keys = await collect(redis.scan({ count: 4 }));
+				expect(keys.sort(), want.sort());
+			})()
+		`)
+		require.NoError(t, err)
+
+		for _, master := range sc.Masters {
+			assert.Contains(t, master.GotCommands(), []string{"SCAN", "0", "count", "4"})
+		}
+	})
+
+	t.Run("invalid options throw", func(t *testing.T) {
+		t.Parallel()
+
+		rs := redistest.RunT(t)
+
+		err := runScript(t, fmt.Sprintf("'redis://%s'", rs.Addr()), `
+			const expectThrow = (fn, expected) => {
+				try {
+					fn();
+				} catch (err) {
+					if (!String(err).includes(expected)) { throw 'unexpected error: ' + err }
+					return;
+				}
+				throw 'expected an error';
+			};
+
+			expectThrow(() => redis.scan({ pattern: "*" }), "unknown field");
+			expectThrow(() => redis.scan({ count: -1 }), "count must not be negative");
+			expectThrow(() => redis.hscan("hash", { type: "string" }), "type is only supported by scan");
+		`)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.k6.io/k6/v2/lib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer used to create the
+// spans of the redis commands.
+const tracerName = "github.com/grafana/xk6-redis"
+
+// tracingHook is a redis.Hook creating an OpenTelemetry span for each
+// command, and each pipeline, processed by the client.
+//
+// Spans are created using the VU's tracer provider, which k6 configures
+// from its `--traces-output` option (or `K6_TRACES_OUTPUT` environment
+// variable). They are children of the span carried by the command's
+// context, if any.
+type tracingHook struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+var _ redis.Hook = &tracingHook{}
+
+// newTracingHook returns a tracingHook creating spans using the provided
+// tracer provider, for a client connected to the provided addresses.
+func newTracingHook(provider lib.TracerProvider, addrs []string) *tracingHook {
+	attrs := []attribute.KeyValue{semconv.DBSystemNameRedis}
+
+	// The server's address is only known beforehand when a single
+	// one was provided; e.g. not in cluster mode.
+	if len(addrs) == 1 {
+		if host, port, err := net.SplitHostPort(addrs[0]); err == nil {
+			attrs = append(attrs, semconv.ServerAddress(host))
+			if p, err := strconv.Atoi(port); err == nil {
+				attrs = append(attrs, semconv.ServerPort(p))
+			}
+		}
+	}
+
+	return &tracingHook{
+		tracer: provider.Tracer(tracerName),
+		attrs:  attrs,
+	}
+}
+
+// DialHook implements the redis.Hook interface.
+func (h *tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook implements the redis.Hook interface, and wraps the processing
+// of the command in a span named after it.
+func (h *tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(h.attrs...),
+			trace.WithAttributes(semconv.DBOperationName(cmd.Name())),
+		)
+		defer span.End()
+
+		err := next(ctx, cmd)
+		recordError(span, err)
+
+		return err
+	}
+}
+
+// ProcessPipelineHook implements the redis.Hook interface, and wraps the
+// processing of the pipeline in a single span.
+func (h *tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, "pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(h.attrs...),
+			trace.WithAttributes(semconv.DBOperationBatchSize(len(cmds))),
+		)
+		defer span.End()
+
+		err := next(ctx, cmds)
+		recordError(span, err)
+
+		return err
+	}
+}
+
+// recordError records the provided error on the span, and marks it as
+// failed. A redis.Nil error, which denotes an empty reply, is not
+// considered a failure.
+func recordError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
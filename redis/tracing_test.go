@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/xk6-redis/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestClientTracing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans are created for each command when tracing is enabled", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+		rs.RegisterCommandHandler("GET", func(c *redistest.Connection, _ []string) {
+			c.WriteBulkString("bar")
+		})
+
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ts.state.TracerProvider = provider
+
+		// Commands are traced as children of the span carried by the VU's context.
+		ctx, parent := provider.Tracer("test").Start(ts.runtime.VU.CtxField, "iteration")
+		ts.runtime.VU.CtxField = ctx
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client({
+					socket: {
+						host: '%s',
+						port: %d,
+					},
+					tracing: true,
+				});
+
+				redis.get("foo")
+					.then(() => redis.incr("foo"))
+					.catch(() => {})
+			`, rs.Addr().IP.String(), rs.Addr().Port))
+
+			return err
+		})
+		require.NoError(t, gotScriptErr)
+		parent.End()
+
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+
+		require.Contains(t, spans, "get")
+		get := spans["get"]
+		assert.Equal(t, trace.SpanKindClient, get.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), get.Parent().SpanID())
+		assert.Equal(t, codes.Unset, get.Status().Code)
+		assert.Subset(t, get.Attributes(), []attribute.KeyValue{
+			attribute.String("db.system.name", "redis"),
+			attribute.String("db.operation.name", "get"),
+			attribute.String("server.address", rs.Addr().IP.String()),
+			attribute.Int("server.port", rs.Addr().Port),
+		})
+
+		require.Contains(t, spans, "incr")
+		incr := spans["incr"]
+		assert.Equal(t, codes.Error, incr.Status().Code)
+		assert.Contains(t, incr.Status().Description, "unknown command")
+	})
+
+	t.Run("no spans are created when tracing is disabled", func(t *testing.T) {
+		t.Parallel()
+
+		ts := newTestSetup(t)
+		rs := redistest.RunT(t)
+
+		recorder := tracetest.NewSpanRecorder()
+		ts.state.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		gotScriptErr := ts.runtime.EventLoop.Start(func() error {
+			_, err := ts.rt.RunString(fmt.Sprintf(`
+				const redis = new Client('redis://%s');
+
+				redis.sendCommand("PING");
+			`, rs.Addr()))
+
+			return err
+		})
+		require.NoError(t, gotScriptErr)
+
+		assert.Empty(t, recorder.Ended())
+	})
+}